@@ -68,6 +68,7 @@ CREATE TABLE "Notification" (
                                 title        TEXT NOT NULL,
                                 "userId"     TEXT NOT NULL REFERENCES "User"(id) ON UPDATE CASCADE ON DELETE CASCADE,
                                 read         BOOLEAN NOT NULL DEFAULT FALSE,
+                                archived     BOOLEAN NOT NULL DEFAULT FALSE,
                                 "createTime" TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
 
@@ -0,0 +1,48 @@
+// Command announce-import copies announcements and per-user read/favourite
+// state from the ANNOUNCE_DIR file layout (plus legacy directories) into the
+// PostgreSQL announcement tables. Run it once before switching the server
+// to ANNOUNCE_STORE=postgres; re-running is harmless.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"scholarhub/backend-go/internal/models"
+	"scholarhub/backend-go/internal/server"
+
+	"github.com/joho/godotenv"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func main() {
+	dir := flag.String("dir", "", "announcement directory (defaults to ANNOUNCE_DIR resolution)")
+	flag.Parse()
+	for _, p := range []string{"../server/.env", "../../server/.env", "server/.env", ".env"} {
+		if _, err := os.Stat(p); err == nil {
+			_ = godotenv.Load(p)
+			break
+		}
+	}
+	if *dir != "" {
+		_ = os.Setenv("ANNOUNCE_DIR", *dir)
+	}
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		log.Fatal("DATABASE_URL missing")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.Announcement{}, &models.AnnouncementState{}, &models.AnnouncementRevision{}); err != nil {
+		log.Fatal(err)
+	}
+	src := server.NewFileAnnouncementStore(server.AnnounceDirs())
+	n, users, err := server.ImportAnnouncements(src, server.NewPGAnnouncementStore(db))
+	if err != nil {
+		log.Fatalf("import failed after %d announcements, %d users: %v", n, users, err)
+	}
+	log.Printf("imported %d announcements and state for %d users", n, users)
+}
@@ -0,0 +1,38 @@
+// Command announce-rerender rebuilds the stored HTML of every announcement
+// from its markdown with the current renderer. Run it after upgrading the
+// markdown renderer or sanitizer policy; re-running is harmless.
+package main
+
+import (
+	"log"
+	"os"
+	"scholarhub/backend-go/internal/server"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func main() {
+	for _, p := range []string{"../server/.env", "../../server/.env", "server/.env", ".env"} {
+		if _, err := os.Stat(p); err == nil {
+			_ = godotenv.Load(p)
+			break
+		}
+	}
+	var db *gorm.DB
+	if dsn := os.Getenv("DATABASE_URL"); dsn != "" {
+		var err error
+		if db, err = gorm.Open(postgres.Open(dsn), &gorm.Config{}); err != nil {
+			log.Fatal(err)
+		}
+	} else if strings.EqualFold(os.Getenv("ANNOUNCE_STORE"), "postgres") {
+		log.Fatal("DATABASE_URL missing")
+	}
+	n, err := server.RerenderAnnouncements(server.NewAnnouncementStore(db))
+	if err != nil {
+		log.Fatalf("re-render failed after %d announcements: %v", n, err)
+	}
+	log.Printf("re-rendered %d announcements", n)
+}
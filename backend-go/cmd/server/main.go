@@ -1,13 +1,18 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"scholarhub/backend-go/internal/models"
 	"scholarhub/backend-go/internal/server"
+	"strconv"
+	"syscall"
 	"time"
+	_ "time/tzdata" // digest time zones; the alpine image has no zoneinfo
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -33,13 +38,51 @@ func main() {
 	if err := db.AutoMigrate(&models.HealthSample{}); err != nil {
 		log.Printf("AutoMigrate HealthSample skipped: %v", err)
 	}
+	if err := db.AutoMigrate(&models.HealthRollupHour{}, &models.HealthRollupDay{}); err != nil {
+		log.Printf("AutoMigrate health rollup tables skipped: %v", err)
+	}
+	if err := db.AutoMigrate(&models.RouteTelemetry{}); err != nil {
+		log.Printf("AutoMigrate RouteTelemetry skipped: %v", err)
+	}
+	if err := db.AutoMigrate(&models.HealthIncident{}); err != nil {
+		log.Printf("AutoMigrate HealthIncident skipped: %v", err)
+	}
+	if err := db.AutoMigrate(&models.SyntheticStep{}); err != nil {
+		log.Printf("AutoMigrate SyntheticStep skipped: %v", err)
+	}
+	if err := db.AutoMigrate(&models.AlertRule{}, &models.Alert{}, &models.AlertSilence{}); err != nil {
+		log.Printf("AutoMigrate alert tables skipped: %v", err)
+	}
+	if err := db.AutoMigrate(&models.QuestionMerge{}, &models.QuestionFollow{}); err != nil {
+		log.Printf("AutoMigrate QA tables skipped: %v", err)
+	}
+	if err := db.AutoMigrate(&models.NotificationPreference{}, &models.DigestDelivery{}); err != nil {
+		log.Printf("AutoMigrate notification tables skipped: %v", err)
+	}
+	if err := db.Exec(`ALTER TABLE "Notification" ADD COLUMN IF NOT EXISTS archived BOOLEAN NOT NULL DEFAULT FALSE`).Error; err != nil {
+		log.Printf("add Notification.archived skipped: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Announcement{}, &models.AnnouncementState{}, &models.AnnouncementRevision{}, &models.CourseEnrollment{}, &models.FeedToken{}); err != nil {
+		log.Printf("AutoMigrate announcement tables skipped: %v", err)
+	}
+	if err := server.LoadHealthModel(); err != nil {
+		log.Fatal(err)
+	}
+	stopModelWatch := server.WatchHealthModel(10 * time.Second)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	server.StartEventRelay(ctx, db, dsn)
+	stopLeader := server.StartLeaderElection(db)
 	hc := server.NewHealthCollector(db)
 	hc.Start()
-	log.Printf("health_collector started")
+	log.Printf("health_collector started instance=%s", server.InstanceID())
 	r := gin.Default()
 	r.Use(server.CORS())
+	r.Use(server.RequestTelemetry())
+	stopMetrics := server.RegisterMetrics(r, db)
 	server.RegisterStatic(r)
-	server.RegisterRoutes(r, db)
+	server.RegisterProbes(r, db)
+	stopJobs := server.RegisterRoutes(r, db)
 	pc := server.LoadPortConfig()
 	pm := server.NewPortManager(pc)
 	ln, port, err := pm.GetListener()
@@ -47,28 +90,69 @@ func main() {
 		log.Fatal(err)
 	}
 	log.Printf("admin server listening on :%d", port)
-	s := &httpServer{r: r, ln: ln}
+	s := &httpServer{ctx: ctx, stop: stop, r: r, ln: ln, onShutdown: func() { stopJobs(); hc.Stop(); stopMetrics(); stopModelWatch(); stopLeader() }}
 	s.run()
 }
 
 type httpServer struct {
-	r  *gin.Engine
-	ln net.Listener
+	ctx        context.Context
+	stop       context.CancelFunc
+	r          *gin.Engine
+	ln         net.Listener
+	onShutdown func()
 }
 
 func (s *httpServer) run() {
-	srv := &serverRunner{engine: s.r, ln: s.ln}
+	srv := &serverRunner{ctx: s.ctx, stop: s.stop, engine: s.r, ln: s.ln, onShutdown: s.onShutdown}
 	srv.start()
 }
 
 type serverRunner struct {
-	engine *gin.Engine
-	ln     net.Listener
+	ctx        context.Context
+	stop       context.CancelFunc
+	engine     *gin.Engine
+	ln         net.Listener
+	onShutdown func()
 }
 
+// start serves until ctx, cancelled by SIGINT/SIGTERM, is done, then
+// drains in-flight requests and stops the background jobs.
 func (s *serverRunner) start() {
 	srv := &http.Server{Handler: s.engine}
-	_ = srv.Serve(s.ln)
+	go func() {
+		if err := srv.Serve(s.ln); err != nil && err != http.ErrServerClosed {
+			log.Printf("server error: %v", err)
+			s.stop()
+		}
+	}()
+	<-s.ctx.Done()
+	server.SetDraining()
+	if d := drainDelay(); d > 0 {
+		log.Printf("draining for %s before shutdown", d)
+		time.Sleep(d)
+	}
+	log.Printf("shutting down")
+	sctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(sctx); err != nil {
+		log.Printf("server shutdown error: %v", err)
+	}
+	if s.onShutdown != nil {
+		s.onShutdown()
+	}
+}
+
+// drainDelay is how long /readyz reports draining before the listener
+// closes, so load balancers see it and stop routing here first:
+// SHUTDOWN_DRAIN_SECONDS, by default 5 in release mode and 0 otherwise.
+func drainDelay() time.Duration {
+	if n, err := strconv.Atoi(os.Getenv("SHUTDOWN_DRAIN_SECONDS")); err == nil && n >= 0 {
+		return time.Duration(n) * time.Second
+	}
+	if gin.Mode() == gin.ReleaseMode {
+		return 5 * time.Second
+	}
+	return 0
 }
 
 func loadEnv() {
@@ -1,6 +1,11 @@
 package models
 
-import "time"
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
 
 type User struct {
 	// 加上 json 标签，前端才能拿到 id, username
@@ -98,6 +103,7 @@ type Notification struct {
 	Title      string    `gorm:"column:title" json:"title"`
 	UserID     string    `gorm:"column:userId" json:"userId"`
 	Read       bool      `gorm:"column:read" json:"read"`
+	Archived   bool      `gorm:"column:archived;default:false" json:"archived"`
 	CreateTime time.Time `gorm:"column:createTime;autoCreateTime" json:"createTime"`
 }
 
@@ -114,16 +120,354 @@ type AdminLog struct {
 
 func (AdminLog) TableName() string { return "\"AdminLog\"" }
 
+// HealthSample is one collector reading. EndpointMs is how long taking it
+// took; ApiP95Ms is the API p95 latency at the time, 0 without traffic.
 type HealthSample struct {
+	ID          int             `gorm:"column:id;primaryKey" json:"id"`
+	Score       int             `gorm:"column:score" json:"score"`
+	Status      string          `gorm:"column:status" json:"status"`
+	CpuUsed     float64         `gorm:"column:cpuUsed" json:"cpuUsed"`
+	MemUsed     float64         `gorm:"column:memUsed" json:"memUsed"`
+	DiskUsed    float64         `gorm:"column:diskUsed" json:"diskUsed"`
+	DbLatencyMs int64           `gorm:"column:dbLatencyMs" json:"dbLatencyMs"`
+	EndpointMs  int64           `gorm:"column:endpointMs" json:"endpointMs"`
+	ApiP95Ms    int64           `gorm:"column:apiP95Ms" json:"apiP95Ms"`
+	InstanceID  string          `gorm:"column:instanceId;index" json:"instanceId"`
+	Host        string          `gorm:"column:host" json:"host"`
+	Anomalies   HealthAnomalies `gorm:"column:anomalies;type:text" json:"anomalies,omitempty"`
+	CreateTime  time.Time       `gorm:"column:createTime;autoCreateTime" json:"createTime"`
+}
+
+func (HealthSample) TableName() string { return "\"HealthSample\"" }
+
+// HealthAnomaly is one metric of a sample that strayed from its baseline.
+// Basis is "hour-of-week" when compared with the same hour in past weeks,
+// or "recent" when compared with a moving average for lack of history.
+type HealthAnomaly struct {
+	Metric   string  `json:"metric"`
+	Value    float64 `json:"value"`
+	Expected float64 `json:"expected"`
+	StdDev   float64 `json:"stdDev"`
+	Z        float64 `json:"z"`
+	Basis    string  `json:"basis"`
+	Message  string  `json:"message"`
+}
+
+// HealthAnomalies is kept as JSON text; a sample without anomalies stores
+// NULL.
+type HealthAnomalies []HealthAnomaly
+
+func (a HealthAnomalies) Value() (driver.Value, error) {
+	if len(a) == 0 {
+		return nil, nil
+	}
+	b, err := json.Marshal(a)
+	return string(b), err
+}
+
+func (a *HealthAnomalies) Scan(v interface{}) error {
+	switch x := v.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case string:
+		return json.Unmarshal([]byte(x), a)
+	case []byte:
+		return json.Unmarshal(x, a)
+	}
+	return fmt.Errorf("HealthAnomalies: cannot scan %T", v)
+}
+
+// HealthRollup summarises the samples of one bucket: min/avg/max/p95 for
+// every metric and how many samples had each status, across all instances.
+// HealthRollupHour and HealthRollupDay share it and differ only in bucket
+// size and table.
+type HealthRollup struct {
+	ID            int       `gorm:"column:id;primaryKey" json:"-"`
+	BucketStart   time.Time `gorm:"column:bucketStart;uniqueIndex" json:"bucketStart"`
+	Samples       int       `gorm:"column:samples" json:"samples"`
+	ScoreMin      float64   `gorm:"column:scoreMin" json:"scoreMin"`
+	ScoreAvg      float64   `gorm:"column:scoreAvg" json:"scoreAvg"`
+	ScoreMax      float64   `gorm:"column:scoreMax" json:"scoreMax"`
+	ScoreP95      float64   `gorm:"column:scoreP95" json:"scoreP95"`
+	CpuMin        float64   `gorm:"column:cpuMin" json:"cpuMin"`
+	CpuAvg        float64   `gorm:"column:cpuAvg" json:"cpuAvg"`
+	CpuMax        float64   `gorm:"column:cpuMax" json:"cpuMax"`
+	CpuP95        float64   `gorm:"column:cpuP95" json:"cpuP95"`
+	MemMin        float64   `gorm:"column:memMin" json:"memMin"`
+	MemAvg        float64   `gorm:"column:memAvg" json:"memAvg"`
+	MemMax        float64   `gorm:"column:memMax" json:"memMax"`
+	MemP95        float64   `gorm:"column:memP95" json:"memP95"`
+	DiskMin       float64   `gorm:"column:diskMin" json:"diskMin"`
+	DiskAvg       float64   `gorm:"column:diskAvg" json:"diskAvg"`
+	DiskMax       float64   `gorm:"column:diskMax" json:"diskMax"`
+	DiskP95       float64   `gorm:"column:diskP95" json:"diskP95"`
+	DbLatencyMin  float64   `gorm:"column:dbLatencyMin" json:"dbLatencyMin"`
+	DbLatencyAvg  float64   `gorm:"column:dbLatencyAvg" json:"dbLatencyAvg"`
+	DbLatencyMax  float64   `gorm:"column:dbLatencyMax" json:"dbLatencyMax"`
+	DbLatencyP95  float64   `gorm:"column:dbLatencyP95" json:"dbLatencyP95"`
+	EndpointMin   float64   `gorm:"column:endpointMin" json:"endpointMin"`
+	EndpointAvg   float64   `gorm:"column:endpointAvg" json:"endpointAvg"`
+	EndpointMax   float64   `gorm:"column:endpointMax" json:"endpointMax"`
+	EndpointP95   float64   `gorm:"column:endpointP95" json:"endpointP95"`
+	ApiMin        float64   `gorm:"column:apiMin" json:"apiMin"`
+	ApiAvg        float64   `gorm:"column:apiAvg" json:"apiAvg"`
+	ApiMax        float64   `gorm:"column:apiMax" json:"apiMax"`
+	ApiP95        float64   `gorm:"column:apiP95" json:"apiP95"`
+	HealthyCount  int       `gorm:"column:healthyCount" json:"healthyCount"`
+	WarningCount  int       `gorm:"column:warningCount" json:"warningCount"`
+	CriticalCount int       `gorm:"column:criticalCount" json:"criticalCount"`
+	AnomalyCount  int       `gorm:"column:anomalyCount" json:"anomalyCount"`
+	// LatencyGoodCount counts samples within the latency SLO target in
+	// force when the bucket was rolled up.
+	LatencyGoodCount int `gorm:"column:latencyGoodCount" json:"latencyGoodCount"`
+}
+
+type HealthRollupHour struct {
+	HealthRollup
+}
+
+func (HealthRollupHour) TableName() string { return "\"HealthRollupHour\"" }
+
+type HealthRollupDay struct {
+	HealthRollup
+}
+
+func (HealthRollupDay) TableName() string { return "\"HealthRollupDay\"" }
+
+// RouteTelemetry is one route's requests in one minute, written when
+// TELEMETRY_PERSIST is on.
+type RouteTelemetry struct {
+	ID           int       `gorm:"column:id;primaryKey" json:"-"`
+	BucketStart  time.Time `gorm:"column:bucketStart;index" json:"bucketStart"`
+	InstanceID   string    `gorm:"column:instanceId;index" json:"instanceId"`
+	Method       string    `gorm:"column:method" json:"method"`
+	Route        string    `gorm:"column:route;index" json:"route"`
+	Count        int       `gorm:"column:count" json:"count"`
+	ClientErrors int       `gorm:"column:clientErrors" json:"clientErrors"`
+	ServerErrors int       `gorm:"column:serverErrors" json:"serverErrors"`
+	AvgMs        float64   `gorm:"column:avgMs" json:"avgMs"`
+	P95Ms        float64   `gorm:"column:p95Ms" json:"p95Ms"`
+	MaxMs        float64   `gorm:"column:maxMs" json:"maxMs"`
+	BytesIn      int64     `gorm:"column:bytesIn" json:"bytesIn"`
+	BytesOut     int64     `gorm:"column:bytesOut" json:"bytesOut"`
+}
+
+func (RouteTelemetry) TableName() string { return "\"RouteTelemetry\"" }
+
+// HealthIncident is a stretch of one instance's samples that were not
+// Healthy. Status is the worst status seen; EndedAt is nil while it lasts.
+// Status Missing, with no InstanceID, is a time no instance sampled at all.
+type HealthIncident struct {
+	ID         int        `gorm:"column:id;primaryKey" json:"id"`
+	InstanceID string     `gorm:"column:instanceId;index" json:"instanceId"`
+	Status     string     `gorm:"column:status" json:"status"`
+	MinScore   int        `gorm:"column:minScore" json:"minScore"`
+	Samples    int        `gorm:"column:samples" json:"samples"`
+	StartedAt  time.Time  `gorm:"column:startedAt;index" json:"startedAt"`
+	LastSeenAt time.Time  `gorm:"column:lastSeenAt" json:"lastSeenAt"`
+	EndedAt    *time.Time `gorm:"column:endedAt;index" json:"endedAt"`
+}
+
+func (HealthIncident) TableName() string { return "\"HealthIncident\"" }
+
+// SyntheticStep is one step of one synthetic journey run. Every step of a
+// pass shares RunAt; Skipped steps never ran because an earlier one failed.
+type SyntheticStep struct {
+	ID         int       `gorm:"column:id;primaryKey" json:"id"`
+	InstanceID string    `gorm:"column:instanceId;index" json:"instanceId"`
+	RunAt      time.Time `gorm:"column:runAt;index" json:"runAt"`
+	Journey    string    `gorm:"column:journey;index" json:"journey"`
+	Step       string    `gorm:"column:step" json:"step"`
+	Seq        int       `gorm:"column:seq" json:"seq"`
+	OK         bool      `gorm:"column:ok" json:"ok"`
+	Skipped    bool      `gorm:"column:skipped" json:"skipped"`
+	StatusCode int       `gorm:"column:statusCode" json:"statusCode"`
+	DurationMs float64   `gorm:"column:durationMs" json:"durationMs"`
+	Error      string    `gorm:"column:error" json:"error,omitempty"`
+}
+
+func (SyntheticStep) TableName() string { return "\"SyntheticStep\"" }
+
+// AlertRule is a condition on the health sample stream. Kind is THRESHOLD
+// (Metric Op Value), RATE (change of Metric over WindowSec, Op Value),
+// HEARTBEAT (no sample for Value seconds) or ANOMALY (the latest sample is
+// anomalous on Metric, or on any metric if Metric is empty). The condition
+// must hold for ForSec before the alert fires. Channels is a JSON array of notifier
+// names; empty means every configured notifier.
+type AlertRule struct {
+	ID         int       `gorm:"column:id;primaryKey" json:"id"`
+	Name       string    `gorm:"column:name" json:"name"`
+	Kind       string    `gorm:"column:kind" json:"kind"`
+	Metric     string    `gorm:"column:metric" json:"metric"`
+	Op         string    `gorm:"column:op" json:"op"`
+	Value      float64   `gorm:"column:value" json:"value"`
+	ForSec     int       `gorm:"column:forSec" json:"forSec"`
+	WindowSec  int       `gorm:"column:windowSec" json:"windowSec"`
+	Severity   string    `gorm:"column:severity" json:"severity"`
+	Enabled    bool      `gorm:"column:enabled" json:"enabled"`
+	Channels   string    `gorm:"column:channels" json:"-"`
+	CreateTime time.Time `gorm:"column:createTime;autoCreateTime" json:"createTime"`
+	UpdateTime time.Time `gorm:"column:updateTime;autoUpdateTime" json:"updateTime"`
+}
+
+func (AlertRule) TableName() string { return "\"AlertRule\"" }
+
+// Alert is one firing episode of a rule, from the moment it fired until it
+// resolved, on the instance whose samples tripped it. Silenced alerts are
+// recorded but nobody is notified.
+type Alert struct {
+	ID         int        `gorm:"column:id;primaryKey" json:"id"`
+	RuleID     int        `gorm:"column:ruleId;index" json:"ruleId"`
+	Instance   string     `gorm:"column:instance;index" json:"instance"`
+	RuleName   string     `gorm:"column:ruleName" json:"ruleName"`
+	Severity   string     `gorm:"column:severity" json:"severity"`
+	State      string     `gorm:"column:state;index" json:"state"`
+	Value      float64    `gorm:"column:value" json:"value"`
+	Message    string     `gorm:"column:message" json:"message"`
+	Silenced   bool       `gorm:"column:silenced" json:"silenced"`
+	StartsAt   time.Time  `gorm:"column:startsAt;index" json:"startsAt"`
+	ResolvedAt *time.Time `gorm:"column:resolvedAt" json:"resolvedAt"`
+}
+
+func (Alert) TableName() string { return "\"Alert\"" }
+
+// AlertSilence mutes notifications for one rule, or every rule when RuleID
+// is nil, between StartsAt and EndsAt.
+type AlertSilence struct {
+	ID         int       `gorm:"column:id;primaryKey" json:"id"`
+	RuleID     *int      `gorm:"column:ruleId" json:"ruleId"`
+	Reason     string    `gorm:"column:reason" json:"reason"`
+	CreatedBy  string    `gorm:"column:createdBy" json:"createdBy"`
+	StartsAt   time.Time `gorm:"column:startsAt" json:"startsAt"`
+	EndsAt     time.Time `gorm:"column:endsAt;index" json:"endsAt"`
+	CreateTime time.Time `gorm:"column:createTime;autoCreateTime" json:"createTime"`
+}
+
+func (AlertSilence) TableName() string { return "\"AlertSilence\"" }
+
+type QuestionMerge struct {
 	ID          int       `gorm:"column:id;primaryKey" json:"id"`
-	Score       int       `gorm:"column:score" json:"score"`
-	Status      string    `gorm:"column:status" json:"status"`
-	CpuUsed     float64   `gorm:"column:cpuUsed" json:"cpuUsed"`
-	MemUsed     float64   `gorm:"column:memUsed" json:"memUsed"`
-	DiskUsed    float64   `gorm:"column:diskUsed" json:"diskUsed"`
-	DbLatencyMs int64     `gorm:"column:dbLatencyMs" json:"dbLatencyMs"`
-	EndpointMs  int64     `gorm:"column:endpointMs" json:"endpointMs"`
+	DuplicateID int       `gorm:"column:duplicateId;uniqueIndex" json:"duplicateId"`
+	CanonicalID int       `gorm:"column:canonicalId;index" json:"canonicalId"`
+	MergedBy    string    `gorm:"column:mergedBy" json:"mergedBy"`
+	Score       float64   `gorm:"column:score" json:"score"`
 	CreateTime  time.Time `gorm:"column:createTime;autoCreateTime" json:"createTime"`
 }
 
-func (HealthSample) TableName() string { return "\"HealthSample\"" }
+func (QuestionMerge) TableName() string { return "\"QuestionMerge\"" }
+
+type QuestionFollow struct {
+	ID         int       `gorm:"column:id;primaryKey" json:"id"`
+	QuestionID int       `gorm:"column:questionId;uniqueIndex:idx_follow_question_user" json:"questionId"`
+	UserID     string    `gorm:"column:userId;uniqueIndex:idx_follow_question_user;index" json:"userId"`
+	CreateTime time.Time `gorm:"column:createTime;autoCreateTime" json:"createTime"`
+}
+
+func (QuestionFollow) TableName() string { return "\"QuestionFollow\"" }
+
+// NotificationPreference holds per-user delivery settings. MutedTypes is a
+// JSON array of notification types the user never wants; Digest is OFF,
+// DAILY or WEEKLY. AnnouncementLocale is NULL until the user picks an
+// announcement language, leaving it to Accept-Language.
+type NotificationPreference struct {
+	UserID             string    `gorm:"column:userId;primaryKey" json:"userId"`
+	MutedTypes         string    `gorm:"column:mutedTypes;default:'[]'" json:"-"`
+	InApp              bool      `gorm:"column:inApp" json:"inApp"`
+	Email              bool      `gorm:"column:email" json:"email"`
+	Digest             string    `gorm:"column:digest;default:'OFF'" json:"digest"`
+	Locale             string    `gorm:"column:locale;default:'zh'" json:"locale"`
+	TimeZone           string    `gorm:"column:timeZone" json:"timeZone"`
+	AnnouncementLocale *string   `gorm:"column:announcementLocale" json:"announcementLocale"`
+	UpdateTime         time.Time `gorm:"column:updateTime;autoUpdateTime" json:"updateTime"`
+}
+
+func (NotificationPreference) TableName() string { return "\"NotificationPreference\"" }
+
+type DigestDelivery struct {
+	ID         int       `gorm:"column:id;primaryKey" json:"id"`
+	UserID     string    `gorm:"column:userId;uniqueIndex:idx_digest_user_period" json:"userId"`
+	Period     string    `gorm:"column:period;uniqueIndex:idx_digest_user_period" json:"period"`
+	PeriodKey  string    `gorm:"column:periodKey;uniqueIndex:idx_digest_user_period" json:"periodKey"`
+	Status     string    `gorm:"column:status" json:"status"`
+	Items      int       `gorm:"column:items" json:"items"`
+	Error      *string   `gorm:"column:error" json:"error"`
+	CreateTime time.Time `gorm:"column:createTime;autoCreateTime" json:"createTime"`
+}
+
+func (DigestDelivery) TableName() string { return "\"DigestDelivery\"" }
+
+// FeedToken is the random secret in a user's announcement feed URLs;
+// replacing it revokes every URL handed out before.
+type FeedToken struct {
+	UserID     string    `gorm:"column:userId;primaryKey" json:"userId"`
+	Token      string    `gorm:"column:token;uniqueIndex" json:"-"`
+	UpdateTime time.Time `gorm:"column:updateTime;autoUpdateTime" json:"updateTime"`
+}
+
+func (FeedToken) TableName() string { return "\"FeedToken\"" }
+
+type Announcement struct {
+	ID           string     `gorm:"column:id;primaryKey" json:"id"`
+	Title        string     `gorm:"column:title" json:"title"`
+	Severity     string     `gorm:"column:severity;index" json:"severity"`
+	Pinned       bool       `gorm:"column:pinned" json:"pinned"`
+	Scope        string     `gorm:"column:scope" json:"scope"`
+	Audience     *string    `gorm:"column:audience" json:"audience"`
+	Publisher    string     `gorm:"column:publisher" json:"publisher"`
+	PublishAt    time.Time  `gorm:"column:publishAt;index:idx_announcement_live,priority:2" json:"publishAt"`
+	ValidFrom    *time.Time `gorm:"column:validFrom" json:"validFrom"`
+	ValidTo      *time.Time `gorm:"column:validTo" json:"validTo"`
+	Markdown     string     `gorm:"column:markdown" json:"markdown"`
+	HTML         string     `gorm:"column:html" json:"html"`
+	Archived     bool       `gorm:"column:archived;index:idx_announcement_live,priority:1" json:"archived"`
+	UnarchivedAt *time.Time `gorm:"column:unarchivedAt" json:"unarchivedAt"`
+	Status       string     `gorm:"column:status;index;default:'PUBLISHED'" json:"status"`
+	ScheduledAt  *time.Time `gorm:"column:scheduledAt" json:"scheduledAt"`
+	SubmittedBy  *string    `gorm:"column:submittedBy" json:"submittedBy"`
+	ApprovedBy   *string    `gorm:"column:approvedBy" json:"approvedBy"`
+	EditedBy     *string    `gorm:"column:editedBy" json:"editedBy"`
+	ReviewNote   *string    `gorm:"column:reviewNote" json:"reviewNote"`
+	DeletedAt    *time.Time `gorm:"column:deletedAt;index" json:"deletedAt"`
+	DeletedBy    *string    `gorm:"column:deletedBy" json:"deletedBy"`
+	Attachments  *string    `gorm:"column:attachments" json:"attachments"`
+	// Locale is the language of Title/Markdown; Translations is a JSON
+	// object of the other languages.
+	Locale       *string   `gorm:"column:locale" json:"locale"`
+	Translations *string   `gorm:"column:translations" json:"translations"`
+	UpdateTime   time.Time `gorm:"column:updateTime;autoUpdateTime" json:"updateTime"`
+}
+
+func (Announcement) TableName() string { return "\"Announcement\"" }
+
+// AnnouncementRevision is a full snapshot of an announcement after one
+// change; Snapshot is the JSON of the announcement at that point.
+type AnnouncementRevision struct {
+	ID             int       `gorm:"column:id;primaryKey" json:"id"`
+	AnnouncementID string    `gorm:"column:announcementId;uniqueIndex:idx_announcement_rev,priority:1" json:"announcementId"`
+	Rev            int       `gorm:"column:rev;uniqueIndex:idx_announcement_rev,priority:2" json:"rev"`
+	Action         string    `gorm:"column:action" json:"action"`
+	Editor         string    `gorm:"column:editor" json:"editor"`
+	Snapshot       string    `gorm:"column:snapshot;type:text" json:"-"`
+	CreateTime     time.Time `gorm:"column:createTime;autoCreateTime" json:"createTime"`
+}
+
+func (AnnouncementRevision) TableName() string { return "\"AnnouncementRevision\"" }
+
+type AnnouncementState struct {
+	UserID         string     `gorm:"column:userId;primaryKey" json:"userId"`
+	AnnouncementID string     `gorm:"column:announcementId;primaryKey;index" json:"announcementId"`
+	Read           bool       `gorm:"column:read" json:"read"`
+	Fav            bool       `gorm:"column:fav" json:"fav"`
+	ReadAt         *time.Time `gorm:"column:readAt" json:"readAt"`
+}
+
+func (AnnouncementState) TableName() string { return "\"AnnouncementState\"" }
+
+type CourseEnrollment struct {
+	CourseID   int       `gorm:"column:courseId;primaryKey" json:"courseId"`
+	UserID     string    `gorm:"column:userId;primaryKey;index" json:"userId"`
+	CreateTime time.Time `gorm:"column:createTime;autoCreateTime" json:"createTime"`
+}
+
+func (CourseEnrollment) TableName() string { return "\"CourseEnrollment\"" }
@@ -5,8 +5,6 @@ import (
 	"fmt"
 	"math"
 	"net/http"
-	"os"
-	"runtime"
 	"scholarhub/backend-go/internal/models"
 	"strconv"
 	"strings"
@@ -16,9 +14,6 @@ import (
 	"log"
 
 	"github.com/gin-gonic/gin"
-	"github.com/shirou/gopsutil/v3/cpu"
-	"github.com/shirou/gopsutil/v3/disk"
-	"github.com/shirou/gopsutil/v3/mem"
 	"github.com/shirou/gopsutil/v3/net"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
@@ -26,13 +21,14 @@ import (
 
 type AdminController struct {
 	db         *gorm.DB
+	noti       *Notifier
 	lastNetIn  uint64
 	lastNetOut uint64
 	lastSample time.Time
 }
 
 func NewAdminController(db *gorm.DB) *AdminController {
-	return &AdminController{db: db}
+	return &AdminController{db: db, noti: NewNotifier(db)}
 }
 
 // --- Helper: Log Action ---
@@ -91,27 +87,7 @@ func (a *AdminController) Stats(c *gin.Context) {
 }
 
 func (a *AdminController) Health(c *gin.Context) {
-	start := time.Now()
-	cpuPerc := 0.0
-	if vals, err := cpu.Percent(time.Millisecond*200, false); err == nil && len(vals) > 0 {
-		cpuPerc = vals[0]
-	}
-	memPerc := 0.0
-	if m, err := mem.VirtualMemory(); err == nil {
-		memPerc = m.UsedPercent
-	}
-	root := "/"
-	if runtime.GOOS == "windows" {
-		drive := os.Getenv("SYSTEMDRIVE")
-		if drive == "" {
-			drive = "C:"
-		}
-		root = drive + "\\"
-	}
-	diskPerc := 0.0
-	if du, err := disk.Usage(root); err == nil && du != nil {
-		diskPerc = du.UsedPercent
-	}
+	r := collectHealth(a.db)
 	var inBps, outBps float64
 	var bytesIn, bytesOut uint64
 	if nstats, err := net.IOCounters(false); err == nil && len(nstats) > 0 {
@@ -128,91 +104,75 @@ func (a *AdminController) Health(c *gin.Context) {
 	a.lastNetIn = bytesIn
 	a.lastNetOut = bytesOut
 	a.lastSample = time.Now()
-	dbStart := time.Now()
-	_ = a.db.Exec("SELECT 1")
-	dbLatency := time.Since(dbStart).Milliseconds()
-	pingURL := os.Getenv("PING_URL")
-	netLatency := int64(0)
-	if pingURL != "" {
-		netLatency = pingLatency(pingURL, 800)
-		if netLatency < 0 {
-			netLatency = 0
-		}
-	}
-	status := "Healthy"
-	totalMs := time.Since(start).Milliseconds()
-	severities := map[string]float64{
-		"cpu":  severityCPU(cpuPerc),
-		"mem":  severityMem(memPerc),
-		"disk": severityDisk(diskPerc, diskClass()),
-		"db":   severityDBLatency(dbLatency),
-		"net":  severityNetworkLatency(netLatency),
-		"svc":  severityServiceMs(totalMs),
-	}
-	baseWeights := map[string]float64{
-		"cpu": 0.22, "mem": 0.22, "disk": 0.20, "db": 0.16, "net": 0.12, "svc": 0.08,
-	}
-	weights := dynamicWeights(baseWeights, severities)
-	score := calcHealthScore(severities, weights)
-	if score < 60 {
-		status = "Critical"
-	} else if score < 80 {
-		status = "Warning"
-	}
+	model := healthScoring.Model()
+	res := model.Score(r)
+	severities, weights := res.Severities, res.Weights
+	penalty := func(k string) float64 { return math.Round(severities[k]*weights[k]*100) / 100 }
+	thresholds := func(k string) map[string]float64 { return model.Components[k].Thresholds }
 	breakdown := gin.H{
-		"cpu":    gin.H{"value": cpuPerc, "severity": severities["cpu"], "weight": weights["cpu"], "penalty": math.Round(severities["cpu"]*weights["cpu"]*100) / 100},
-		"memory": gin.H{"value": memPerc, "severity": severities["mem"], "weight": weights["mem"], "penalty": math.Round(severities["mem"]*weights["mem"]*100) / 100},
+		"cpu":    gin.H{"value": r.CPU, "severity": severities["cpu"], "weight": weights["cpu"], "penalty": penalty("cpu")},
+		"memory": gin.H{"value": r.Mem, "severity": severities["mem"], "weight": weights["mem"], "penalty": penalty("mem")},
 		"disk": gin.H{
-			"value":      diskPerc,
+			"value":      r.Disk,
 			"severity":   severities["disk"],
 			"weight":     weights["disk"],
-			"penalty":    math.Round(severities["disk"]*weights["disk"]*100) / 100,
-			"type":       diskClass(),
-			"thresholds": gin.H{"normal_low": 60, "normal_high": 80, "warn": 90, "crit": 95},
+			"penalty":    penalty("disk"),
+			"type":       r.DiskClass,
+			"thresholds": thresholds("disk"),
 		},
-		"db":  gin.H{"latencyMs": dbLatency, "severity": severities["db"], "weight": weights["db"], "penalty": math.Round(severities["db"]*weights["db"]*100) / 100},
-		"net": gin.H{"latencyMs": netLatency, "severity": severities["net"], "weight": weights["net"], "penalty": math.Round(severities["net"]*weights["net"]*100) / 100},
-		"svc": gin.H{"latencyMs": totalMs, "severity": severities["svc"], "weight": weights["svc"], "penalty": math.Round(severities["svc"]*weights["svc"]*100) / 100},
+		"db":  gin.H{"latencyMs": r.DBMs, "severity": severities["db"], "weight": weights["db"], "penalty": penalty("db")},
+		"net": gin.H{"latencyMs": r.NetMs, "severity": severities["net"], "weight": weights["net"], "penalty": penalty("net")},
+		"svc": gin.H{"latencyMs": r.SvcMs, "severity": severities["svc"], "weight": weights["svc"], "penalty": penalty("svc")},
+		"journeys": gin.H{"failingPercent": r.Journeys, "severity": severities["journeys"], "weight": weights["journeys"],
+			"penalty": penalty("journeys"), "thresholds": thresholds("journeys")},
 	}
 	c.JSON(http.StatusOK, respOk(gin.H{
-		"score":     int(score + 0.5),
-		"status":    status,
+		"score":     int(res.Score + 0.5),
+		"status":    res.Status,
 		"timestamp": time.Now().UTC(),
 		"components": gin.H{
 			"cpu": gin.H{
-				"usedPercent": cpuPerc,
+				"usedPercent": r.CPU,
 				"weight":      weights["cpu"],
-				"thresholds":  gin.H{"warn": 75, "crit": 90},
+				"thresholds":  thresholds("cpu"),
 			},
 			"memory": gin.H{
-				"usedPercent": memPerc,
+				"usedPercent": r.Mem,
 				"weight":      weights["mem"],
-				"thresholds":  gin.H{"warn": 75, "crit": 90},
+				"thresholds":  thresholds("mem"),
 			},
 			"disk": gin.H{
-				"usedPercent": diskPerc,
+				"usedPercent": r.Disk,
 				"weight":      weights["disk"],
-				"type":        diskClass(),
-				"thresholds":  gin.H{"normal_low": 60, "normal_high": 80, "warn": 90, "crit": 95},
+				"type":        r.DiskClass,
+				"thresholds":  thresholds("disk"),
 			},
 			"db": gin.H{
-				"latencyMs":  dbLatency,
+				"latencyMs":  r.DBMs,
 				"weight":     weights["db"],
-				"thresholds": gin.H{"warn": 200, "crit": 1000},
+				"thresholds": thresholds("db"),
 			},
 			"network": gin.H{
 				"inBps":      inBps,
 				"outBps":     outBps,
-				"latencyMs":  netLatency,
+				"latencyMs":  r.NetMs,
 				"weight":     weights["net"],
-				"thresholds": gin.H{"warn": 100, "crit": 200},
+				"thresholds": thresholds("net"),
 			},
 		},
-		"breakdown":  breakdown,
-		"endpointMs": totalMs,
+		"breakdown":    breakdown,
+		"endpointMs":   r.ProbeMs,
+		"apiP95Ms":     r.SvcMs,
+		"modelVersion": healthScoring.info()["version"],
 	}))
-	log.Printf("health status=%s score=%d endpointMs=%d cpu=%.1f mem=%.1f disk=%.1f db=%d",
-		status, int(score+0.5), totalMs, cpuPerc, memPerc, diskPerc, dbLatency)
+	log.Printf("health status=%s score=%d endpointMs=%d apiP95Ms=%d cpu=%.1f mem=%.1f disk=%.1f db=%d",
+		res.Status, int(res.Score+0.5), r.ProbeMs, r.SvcMs, r.CPU, r.Mem, r.Disk, r.DBMs)
+}
+
+// HealthModel shows the scoring model in force, where it came from and
+// whether the last reload failed.
+func (a *AdminController) HealthModel(c *gin.Context) {
+	c.JSON(http.StatusOK, respOk(healthScoring.info()))
 }
 
 func min(a float64, b float64) float64 {
@@ -222,41 +182,131 @@ func min(a float64, b float64) float64 {
 	return b
 }
 
+// HealthTrend returns samples between from and to (epoch ms; to defaults to
+// now, from to days before it, 30 by default). step is raw, hour or day;
+// left out, it is picked from the length of the range. instance narrows
+// it to one instance; without it raw samples of every instance come back
+// tagged and rollups cover the whole cluster. Hourly and daily buckets of
+// one instance are worked out from raw samples, so they only reach back
+// as far as those are kept. anomalies lists the newest 200 anomalous
+// samples in the range whatever the step, for the chart to highlight.
 func (a *AdminController) HealthTrend(c *gin.Context) {
+	to := time.Now()
+	if ms, err := strconv.ParseInt(c.Query("to"), 10, 64); err == nil && ms > 0 {
+		to = time.UnixMilli(ms)
+	}
 	days, _ := strconv.Atoi(c.Query("days"))
 	if days <= 0 {
 		days = 30
 	}
-	start := time.Now().Add(-time.Duration(days) * 24 * time.Hour)
-	var list []models.HealthSample
-	a.db.Where("\"createTime\" >= ?", start).Order("\"createTime\" asc").Find(&list)
-	c.JSON(http.StatusOK, respOk(gin.H{"items": list}))
+	start := to.Add(-time.Duration(days) * 24 * time.Hour)
+	if ms, err := strconv.ParseInt(c.Query("from"), 10, 64); err == nil && ms > 0 {
+		start = time.UnixMilli(ms)
+	}
+	if !start.Before(to) {
+		c.JSON(http.StatusBadRequest, respErr(1002, "invalid_range"))
+		return
+	}
+	instance := c.Query("instance")
+	step := c.DefaultQuery("step", trendStep(to.Sub(start)))
+	var items interface{}
+	var err error
+	switch step {
+	case "raw":
+		if to.Sub(start) > 7*24*time.Hour {
+			c.JSON(http.StatusBadRequest, respErr(1002, "range_too_large"))
+			return
+		}
+		tx := a.db.Where("\"createTime\" >= ? AND \"createTime\" < ?", start, to)
+		if instance != "" {
+			tx = tx.Where("\"instanceId\" = ?", instance)
+		}
+		var list []models.HealthSample
+		err = tx.Order("\"createTime\" asc").Find(&list).Error
+		items = list
+	case "hour", "day":
+		size := rollupHour
+		if step == "day" {
+			size = rollupDay
+		}
+		if instance == "" {
+			items, err = rollupTrend(a.db, size, start, to)
+		} else {
+			items, err = instanceTrend(a.db, size, instance, start, to)
+		}
+	default:
+		c.JSON(http.StatusBadRequest, respErr(1002, "invalid_step"))
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, respErr(1004, "db_error"))
+		return
+	}
+	marks, err := anomaliesBetween(a.db, instance, start, to, 200)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, respErr(1004, "db_error"))
+		return
+	}
+	c.JSON(http.StatusOK, respOk(gin.H{"step": step, "instance": instance, "items": items, "anomalies": marks}))
+}
+
+type instanceStatus struct {
+	InstanceID      string    `json:"instanceId"`
+	Host            string    `json:"host"`
+	Samples24h      int64     `json:"samples24h"`
+	LastHeartbeat   time.Time `json:"lastHeartbeat"`
+	Running         bool      `json:"running"`
+	Completeness24h int       `json:"completeness24h"`
 }
 
+// ServiceStatus reports, per instance, whether its collector is running
+// and how many of the expected samples it wrote in the last 24 hours. The
+// top-level fields are for instance= if given, otherwise for the cluster:
+// running if any collector is, with completeness averaged over instances.
 func (a *AdminController) ServiceStatus(c *gin.Context) {
-	var last models.HealthSample
-	a.db.Order("\"createTime\" desc").Limit(1).Find(&last)
-	ok := false
-	if !last.CreateTime.IsZero() {
-		if time.Since(last.CreateTime) <= 5*time.Second {
-			ok = true
-		}
+	now := time.Now()
+	var rows []struct {
+		InstanceID string
+		Host       string
+		Count      int64
+		Last       time.Time
+	}
+	q := a.db.Model(&models.HealthSample{}).
+		Select("\"instanceId\" AS instance_id, MAX(host) AS host, COUNT(*) AS count, MAX(\"createTime\") AS last").
+		Where("\"createTime\" >= ?", now.Add(-24*time.Hour))
+	if inst := c.Query("instance"); inst != "" {
+		q = q.Where("\"instanceId\" = ?", inst)
+	}
+	if err := q.Group("\"instanceId\"").Order("\"instanceId\" asc").Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, respErr(1004, "db_error"))
+		return
 	}
-	var count24 int64
-	a.db.Model(&models.HealthSample{}).Where("\"createTime\" >= ?", time.Now().Add(-24*time.Hour)).Count(&count24)
-	intervalMs := 30000
-	if os.Getenv("ENV") == "production" || os.Getenv("GIN_MODE") == "release" {
-		intervalMs = 30000
+	interval := healthInterval()
+	expected := float64(24*time.Hour) / float64(interval)
+	instances := make([]instanceStatus, 0, len(rows))
+	running, last, sum := false, time.Time{}, 0
+	for _, r := range rows {
+		st := instanceStatus{InstanceID: r.InstanceID, Host: r.Host, Samples24h: r.Count, LastHeartbeat: r.Last,
+			Running: now.Sub(r.Last) <= 2*interval+5*time.Second}
+		st.Completeness24h = int(minf(float64(r.Count)/expected*100, 100) + 0.5)
+		instances = append(instances, st)
+		running = running || st.Running
+		if r.Last.After(last) {
+			last = r.Last
+		}
+		sum += st.Completeness24h
 	}
-	expected := int64((24 * 60 * 60 * 1000) / intervalMs)
-	completeness := 0.0
-	if expected > 0 {
-		completeness = float64(count24) / float64(expected) * 100
+	completeness := 0
+	if len(instances) > 0 {
+		completeness = int(float64(sum)/float64(len(instances)) + 0.5)
 	}
 	c.JSON(http.StatusOK, respOk(gin.H{
-		"running":         ok,
-		"lastHeartbeat":   last.CreateTime,
-		"completeness24h": int(completeness + 0.5),
+		"running":         running,
+		"lastHeartbeat":   last,
+		"completeness24h": completeness,
+		"instances":       instances,
+		"instance":        InstanceID(),
+		"leader":          isLeader(),
 	}))
 }
 
@@ -271,6 +321,9 @@ func (a *AdminController) HealthSamples(c *gin.Context) {
 	if sinceMs > 0 {
 		tx = tx.Where("\"createTime\" > ?", time.UnixMilli(sinceMs))
 	}
+	if inst := c.Query("instance"); inst != "" {
+		tx = tx.Where("\"instanceId\" = ?", inst)
+	}
 	tx.Limit(limit).Find(&list)
 	version := int64(0)
 	if len(list) > 0 {
@@ -285,8 +338,8 @@ func (a *AdminController) HealthStream(c *gin.Context) {
 	c.Writer.Header().Set("Connection", "keep-alive")
 	uid := c.GetString("user_id")
 	log.Printf("health_stream connect user=%s", uid)
-	ch := SubscribeHealth()
-	defer UnsubscribeHealth(ch)
+	sub := hub.Subscribe(TopicHealth, "")
+	defer hub.Unsubscribe(sub)
 	flusher, ok := c.Writer.(http.Flusher)
 	if !ok {
 		c.Status(http.StatusInternalServerError)
@@ -302,9 +355,8 @@ func (a *AdminController) HealthStream(c *gin.Context) {
 	ctxDone := c.Request.Context().Done()
 	for {
 		select {
-		case s := <-ch:
-			b, _ := json.Marshal(s)
-			if _, err := fmt.Fprintf(c.Writer, "event: sample\ndata: %s\n\n", string(b)); err != nil {
+		case e := <-sub.C:
+			if err := writeSSE(c.Writer, "", e.Name, e.Data); err != nil {
 				log.Printf("health_stream write sample error user=%s err=%v", uid, err)
 				return
 			}
@@ -596,6 +648,14 @@ func (a *AdminController) ListAuditQuestions(c *gin.Context) {
 	c.JSON(http.StatusOK, respOk(gin.H{"items": list, "total": total}))
 }
 
+// questionStatusLabels are the statuses an admin may set, with the wording
+// followers see. MERGED is only set by a merge.
+var questionStatusLabels = map[string]string{
+	"UNANSWERED": "待回答",
+	"ANSWERED":   "已解决",
+	"VIOLATION":  "违规",
+}
+
 func (a *AdminController) AuditQuestion(c *gin.Context) {
 	id := c.Param("id")
 	var req struct {
@@ -605,14 +665,27 @@ func (a *AdminController) AuditQuestion(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, respErr(1002, "bad_request"))
 		return
 	}
-
-	if err := a.db.Model(&models.Question{}).Where("id = ?", id).Update("status", req.Status).Error; err != nil {
+	req.Status = strings.ToUpper(strings.TrimSpace(req.Status))
+	label, ok := questionStatusLabels[req.Status]
+	if !ok {
+		c.JSON(http.StatusBadRequest, respErr(1002, "invalid_status"))
+		return
+	}
+	var q models.Question
+	if err := a.db.First(&q, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, respErr(1006, "not_found"))
+		return
+	}
+	if err := a.db.Model(&models.Question{}).Where("id = ?", q.ID).Update("status", req.Status).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, respErr(1004, "db_error"))
 		return
 	}
 
 	adminID := c.GetString("user_id")
 	a.logAction(adminID, "AUDIT_QUESTION", id, req)
+	if q.Status != req.Status {
+		a.noti.NotifyFollowers(q.ID, NotiStatus, "您关注的问题《"+q.Title+"》状态变更为"+label, adminID)
+	}
 	c.JSON(http.StatusOK, respOk(gin.H{"ok": true}))
 }
 
@@ -642,6 +715,11 @@ func (a *AdminController) AuditAnswer(c *gin.Context) {
 
 	adminID := c.GetString("user_id")
 	a.logAction(adminID, "AUDIT_ANSWER", id, req)
+	var ans models.Answer
+	var q models.Question
+	if req.IsTop != nil && *req.IsTop && a.db.First(&ans, id).Error == nil && a.db.First(&q, ans.QuestionID).Error == nil {
+		a.noti.NotifyFollowers(q.ID, NotiStatus, "您关注的问题《"+q.Title+"》有回答被置顶", adminID)
+	}
 	c.JSON(http.StatusOK, respOk(gin.H{"ok": true}))
 }
 
@@ -0,0 +1,541 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"scholarhub/backend-go/internal/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	AlertThreshold = "THRESHOLD"
+	AlertRate      = "RATE"
+	AlertHeartbeat = "HEARTBEAT"
+	AlertAnomaly   = "ANOMALY"
+
+	AlertFiring   = "FIRING"
+	AlertResolved = "RESOLVED"
+)
+
+// alertMetrics are the HealthSample fields rules can refer to.
+var alertMetrics = map[string]func(models.HealthSample) float64{
+	"score":       func(s models.HealthSample) float64 { return float64(s.Score) },
+	"cpuUsed":     func(s models.HealthSample) float64 { return s.CpuUsed },
+	"memUsed":     func(s models.HealthSample) float64 { return s.MemUsed },
+	"diskUsed":    func(s models.HealthSample) float64 { return s.DiskUsed },
+	"dbLatencyMs": func(s models.HealthSample) float64 { return float64(s.DbLatencyMs) },
+	"endpointMs":  func(s models.HealthSample) float64 { return float64(s.EndpointMs) },
+	"apiP95Ms":    func(s models.HealthSample) float64 { return float64(s.ApiP95Ms) },
+}
+
+func compareOp(v float64, op string, ref float64) bool {
+	switch op {
+	case "<":
+		return v < ref
+	case "<=":
+		return v <= ref
+	case ">":
+		return v > ref
+	case ">=":
+		return v >= ref
+	}
+	return false
+}
+
+// alertTransition is a rule starting or stopping to fire.
+type alertTransition struct {
+	Rule    models.AlertRule
+	Firing  bool
+	Value   float64
+	Message string
+	At      time.Time
+}
+
+// alertEvaluator holds recent samples and rule state and does no I/O.
+// Only the leader fills peers, the other instances' last sample times.
+type alertEvaluator struct {
+	started time.Time
+	samples []models.HealthSample
+	peers   map[string]time.Time
+	pending map[int]time.Time
+	firing  map[int]bool
+}
+
+// alertSampleKeep bounds the sample buffer; RATE windows cannot be longer.
+const alertSampleKeep = 6 * time.Hour
+
+func newAlertEvaluator(now time.Time) *alertEvaluator {
+	return &alertEvaluator{started: now, pending: map[int]time.Time{}, firing: map[int]bool{}}
+}
+
+func (e *alertEvaluator) observe(s models.HealthSample) {
+	e.samples = append(e.samples, s)
+	cut := s.CreateTime.Add(-alertSampleKeep)
+	i := 0
+	for i < len(e.samples) && e.samples[i].CreateTime.Before(cut) {
+		i++
+	}
+	e.samples = e.samples[i:]
+}
+
+// check reports whether r holds at now, with the value and a description.
+func (e *alertEvaluator) check(r models.AlertRule, now time.Time) (bool, float64, string) {
+	if r.Kind == AlertHeartbeat {
+		last := e.started
+		if n := len(e.samples); n > 0 {
+			last = e.samples[n-1].CreateTime
+		}
+		from := ""
+		for inst, t := range e.peers {
+			if t.Before(last) {
+				last, from = t, " from "+inst
+			}
+		}
+		gap := now.Sub(last)
+		return gap.Seconds() > r.Value, gap.Seconds(), fmt.Sprintf("no health sample%s for %s", from, gap.Round(time.Second))
+	}
+	if r.Kind == AlertAnomaly {
+		if len(e.samples) == 0 {
+			return false, 0, ""
+		}
+		for _, a := range e.samples[len(e.samples)-1].Anomalies {
+			if r.Metric == "" || a.Metric == r.Metric {
+				return true, a.Z, a.Message
+			}
+		}
+		return false, 0, ""
+	}
+	get, ok := alertMetrics[r.Metric]
+	if !ok || len(e.samples) == 0 {
+		return false, 0, ""
+	}
+	cur := e.samples[len(e.samples)-1]
+	switch r.Kind {
+	case AlertThreshold:
+		v := get(cur)
+		return compareOp(v, r.Op, r.Value), v, fmt.Sprintf("%s is %g (%s %g)", r.Metric, v, r.Op, r.Value)
+	case AlertRate:
+		window := time.Duration(r.WindowSec) * time.Second
+		var base *models.HealthSample
+		for i := range e.samples {
+			if !e.samples[i].CreateTime.Before(cur.CreateTime.Add(-window)) {
+				base = &e.samples[i]
+				break
+			}
+		}
+		if base == nil || base == &e.samples[len(e.samples)-1] {
+			return false, 0, ""
+		}
+		d := get(cur) - get(*base)
+		return compareOp(d, r.Op, r.Value), d, fmt.Sprintf("%s changed by %g in %s (%s %g)", r.Metric, d, cur.CreateTime.Sub(base.CreateTime).Round(time.Second), r.Op, r.Value)
+	}
+	return false, 0, ""
+}
+
+// evaluate fires rules that have held for ForSec and resolves those that
+// stopped holding; a firing rule produces nothing more.
+func (e *alertEvaluator) evaluate(rules []models.AlertRule, now time.Time) []alertTransition {
+	var out []alertTransition
+	seen := map[int]bool{}
+	for _, r := range rules {
+		if !r.Enabled {
+			continue
+		}
+		seen[r.ID] = true
+		ok, v, msg := e.check(r, now)
+		if !ok {
+			delete(e.pending, r.ID)
+			if e.firing[r.ID] {
+				delete(e.firing, r.ID)
+				out = append(out, alertTransition{Rule: r, Value: v, Message: msg, At: now})
+			}
+			continue
+		}
+		since, waiting := e.pending[r.ID]
+		if !waiting {
+			since = now
+			e.pending[r.ID] = now
+		}
+		if !e.firing[r.ID] && now.Sub(since) >= time.Duration(r.ForSec)*time.Second {
+			e.firing[r.ID] = true
+			out = append(out, alertTransition{Rule: r, Firing: true, Value: v, Message: msg, At: now})
+		}
+	}
+	// Rules deleted or disabled while firing resolve too.
+	for id := range e.firing {
+		if !seen[id] {
+			delete(e.firing, id)
+			delete(e.pending, id)
+			out = append(out, alertTransition{Rule: models.AlertRule{ID: id}, Message: "rule disabled", At: now})
+		}
+	}
+	return out
+}
+
+// AlertNotification is what notifiers deliver, and the webhook body.
+type AlertNotification struct {
+	Rule       string     `json:"rule"`
+	Severity   string     `json:"severity"`
+	State      string     `json:"state"`
+	Value      float64    `json:"value"`
+	Message    string     `json:"message"`
+	StartsAt   time.Time  `json:"startsAt"`
+	ResolvedAt *time.Time `json:"resolvedAt,omitempty"`
+	Instance   string     `json:"instance"`
+}
+
+func (n AlertNotification) title() string {
+	return fmt.Sprintf("[%s] %s: %s", n.State, n.Severity, n.Rule)
+}
+
+type AlertNotifier interface {
+	Notify(n AlertNotification) error
+}
+
+// WebhookNotifier POSTs the notification as JSON to each URL.
+type WebhookNotifier struct {
+	URLs   []string
+	client *http.Client
+}
+
+func (w *WebhookNotifier) Notify(n AlertNotification) error {
+	body, _ := json.Marshal(n)
+	var errs []string
+	for _, u := range w.URLs {
+		resp, err := w.client.Post(u, "application/json", bytes.NewReader(body))
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			errs = append(errs, fmt.Sprintf("%s: status %d", u, resp.StatusCode))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("webhook: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// EmailNotifier mails the notification to fixed addresses.
+type EmailNotifier struct {
+	To   []string
+	mail Mailer
+}
+
+func (e *EmailNotifier) Notify(n AlertNotification) error {
+	text := fmt.Sprintf("%s\n\n%s\nvalue: %g\ninstance: %s\nsince: %s\n", n.title(), n.Message, n.Value, n.Instance, n.StartsAt.Format(time.RFC3339))
+	for _, to := range e.To {
+		if err := e.mail.Send(Mail{To: to, Subject: "[ScholarHub] " + n.title(), Text: text}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InAppNotifier puts the notification in every admin's notification list.
+type InAppNotifier struct {
+	db   *gorm.DB
+	noti *Notifier
+}
+
+func (i *InAppNotifier) Notify(n AlertNotification) error {
+	var ids []string
+	if err := i.db.Model(&models.User{}).Where("role = ?", "ADMIN").Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	for _, uid := range ids {
+		i.noti.Send(uid, NotiAlert, n.title()+" "+n.Message, nil)
+	}
+	return nil
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, x := range strings.Split(s, ",") {
+		if x = strings.TrimSpace(x); x != "" {
+			out = append(out, x)
+		}
+	}
+	return out
+}
+
+// alertNotifiers builds the webhook, email and in-app notifiers.
+func alertNotifiers(db *gorm.DB) map[string]AlertNotifier {
+	out := map[string]AlertNotifier{"inapp": &InAppNotifier{db: db, noti: NewNotifier(db)}}
+	if urls := splitList(os.Getenv("ALERT_WEBHOOK_URLS")); len(urls) > 0 {
+		out["webhook"] = &WebhookNotifier{URLs: urls, client: &http.Client{Timeout: 5 * time.Second}}
+	}
+	if to := splitList(os.Getenv("ALERT_EMAIL_TO")); len(to) > 0 {
+		if m := defaultMailer(); m != nil {
+			out["email"] = &EmailNotifier{To: to, mail: m}
+		}
+	}
+	return out
+}
+
+// defaultAlertRules are created when there are no rules at all.
+func defaultAlertRules() []models.AlertRule {
+	return []models.AlertRule{
+		{Name: "Health critical", Kind: AlertThreshold, Metric: "score", Op: "<", Value: 60, ForSec: 300, Severity: "CRITICAL", Enabled: true, Channels: "[]"},
+		{Name: "Health score falling", Kind: AlertRate, Metric: "score", Op: "<=", Value: -20, WindowSec: 600, Severity: "WARNING", Enabled: true, Channels: "[]"},
+		{Name: "Health collector silent", Kind: AlertHeartbeat, Value: 180, Severity: "CRITICAL", Enabled: true, Channels: "[]"},
+	}
+}
+
+// AlertEngine evaluates the rules on every tick and sample, records
+// alerts and sends notifications.
+type AlertEngine struct {
+	db        *gorm.DB
+	notifiers map[string]AlertNotifier
+	instance  string
+	tick      time.Duration
+
+	mu     sync.Mutex
+	eval   *alertEvaluator
+	active map[int]*models.Alert
+
+	queue  chan alertDelivery
+	stopCh chan struct{}
+	done   chan struct{}
+	once   sync.Once
+}
+
+func NewAlertEngine(db *gorm.DB) *AlertEngine {
+	return &AlertEngine{
+		db:        db,
+		notifiers: alertNotifiers(db),
+		instance:  InstanceID(),
+		tick:      15 * time.Second,
+		eval:      newAlertEvaluator(time.Now()),
+		active:    map[int]*models.Alert{},
+		queue:     make(chan alertDelivery, alertQueueSize),
+		stopCh:    make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+func (e *AlertEngine) Start() {
+	var n int64
+	if err := e.db.Model(&models.AlertRule{}).Count(&n).Error; err == nil && n == 0 {
+		if err := e.db.Create(defaultAlertRules()).Error; err != nil {
+			log.Printf("alert default rules error: %v", err)
+		}
+	}
+	// Alerts left firing by a previous run stay open without notifying again.
+	var open []models.Alert
+	e.db.Where("state = ? AND instance = ?", AlertFiring, e.instance).Find(&open)
+	for i := range open {
+		e.active[open[i].RuleID] = &open[i]
+		e.eval.firing[open[i].RuleID] = true
+		e.eval.pending[open[i].RuleID] = open[i].StartsAt
+	}
+	var workers sync.WaitGroup
+	for i := 0; i < alertNotifyWorkers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			e.deliver()
+		}()
+	}
+	sub := hub.Subscribe(TopicHealth, "")
+	go func() {
+		defer close(e.done)
+		defer workers.Wait()
+		defer hub.Unsubscribe(sub)
+		t := time.NewTicker(e.tick)
+		defer t.Stop()
+		for {
+			select {
+			case ev, ok := <-sub.C:
+				if !ok {
+					return
+				}
+				if ev.Name != "sample" {
+					continue
+				}
+				var s models.HealthSample
+				if json.Unmarshal(ev.Data, &s) == nil {
+					if s.CreateTime.IsZero() {
+						s.CreateTime = time.Now()
+					}
+					e.mu.Lock()
+					e.eval.observe(s)
+					e.mu.Unlock()
+				}
+			case <-t.C:
+			case <-e.stopCh:
+				return
+			}
+			e.step(time.Now())
+		}
+	}()
+}
+
+func (e *AlertEngine) Stop() {
+	e.once.Do(func() { close(e.stopCh) })
+	<-e.done
+}
+
+func (e *AlertEngine) step(now time.Time) {
+	var rules []models.AlertRule
+	if err := e.db.Find(&rules).Error; err != nil {
+		log.Printf("alert rules load error: %v", err)
+		return
+	}
+	var peers map[string]time.Time
+	if isLeader() {
+		peers = e.peerHeartbeats(now)
+	}
+	e.mu.Lock()
+	e.eval.peers = peers
+	trs := e.eval.evaluate(rules, now)
+	e.mu.Unlock()
+	for _, tr := range trs {
+		e.apply(tr)
+	}
+}
+
+// peerHeartbeats returns the other instances' last sample times.
+func (e *AlertEngine) peerHeartbeats(now time.Time) map[string]time.Time {
+	var ids []string
+	if err := e.db.Model(&models.HealthSample{}).Where("\"createTime\" > ? AND \"instanceId\" <> ?", now.Add(-alertSampleKeep), e.instance).
+		Distinct().Pluck("instanceId", &ids).Error; err != nil {
+		log.Printf("alert heartbeat query error: %v", err)
+		return nil
+	}
+	out := map[string]time.Time{}
+	for _, id := range ids {
+		var s models.HealthSample
+		if e.db.Where("\"instanceId\" = ?", id).Order("\"createTime\" DESC").First(&s).Error == nil {
+			out[id] = s.CreateTime
+		}
+	}
+	return out
+}
+
+func (e *AlertEngine) silenced(ruleID int, now time.Time) bool {
+	var n int64
+	e.db.Model(&models.AlertSilence{}).
+		Where("\"startsAt\" <= ? AND \"endsAt\" > ?", now, now).
+		Where("\"ruleId\" IS NULL OR \"ruleId\" = ?", ruleID).Count(&n)
+	return n > 0
+}
+
+// apply runs on the engine goroutine; e.mu guards only the active map.
+func (e *AlertEngine) apply(tr alertTransition) {
+	silenced := e.silenced(tr.Rule.ID, tr.At)
+	if tr.Firing {
+		a := &models.Alert{RuleID: tr.Rule.ID, Instance: e.instance, RuleName: tr.Rule.Name, Severity: tr.Rule.Severity, State: AlertFiring,
+			Value: tr.Value, Message: tr.Message, Silenced: silenced, StartsAt: tr.At}
+		if err := e.db.Create(a).Error; err != nil {
+			log.Printf("alert record error rule=%d err=%v", tr.Rule.ID, err)
+		}
+		e.mu.Lock()
+		e.active[tr.Rule.ID] = a
+		e.mu.Unlock()
+		log.Printf("alert firing rule=%q severity=%s silenced=%v %s", a.RuleName, a.Severity, silenced, a.Message)
+		if !silenced {
+			e.notify(tr.Rule, *a)
+		}
+		return
+	}
+	e.mu.Lock()
+	a, ok := e.active[tr.Rule.ID]
+	delete(e.active, tr.Rule.ID)
+	e.mu.Unlock()
+	if !ok {
+		return
+	}
+	at := tr.At
+	a.State, a.ResolvedAt = AlertResolved, &at
+	if err := e.db.Model(a).Updates(map[string]interface{}{"state": AlertResolved, "resolvedAt": at}).Error; err != nil {
+		log.Printf("alert resolve error id=%d err=%v", a.ID, err)
+	}
+	log.Printf("alert resolved rule=%q after %s", a.RuleName, at.Sub(a.StartsAt).Round(time.Second))
+	// A resolve is only news to whoever heard about the alert.
+	if !a.Silenced && !silenced {
+		if tr.Rule.Name == "" {
+			tr.Rule = models.AlertRule{ID: a.RuleID, Name: a.RuleName, Severity: a.Severity, Channels: "[]"}
+			e.db.First(&tr.Rule, a.RuleID)
+		}
+		e.notify(tr.Rule, *a)
+	}
+}
+
+const (
+	alertQueueSize     = 64
+	alertNotifyWorkers = 2
+)
+
+type alertDelivery struct {
+	channel  string
+	notifier AlertNotifier
+	n        AlertNotification
+}
+
+// notify queues the deliveries, dropping them when the queue is full.
+func (e *AlertEngine) notify(r models.AlertRule, a models.Alert) {
+	n := AlertNotification{Rule: a.RuleName, Severity: a.Severity, State: a.State, Value: a.Value,
+		Message: a.Message, StartsAt: a.StartsAt, ResolvedAt: a.ResolvedAt, Instance: e.instance}
+	var channels []string
+	_ = json.Unmarshal([]byte(r.Channels), &channels)
+	for name, nt := range e.notifiers {
+		if len(channels) > 0 && !containsString(channels, name) {
+			continue
+		}
+		select {
+		case e.queue <- alertDelivery{channel: name, notifier: nt, n: n}:
+		default:
+			log.Printf("alert notify queue full, dropped channel=%s rule=%q", name, n.Rule)
+		}
+	}
+}
+
+func (e *AlertEngine) deliver() {
+	for {
+		select {
+		case d := <-e.queue:
+			if err := d.notifier.Notify(d.n); err != nil {
+				log.Printf("alert notify error channel=%s rule=%q err=%v", d.channel, d.n.Rule, err)
+			}
+		case <-e.stopCh:
+			return
+		}
+	}
+}
+
+// snapshot returns the open alerts and the rules waiting out their ForSec.
+func (e *AlertEngine) snapshot() ([]models.Alert, map[int]time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	active := make([]models.Alert, 0, len(e.active))
+	for _, a := range e.active {
+		active = append(active, *a)
+	}
+	pending := map[int]time.Time{}
+	for id, t := range e.eval.pending {
+		if !e.eval.firing[id] {
+			pending[id] = t
+		}
+	}
+	return active, pending
+}
+
+func (e *AlertEngine) notifierNames() []string {
+	out := make([]string, 0, len(e.notifiers))
+	for name := range e.notifiers {
+		out = append(out, name)
+	}
+	return out
+}
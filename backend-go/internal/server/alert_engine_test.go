@@ -0,0 +1,125 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"scholarhub/backend-go/internal/models"
+)
+
+func TestAlertThresholdWaitsForDurationAndResolves(t *testing.T) {
+	t0 := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	ev := newAlertEvaluator(t0)
+	rules := []models.AlertRule{{ID: 1, Name: "low", Kind: AlertThreshold, Metric: "score", Op: "<", Value: 60, ForSec: 60, Enabled: true}}
+
+	ev.observe(models.HealthSample{Score: 50, CreateTime: t0})
+	if trs := ev.evaluate(rules, t0); len(trs) != 0 {
+		t.Fatalf("fired before the duration elapsed: %+v", trs)
+	}
+	ev.observe(models.HealthSample{Score: 40, CreateTime: t0.Add(60 * time.Second)})
+	trs := ev.evaluate(rules, t0.Add(60*time.Second))
+	if len(trs) != 1 || !trs[0].Firing || trs[0].Value != 40 {
+		t.Fatalf("expected one firing transition, got %+v", trs)
+	}
+	if trs := ev.evaluate(rules, t0.Add(90*time.Second)); len(trs) != 0 {
+		t.Fatalf("a firing rule must not fire again: %+v", trs)
+	}
+	ev.observe(models.HealthSample{Score: 90, CreateTime: t0.Add(120 * time.Second)})
+	trs = ev.evaluate(rules, t0.Add(120*time.Second))
+	if len(trs) != 1 || trs[0].Firing {
+		t.Fatalf("expected a resolve, got %+v", trs)
+	}
+}
+
+func TestAlertRateAndHeartbeat(t *testing.T) {
+	t0 := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	ev := newAlertEvaluator(t0)
+	rules := []models.AlertRule{
+		{ID: 1, Kind: AlertRate, Metric: "score", Op: "<=", Value: -20, WindowSec: 600, Enabled: true},
+		{ID: 2, Kind: AlertHeartbeat, Value: 120, Enabled: true},
+	}
+	ev.observe(models.HealthSample{Score: 95, CreateTime: t0})
+	ev.observe(models.HealthSample{Score: 85, CreateTime: t0.Add(5 * time.Minute)})
+	if trs := ev.evaluate(rules, t0.Add(5*time.Minute)); len(trs) != 0 {
+		t.Fatalf("a 10 point drop should not fire: %+v", trs)
+	}
+	ev.observe(models.HealthSample{Score: 70, CreateTime: t0.Add(8 * time.Minute)})
+	trs := ev.evaluate(rules, t0.Add(8*time.Minute))
+	if len(trs) != 1 || trs[0].Rule.ID != 1 || trs[0].Value != -25 {
+		t.Fatalf("expected the rate rule to fire on -25, got %+v", trs)
+	}
+	trs = ev.evaluate(rules, t0.Add(11*time.Minute))
+	if len(trs) != 1 || trs[0].Rule.ID != 2 || !trs[0].Firing {
+		t.Fatalf("expected the heartbeat rule to fire after 3 silent minutes, got %+v", trs)
+	}
+}
+
+func TestAlertDisabledRuleResolves(t *testing.T) {
+	t0 := time.Now()
+	ev := newAlertEvaluator(t0)
+	r := models.AlertRule{ID: 7, Kind: AlertThreshold, Metric: "cpuUsed", Op: ">", Value: 90, Enabled: true}
+	ev.observe(models.HealthSample{CpuUsed: 99, CreateTime: t0})
+	if trs := ev.evaluate([]models.AlertRule{r}, t0); len(trs) != 1 {
+		t.Fatalf("expected an immediate fire without a duration, got %+v", trs)
+	}
+	r.Enabled = false
+	trs := ev.evaluate([]models.AlertRule{r}, t0.Add(time.Second))
+	if len(trs) != 1 || trs[0].Firing || trs[0].Rule.ID != 7 {
+		t.Fatalf("expected the disabled rule to resolve, got %+v", trs)
+	}
+}
+
+func TestWebhookNotifierPostsJSON(t *testing.T) {
+	var got AlertNotification
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected content type %q", r.Header.Get("Content-Type"))
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+	n := &WebhookNotifier{URLs: []string{srv.URL}, client: srv.Client()}
+	if err := n.Notify(AlertNotification{Rule: "Health critical", Severity: "CRITICAL", State: AlertFiring, Value: 42}); err != nil {
+		t.Fatal(err)
+	}
+	if got.Rule != "Health critical" || got.State != AlertFiring || got.Value != 42 {
+		t.Fatalf("unexpected payload %+v", got)
+	}
+
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(500) }))
+	defer bad.Close()
+	n.URLs = []string{bad.URL}
+	if err := n.Notify(AlertNotification{}); err == nil {
+		t.Fatal("expected an error for a failing endpoint")
+	}
+}
+
+func TestLeaderHeartbeatWatchesOtherInstances(t *testing.T) {
+	db := newTestDB(t, &models.AlertRule{}, &models.Alert{}, &models.AlertSilence{}, &models.HealthSample{})
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	db.Create(&models.AlertRule{ID: 1, Name: "silent", Kind: AlertHeartbeat, Value: 180, Severity: "CRITICAL", Enabled: true, Channels: "[]"})
+	db.Create(&[]models.HealthSample{
+		{InstanceID: "b", Score: 90, CreateTime: now.Add(-10 * time.Minute)},
+		{InstanceID: "c", Score: 90, CreateTime: now.Add(-time.Minute)},
+	})
+	e := &AlertEngine{db: db, notifiers: map[string]AlertNotifier{"inapp": nil}, instance: "a",
+		eval: newAlertEvaluator(now), active: map[int]*models.Alert{}, queue: make(chan alertDelivery, 1)}
+	e.eval.observe(models.HealthSample{InstanceID: "a", CreateTime: now})
+
+	e.step(now)
+	var a models.Alert
+	if err := db.First(&a).Error; err != nil || a.State != AlertFiring || !strings.Contains(a.Message, "from b") {
+		t.Fatalf("the leader should alert on the silent instance, got %+v %v", a, err)
+	}
+	if len(e.queue) != 1 {
+		t.Fatalf("expected the notification to be queued, got %d", len(e.queue))
+	}
+	e.notify(models.AlertRule{Channels: "[]"}, a) // the queue is full: dropped, not blocked
+	if active, _ := e.snapshot(); len(active) != 1 {
+		t.Fatalf("expected one open alert, got %+v", active)
+	}
+}
@@ -0,0 +1,320 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"scholarhub/backend-go/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// AlertsController serves alert state, history, rules and silences under
+// /api/admin/alerts.
+type AlertsController struct {
+	db     *gorm.DB
+	engine *AlertEngine
+}
+
+func NewAlertsController(db *gorm.DB, engine *AlertEngine) *AlertsController {
+	return &AlertsController{db: db, engine: engine}
+}
+
+type alertRuleView struct {
+	models.AlertRule
+	Channels []string `json:"channels"`
+}
+
+func viewRule(r models.AlertRule) alertRuleView {
+	v := alertRuleView{AlertRule: r, Channels: []string{}}
+	_ = json.Unmarshal([]byte(r.Channels), &v.Channels)
+	return v
+}
+
+func (a *AlertsController) logAction(c *gin.Context, actionType, targetID string, details interface{}) {
+	b, _ := json.Marshal(details)
+	s := string(b)
+	a.db.Create(&models.AdminLog{AdminID: c.GetString("user_id"), ActionType: actionType, TargetID: targetID, Details: &s})
+}
+
+// State lists the open alerts and the rules whose condition holds but has
+// not lasted long enough to fire yet.
+func (a *AlertsController) State(c *gin.Context) {
+	active, pending := a.engine.snapshot()
+	type waiting struct {
+		RuleID int       `json:"ruleId"`
+		Since  time.Time `json:"since"`
+	}
+	ws := make([]waiting, 0, len(pending))
+	for id, t := range pending {
+		ws = append(ws, waiting{id, t})
+	}
+	c.JSON(http.StatusOK, respOk(gin.H{"active": active, "pending": ws, "notifiers": a.engine.notifierNames()}))
+}
+
+func (a *AlertsController) History(c *gin.Context) {
+	page, _ := strconv.Atoi(c.Query("page"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(c.Query("pageSize"))
+	if pageSize < 1 || pageSize > 200 {
+		pageSize = 20
+	}
+	tx := a.db.Model(&models.Alert{})
+	if id, err := strconv.Atoi(c.Query("ruleId")); err == nil {
+		tx = tx.Where("\"ruleId\" = ?", id)
+	}
+	if inst := c.Query("instance"); inst != "" {
+		tx = tx.Where("instance = ?", inst)
+	}
+	if st := strings.ToUpper(c.Query("state")); st != "" {
+		tx = tx.Where("state = ?", st)
+	}
+	var total int64
+	tx.Count(&total)
+	var items []models.Alert
+	if err := tx.Order("\"startsAt\" desc").Offset((page - 1) * pageSize).Limit(pageSize).Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, respErr(1004, "db_error"))
+		return
+	}
+	c.JSON(http.StatusOK, respOk(gin.H{"items": items, "total": total}))
+}
+
+func (a *AlertsController) ListRules(c *gin.Context) {
+	var rules []models.AlertRule
+	if err := a.db.Order("id asc").Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, respErr(1004, "db_error"))
+		return
+	}
+	out := make([]alertRuleView, 0, len(rules))
+	for _, r := range rules {
+		out = append(out, viewRule(r))
+	}
+	c.JSON(http.StatusOK, respOk(gin.H{"items": out}))
+}
+
+type alertRuleReq struct {
+	Name      *string   `json:"name"`
+	Kind      *string   `json:"kind"`
+	Metric    *string   `json:"metric"`
+	Op        *string   `json:"op"`
+	Value     *float64  `json:"value"`
+	ForSec    *int      `json:"forSec"`
+	WindowSec *int      `json:"windowSec"`
+	Severity  *string   `json:"severity"`
+	Enabled   *bool     `json:"enabled"`
+	Channels  *[]string `json:"channels"`
+}
+
+// apply copies the set fields onto r and validates the result.
+func (req alertRuleReq) apply(r *models.AlertRule) string {
+	if req.Name != nil {
+		r.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Kind != nil {
+		r.Kind = strings.ToUpper(strings.TrimSpace(*req.Kind))
+	}
+	if req.Metric != nil {
+		r.Metric = strings.TrimSpace(*req.Metric)
+	}
+	if req.Op != nil {
+		r.Op = strings.TrimSpace(*req.Op)
+	}
+	if req.Value != nil {
+		r.Value = *req.Value
+	}
+	if req.ForSec != nil {
+		r.ForSec = *req.ForSec
+	}
+	if req.WindowSec != nil {
+		r.WindowSec = *req.WindowSec
+	}
+	if req.Severity != nil {
+		r.Severity = strings.ToUpper(strings.TrimSpace(*req.Severity))
+	}
+	if req.Enabled != nil {
+		r.Enabled = *req.Enabled
+	}
+	if req.Channels != nil {
+		for _, ch := range *req.Channels {
+			if ch != "webhook" && ch != "email" && ch != "inapp" {
+				return "invalid_channel"
+			}
+		}
+		r.Channels = string(mustJSON(*req.Channels))
+	}
+	if r.Channels == "" {
+		r.Channels = "[]"
+	}
+	if r.Name == "" || len([]rune(r.Name)) > 100 {
+		return "invalid_name"
+	}
+	if r.Severity != "WARNING" && r.Severity != "CRITICAL" {
+		return "invalid_severity"
+	}
+	if r.ForSec < 0 {
+		return "invalid_for"
+	}
+	switch r.Kind {
+	case AlertThreshold, AlertRate:
+		if _, ok := alertMetrics[r.Metric]; !ok {
+			return "invalid_metric"
+		}
+		if r.Op != "<" && r.Op != "<=" && r.Op != ">" && r.Op != ">=" {
+			return "invalid_op"
+		}
+		if r.Kind == AlertRate && (r.WindowSec <= 0 || time.Duration(r.WindowSec)*time.Second > alertSampleKeep) {
+			return "invalid_window"
+		}
+	case AlertAnomaly:
+		if _, ok := alertMetrics[r.Metric]; r.Metric != "" && !ok {
+			return "invalid_metric"
+		}
+	case AlertHeartbeat:
+		if r.Value <= 0 {
+			return "invalid_value"
+		}
+	default:
+		return "invalid_kind"
+	}
+	return ""
+}
+
+func (a *AlertsController) CreateRule(c *gin.Context) {
+	var req alertRuleReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, respErr(1002, "bad_request"))
+		return
+	}
+	r := models.AlertRule{Enabled: true, Severity: "WARNING"}
+	if code := req.apply(&r); code != "" {
+		c.JSON(http.StatusBadRequest, respErr(1002, code))
+		return
+	}
+	if err := a.db.Create(&r).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, respErr(1004, "db_error"))
+		return
+	}
+	a.logAction(c, "CREATE_ALERT_RULE", strconv.Itoa(r.ID), gin.H{"name": r.Name})
+	c.JSON(http.StatusOK, respOk(viewRule(r)))
+}
+
+func (a *AlertsController) UpdateRule(c *gin.Context) {
+	var r models.AlertRule
+	if err := a.db.First(&r, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, respErr(1006, "not_found"))
+		return
+	}
+	var req alertRuleReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, respErr(1002, "bad_request"))
+		return
+	}
+	if code := req.apply(&r); code != "" {
+		c.JSON(http.StatusBadRequest, respErr(1002, code))
+		return
+	}
+	if err := a.db.Save(&r).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, respErr(1004, "db_error"))
+		return
+	}
+	a.logAction(c, "UPDATE_ALERT_RULE", strconv.Itoa(r.ID), gin.H{"name": r.Name})
+	c.JSON(http.StatusOK, respOk(viewRule(r)))
+}
+
+// DeleteRule removes a rule; an alert it had open resolves on the next
+// evaluation.
+func (a *AlertsController) DeleteRule(c *gin.Context) {
+	res := a.db.Where("id = ?", c.Param("id")).Delete(&models.AlertRule{})
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, respErr(1004, "db_error"))
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, respErr(1006, "not_found"))
+		return
+	}
+	a.logAction(c, "DELETE_ALERT_RULE", c.Param("id"), nil)
+	c.JSON(http.StatusOK, respOk(gin.H{"ok": true}))
+}
+
+// ListSilences returns current and upcoming silences; all=1 includes
+// expired ones.
+func (a *AlertsController) ListSilences(c *gin.Context) {
+	tx := a.db.Order("\"endsAt\" desc")
+	if c.Query("all") != "1" {
+		tx = tx.Where("\"endsAt\" > ?", time.Now())
+	}
+	var items []models.AlertSilence
+	if err := tx.Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, respErr(1004, "db_error"))
+		return
+	}
+	c.JSON(http.StatusOK, respOk(gin.H{"items": items}))
+}
+
+// CreateSilence mutes one rule (or all, without ruleId) for minutes, or
+// between startsAt and endsAt given in epoch milliseconds.
+func (a *AlertsController) CreateSilence(c *gin.Context) {
+	var req struct {
+		RuleID   *int   `json:"ruleId"`
+		Reason   string `json:"reason"`
+		Minutes  int    `json:"minutes"`
+		StartsAt *int64 `json:"startsAt"`
+		EndsAt   *int64 `json:"endsAt"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, respErr(1002, "bad_request"))
+		return
+	}
+	now := time.Now()
+	s := models.AlertSilence{RuleID: req.RuleID, Reason: strings.TrimSpace(req.Reason), CreatedBy: c.GetString("user_id"), StartsAt: now}
+	if req.StartsAt != nil {
+		s.StartsAt = time.UnixMilli(*req.StartsAt)
+	}
+	switch {
+	case req.EndsAt != nil:
+		s.EndsAt = time.UnixMilli(*req.EndsAt)
+	case req.Minutes > 0:
+		s.EndsAt = s.StartsAt.Add(time.Duration(req.Minutes) * time.Minute)
+	}
+	if !s.EndsAt.After(s.StartsAt) || !s.EndsAt.After(now) {
+		c.JSON(http.StatusBadRequest, respErr(1002, "invalid_period"))
+		return
+	}
+	if req.RuleID != nil {
+		var n int64
+		a.db.Model(&models.AlertRule{}).Where("id = ?", *req.RuleID).Count(&n)
+		if n == 0 {
+			c.JSON(http.StatusBadRequest, respErr(1002, "invalid_rule"))
+			return
+		}
+	}
+	if err := a.db.Create(&s).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, respErr(1004, "db_error"))
+		return
+	}
+	a.logAction(c, "CREATE_ALERT_SILENCE", strconv.Itoa(s.ID), gin.H{"ruleId": s.RuleID, "endsAt": s.EndsAt, "reason": s.Reason})
+	c.JSON(http.StatusOK, respOk(s))
+}
+
+// ExpireSilence ends a silence now; history keeps it.
+func (a *AlertsController) ExpireSilence(c *gin.Context) {
+	now := time.Now()
+	res := a.db.Model(&models.AlertSilence{}).Where("id = ? AND \"endsAt\" > ?", c.Param("id"), now).Update("endsAt", now)
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, respErr(1004, "db_error"))
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, respErr(1006, "not_found"))
+		return
+	}
+	a.logAction(c, "EXPIRE_ALERT_SILENCE", c.Param("id"), nil)
+	c.JSON(http.StatusOK, respOk(gin.H{"ok": true}))
+}
@@ -0,0 +1,210 @@
+package server
+
+import (
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"scholarhub/backend-go/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type readBucket struct {
+	Time       time.Time `json:"time"`
+	Reads      int       `json:"reads"`
+	Cumulative int       `json:"cumulative"`
+	Rate       float64   `json:"rate"`
+}
+
+// announcementReach works out who an announcement is for and what each of
+// them has done with it: eligible holds every user in the audience, and
+// reads/favs only the eligible users' state.
+type announcementReach struct {
+	eligible map[string]bool
+	reads    []AnnouncementReader
+	favs     int
+}
+
+func (a *AnnouncementsController) reach(af AnnouncementFile) (*announcementReach, error) {
+	r := &announcementReach{eligible: map[string]bool{}}
+	if au, ok := effectiveAudience(af); ok {
+		var ids []string
+		if err := audienceUsers(a.db, au).Pluck("id", &ids).Error; err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			r.eligible[id] = true
+		}
+	}
+	readers, err := a.store.Readers(af.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, x := range readers {
+		if !r.eligible[x.UserID] {
+			continue
+		}
+		if x.Read {
+			r.reads = append(r.reads, x)
+		}
+		if x.Fav {
+			r.favs++
+		}
+	}
+	return r, nil
+}
+
+// readTimeline buckets timed reads from the publish time on, with the
+// cumulative read rate after each bucket.
+func readTimeline(reads []AnnouncementReader, since time.Time, bucket time.Duration, reach int) []readBucket {
+	counts := map[int64]int{}
+	var last int64
+	for _, x := range reads {
+		if x.ReadAt == nil {
+			continue
+		}
+		k := x.ReadAt.Sub(since).Milliseconds() / bucket.Milliseconds()
+		if k < 0 {
+			k = 0
+		}
+		counts[k]++
+		if k > last {
+			last = k
+		}
+	}
+	if len(counts) == 0 {
+		return []readBucket{}
+	}
+	out := make([]readBucket, 0, last+1)
+	cum := 0
+	for k := int64(0); k <= last; k++ {
+		cum += counts[k]
+		b := readBucket{Time: since.Add(time.Duration(k) * bucket), Reads: counts[k], Cumulative: cum}
+		if reach > 0 {
+			b.Rate = float64(cum) / float64(reach)
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+// AdminAnalytics reports reach, reads, favourites and the read rate over
+// time for one announcement. bucket=hour|day picks the timeline
+// resolution; by default it is hourly for the first three days.
+func (a *AnnouncementsController) AdminAnalytics(c *gin.Context) {
+	af, err := a.store.Get(c.Param("id"))
+	if err != nil || af.DeletedAt != nil {
+		c.JSON(404, respErr(1006, "not_found"))
+		return
+	}
+	r, err := a.reach(*af)
+	if err != nil {
+		c.JSON(500, respErr(1004, "db_error"))
+		return
+	}
+	bucket := time.Hour
+	switch strings.ToLower(c.Query("bucket")) {
+	case "day":
+		bucket = 24 * time.Hour
+	case "hour":
+	default:
+		if time.Since(af.PublishAt) > 72*time.Hour {
+			bucket = 24 * time.Hour
+		}
+	}
+	untimed := 0
+	for _, x := range r.reads {
+		if x.ReadAt == nil {
+			untimed++
+		}
+	}
+	reach := len(r.eligible)
+	rate := 0.0
+	if reach > 0 {
+		rate = float64(len(r.reads)) / float64(reach)
+	}
+	c.JSON(200, respOk(gin.H{
+		"id":           af.ID,
+		"title":        af.Title,
+		"publishAt":    af.PublishAt,
+		"reach":        reach,
+		"reads":        len(r.reads),
+		"readRate":     rate,
+		"favorites":    r.favs,
+		"bucket":       bucket.String(),
+		"timeline":     readTimeline(r.reads, af.PublishAt, bucket, reach),
+		"untimedReads": untimed,
+	}))
+}
+
+// AdminUnreadUsers lists the eligible users who have not read the
+// announcement yet, as JSON pages or, with format=csv, as a download.
+func (a *AnnouncementsController) AdminUnreadUsers(c *gin.Context) {
+	af, err := a.store.Get(c.Param("id"))
+	if err != nil || af.DeletedAt != nil {
+		c.JSON(404, respErr(1006, "not_found"))
+		return
+	}
+	r, err := a.reach(*af)
+	if err != nil {
+		c.JSON(500, respErr(1004, "db_error"))
+		return
+	}
+	read := make(map[string]bool, len(r.reads))
+	for _, x := range r.reads {
+		read[x.UserID] = true
+	}
+	unread := make([]models.User, 0, len(r.eligible)-len(read))
+	if au, ok := effectiveAudience(*af); ok {
+		var users []models.User
+		if err := audienceUsers(a.db, au).Select("id, username, fullname, role, email").Order("username").Find(&users).Error; err != nil {
+			c.JSON(500, respErr(1004, "db_error"))
+			return
+		}
+		for _, u := range users {
+			if !read[u.ID] {
+				unread = append(unread, u)
+			}
+		}
+	}
+
+	if strings.EqualFold(c.Query("format"), "csv") {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"announcement-%s-unread.csv\"", af.ID))
+		// BOM so spreadsheet apps pick UTF-8 for Chinese names.
+		_, _ = c.Writer.WriteString("\ufeff")
+		w := csv.NewWriter(c.Writer)
+		_ = w.Write([]string{"id", "username", "fullName", "role", "email"})
+		for _, u := range unread {
+			name := ""
+			if u.FullName != nil {
+				name = *u.FullName
+			}
+			_ = w.Write([]string{u.ID, u.Username, name, u.Role, u.Email})
+		}
+		w.Flush()
+		return
+	}
+
+	page, _ := strconv.Atoi(c.Query("page"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(c.Query("pageSize"))
+	if pageSize < 1 {
+		pageSize = 20
+	}
+	total := len(unread)
+	start := (page - 1) * pageSize
+	if start > total {
+		start = total
+	}
+	end := start + pageSize
+	if end > total {
+		end = total
+	}
+	c.JSON(200, respOk(gin.H{"items": unread[start:end], "total": total}))
+}
@@ -0,0 +1,45 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReadTimeline(t *testing.T) {
+	pub := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time { x := pub.Add(d); return &x }
+	reads := []AnnouncementReader{
+		{UserID: "a", Read: true, ReadAt: at(10 * time.Minute)},
+		{UserID: "b", Read: true, ReadAt: at(20 * time.Minute)},
+		{UserID: "c", Read: true, ReadAt: at(2*time.Hour + time.Minute)},
+		{UserID: "d", Read: true},
+	}
+	tl := readTimeline(reads, pub, time.Hour, 8)
+	if len(tl) != 3 {
+		t.Fatalf("expected 3 hourly buckets, got %+v", tl)
+	}
+	if tl[0].Reads != 2 || tl[1].Reads != 0 || tl[2].Cumulative != 3 || tl[2].Rate != 3.0/8 {
+		t.Fatalf("unexpected timeline %+v", tl)
+	}
+	if !tl[1].Time.Equal(pub.Add(time.Hour)) {
+		t.Fatalf("bucket times should start at publish, got %v", tl[1].Time)
+	}
+}
+
+func TestFileStoreReadersKeepReadTime(t *testing.T) {
+	store := newTestStore(t)
+	_ = store.MarkRead("u1", []string{"a1"})
+	_ = store.SetFavorite("u2", "a1", true)
+	readers, _ := store.Readers("a1")
+	if len(readers) != 2 {
+		t.Fatalf("expected 2 readers, got %+v", readers)
+	}
+	for _, r := range readers {
+		if r.UserID == "u1" && (!r.Read || r.ReadAt == nil) {
+			t.Fatalf("u1 should be read with a timestamp: %+v", r)
+		}
+		if r.UserID == "u2" && (r.Read || !r.Fav) {
+			t.Fatalf("u2 should be favourite only: %+v", r)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package server
+
+import (
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// archiveRetentionDays is ANNOUNCE_ARCHIVE_DAYS (default 90); 0 turns
+// archiving off.
+func archiveRetentionDays() int {
+	if v, err := strconv.Atoi(os.Getenv("ANNOUNCE_ARCHIVE_DAYS")); err == nil && v >= 0 {
+		return v
+	}
+	return 90
+}
+
+// retentionStart is when an announcement's retention period began.
+func retentionStart(af AnnouncementFile) time.Time {
+	if af.UnarchivedAt != nil && af.UnarchivedAt.After(af.PublishAt) {
+		return *af.UnarchivedAt
+	}
+	return af.PublishAt
+}
+
+// AnnouncementArchiver archives expired announcements and sweeps
+// attachments every interval, on the leader only.
+type AnnouncementArchiver struct {
+	ann      *AnnouncementsController
+	interval time.Duration
+	stopCh   chan struct{}
+	done     chan struct{}
+	once     sync.Once
+}
+
+func NewAnnouncementArchiver(ann *AnnouncementsController) *AnnouncementArchiver {
+	return &AnnouncementArchiver{ann: ann, interval: time.Hour, stopCh: make(chan struct{}), done: make(chan struct{})}
+}
+
+func (w *AnnouncementArchiver) Start() {
+	if w.ann.archiveDays == 0 {
+		log.Printf("announcement archiving disabled: ANNOUNCE_ARCHIVE_DAYS=0")
+	}
+	go func() {
+		defer close(w.done)
+		t := time.NewTicker(w.interval)
+		defer t.Stop()
+		for {
+			if isLeader() {
+				w.RunOnce(time.Now())
+			}
+			select {
+			case <-t.C:
+			case <-w.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the loop and waits for a running pass.
+func (w *AnnouncementArchiver) Stop() {
+	w.once.Do(func() { close(w.stopCh) })
+	<-w.done
+}
+
+// RunOnce archives what is due and returns how many.
+func (w *AnnouncementArchiver) RunOnce(now time.Time) int {
+	n := 0
+	if days := w.ann.archiveDays; days > 0 {
+		var err error
+		n, err = w.ann.store.ArchiveBefore(now.AddDate(0, 0, -days))
+		if err != nil {
+			log.Printf("announcement archive error: %v", err)
+		}
+	}
+	w.ann.sweepAttachments(now)
+	return n
+}
+
+// AdminArchived lists archived announcements by q and severity.
+func (a *AnnouncementsController) AdminArchived(c *gin.Context) {
+	page, _ := strconv.Atoi(c.Query("page"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(c.Query("pageSize"))
+	if pageSize < 1 {
+		pageSize = 10
+	}
+	items, err := a.store.List(AnnouncementFilter{
+		Archived: true,
+		Severity: strings.ToUpper(strings.TrimSpace(c.Query("severity"))),
+		Query:    strings.TrimSpace(c.Query("q")),
+	})
+	if err != nil {
+		c.JSON(500, respErr(1004, "db_error"))
+		return
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].PublishAt.After(items[j].PublishAt) })
+	total := len(items)
+	start := (page - 1) * pageSize
+	if start > total {
+		start = total
+	}
+	end := start + pageSize
+	if end > total {
+		end = total
+	}
+	c.JSON(200, respOk(gin.H{"items": items[start:end], "total": total, "retentionDays": a.archiveDays}))
+}
+
+// AdminArchivePolicy reports the retention period in force.
+func (a *AnnouncementsController) AdminArchivePolicy(c *gin.Context) {
+	c.JSON(200, respOk(gin.H{"retentionDays": a.archiveDays, "enabled": a.archiveDays > 0}))
+}
+
+// AdminUnarchive restores an item and restarts its retention period.
+func (a *AnnouncementsController) AdminUnarchive(c *gin.Context) {
+	af, err := a.store.Get(c.Param("id"))
+	if err != nil || af.DeletedAt != nil {
+		c.JSON(404, respErr(1006, "not_found"))
+		return
+	}
+	if !af.Archived {
+		c.JSON(409, respErr(1003, "not_archived"))
+		return
+	}
+	prev := *af
+	now := time.Now().UTC()
+	af.Archived = false
+	af.UnarchivedAt = &now
+	if err := a.store.Save(*af); err != nil {
+		c.JSON(500, respErr(1004, "write_error"))
+		return
+	}
+	uid := c.GetString("user_id")
+	a.record(&prev, *af, uid, "UNARCHIVE")
+	a.logAction(uid, "UNARCHIVE_ANNOUNCEMENT", af.ID, gin.H{"title": af.Title})
+	c.JSON(200, respOk(af))
+}
+
+// AdminPurgeArchived deletes an archived announcement for good.
+func (a *AnnouncementsController) AdminPurgeArchived(c *gin.Context) {
+	id := c.Param("id")
+	af, err := a.store.Get(id)
+	if err != nil || !af.Archived {
+		c.JSON(404, respErr(1006, "not_found"))
+		return
+	}
+	if err := a.purge(id); err != nil {
+		c.JSON(500, respErr(1004, "delete_error"))
+		return
+	}
+	a.logAction(c.GetString("user_id"), "PURGE_ANNOUNCEMENT", id, gin.H{"title": af.Title, "archived": true})
+	c.JSON(200, respOk(gin.H{"ok": true}))
+}
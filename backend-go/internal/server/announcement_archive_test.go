@@ -0,0 +1,58 @@
+package server
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestArchiverRespectsRetentionAndUnarchive(t *testing.T) {
+	t.Setenv("ANNOUNCE_ATTACH_DIR", t.TempDir())
+	// A base under a directory named "archive" used to hide every item.
+	store := NewFileAnnouncementStore(filepath.Join(t.TempDir(), "archive", "announcements"), nil)
+	now := time.Now().UTC()
+	_ = store.Save(AnnouncementFile{ID: "old", Title: "Exam timetable", Markdown: "room 101", PublishAt: now.AddDate(0, 0, -40)})
+	_ = store.Save(AnnouncementFile{ID: "draft", Title: "Draft", Status: StatusDraft, PublishAt: now.AddDate(0, 0, -40)})
+	_ = store.Save(AnnouncementFile{ID: "new", Title: "Library hours", PublishAt: now.AddDate(0, 0, -5)})
+
+	a := &AnnouncementsController{store: store, archiveDays: 30}
+	w := NewAnnouncementArchiver(a)
+	if n := w.RunOnce(now); n != 1 {
+		t.Fatalf("expected only the old published item archived, got %d", n)
+	}
+	got, _ := store.List(AnnouncementFilter{Archived: true, Query: "ROOM"})
+	if len(got) != 1 || got[0].ID != "old" {
+		t.Fatalf("expected search to find the archived item by body, got %+v", got)
+	}
+
+	af, _ := store.Get("old")
+	back := now.AddDate(0, 0, -1)
+	af.Archived, af.UnarchivedAt = false, &back
+	_ = store.Save(*af)
+	if n := w.RunOnce(now); n != 0 {
+		t.Fatalf("an unarchived item must get a fresh retention period, archived %d", n)
+	}
+	if n := w.RunOnce(now.AddDate(0, 0, 30)); n != 2 {
+		t.Fatalf("expected both published items archived a month later, got %d", n)
+	}
+
+	a.archiveDays = 0
+	_ = store.Save(AnnouncementFile{ID: "older", Title: "x", PublishAt: now.AddDate(-1, 0, 0)})
+	if n := w.RunOnce(now); n != 0 {
+		t.Fatalf("archiving must be off with zero retention, archived %d", n)
+	}
+}
+
+func TestArchiverStopWaitsForLoop(t *testing.T) {
+	t.Setenv("ANNOUNCE_ATTACH_DIR", t.TempDir())
+	a := &AnnouncementsController{store: newTestStore(t), archiveDays: 30}
+	w := NewAnnouncementArchiver(a)
+	w.Start()
+	done := make(chan struct{})
+	go func() { w.Stop(); w.Stop(); close(done) }()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Stop did not return")
+	}
+}
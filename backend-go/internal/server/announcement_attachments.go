@@ -0,0 +1,302 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AnnouncementAttachment is a file kept under attachmentDir(), which
+// /uploads does not serve.
+type AnnouncementAttachment struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Size       int64     `json:"size"`
+	Type       string    `json:"type"`
+	Inline     bool      `json:"inline"`
+	CreateTime time.Time `json:"createTime"`
+	URL        string    `json:"url,omitempty"`
+}
+
+const attachmentURLTTL = 12 * time.Hour
+
+// attachmentRef matches relative attachment links, e.g. ![plan](attachments/123).
+var attachmentRef = regexp.MustCompile(`(src|href)="attachments/([0-9]+)"`)
+
+// attachmentSubdir holds attachments inside the upload directory.
+const attachmentSubdir = "announcements"
+
+// attachmentDir is ANNOUNCE_ATTACH_DIR, default <upload dir>/announcements.
+func attachmentDir() string {
+	if d := os.Getenv("ANNOUNCE_ATTACH_DIR"); d != "" {
+		return d
+	}
+	return filepath.Join(uploadDir(), attachmentSubdir)
+}
+
+func attachmentMaxBytes() int64 {
+	if v, err := strconv.Atoi(os.Getenv("ANNOUNCE_ATTACH_MAX_MB")); err == nil && v > 0 {
+		return int64(v) << 20
+	}
+	return 20 << 20
+}
+
+func attachmentSig(annID, attID, uid string, exp int64) string {
+	mac := hmac.New(sha256.New, []byte(os.Getenv("JWT_SECRET")))
+	fmt.Fprintf(mac, "announcement-attachment:%s:%s:%s:%d", annID, attID, uid, exp)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// signedAttachmentURL links to the file under api. The expiry is rounded
+// so the URL stays stable for a while.
+func signedAttachmentURL(api, annID, attID, uid string, now time.Time) string {
+	exp := now.Truncate(attachmentURLTTL).Add(2 * attachmentURLTTL).Unix()
+	q := url.Values{}
+	q.Set("uid", uid)
+	q.Set("exp", strconv.FormatInt(exp, 10))
+	q.Set("sig", attachmentSig(annID, attID, uid, exp))
+	return api + "/announcements/" + annID + "/attachments/" + attID + "?" + q.Encode()
+}
+
+// signAttachments signs every attachment URL for uid, who must be allowed
+// to see af.
+func signAttachments(af *AnnouncementFile, uid, api string) {
+	if len(af.Attachments) == 0 {
+		return
+	}
+	now := time.Now()
+	urls := make(map[string]string, len(af.Attachments))
+	for i := range af.Attachments {
+		u := signedAttachmentURL(api, af.ID, af.Attachments[i].ID, uid, now)
+		af.Attachments[i].URL = u
+		urls[af.Attachments[i].ID] = u
+	}
+	af.HTML = attachmentRef.ReplaceAllStringFunc(af.HTML, func(m string) string {
+		sub := attachmentRef.FindStringSubmatch(m)
+		u, ok := urls[sub[2]]
+		if !ok {
+			return m
+		}
+		return sub[1] + `="` + strings.ReplaceAll(u, "&", "&amp;") + `"`
+	})
+}
+
+func findAttachment(af *AnnouncementFile, attID string) *AnnouncementAttachment {
+	for i := range af.Attachments {
+		if af.Attachments[i].ID == attID {
+			return &af.Attachments[i]
+		}
+	}
+	return nil
+}
+
+func serveAttachment(c *gin.Context, annID string, att *AnnouncementAttachment) {
+	path := filepath.Join(attachmentDir(), annID, att.ID)
+	if _, err := os.Stat(path); err != nil {
+		c.JSON(404, respErr(1006, "not_found"))
+		return
+	}
+	disp := "attachment"
+	if att.Inline {
+		disp = "inline"
+	}
+	c.Header("Content-Type", att.Type)
+	c.Header("Content-Disposition", mime.FormatMediaType(disp, map[string]string{"filename": att.Name}))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.File(path)
+}
+
+// AdminAddAttachment stores a file; inline=1 requires an image.
+func (a *AnnouncementsController) AdminAddAttachment(c *gin.Context) {
+	af, err := a.store.Get(c.Param("id"))
+	if err != nil || af.DeletedAt != nil {
+		c.JSON(404, respErr(1006, "not_found"))
+		return
+	}
+	f, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(400, respErr(1002, "bad_request"))
+		return
+	}
+	if f.Size > attachmentMaxBytes() {
+		c.JSON(400, respErr(1002, "file_too_large"))
+		return
+	}
+	src, err := f.Open()
+	if err != nil {
+		c.JSON(400, respErr(1002, "bad_request"))
+		return
+	}
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(src, head)
+	src.Close()
+	// Sniffed, so SVG and HTML can never be served inline.
+	typ := http.DetectContentType(head[:n])
+	inline := c.PostForm("inline") == "1" || c.PostForm("inline") == "true"
+	if inline && !strings.HasPrefix(typ, "image/") {
+		c.JSON(400, respErr(1002, "not_an_image"))
+		return
+	}
+	att := AnnouncementAttachment{ID: genID(), Name: filepath.Base(f.Filename), Size: f.Size, Type: typ, Inline: inline, CreateTime: time.Now().UTC()}
+	dir := filepath.Join(attachmentDir(), af.ID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		c.JSON(500, respErr(1004, "save_error"))
+		return
+	}
+	if err := c.SaveUploadedFile(f, filepath.Join(dir, att.ID)); err != nil {
+		c.JSON(500, respErr(1004, "save_error"))
+		return
+	}
+	prev := *af
+	af.Attachments = append(af.Attachments, att)
+	if err := a.store.Save(*af); err != nil {
+		_ = os.Remove(filepath.Join(dir, att.ID))
+		c.JSON(500, respErr(1004, "write_error"))
+		return
+	}
+	uid := c.GetString("user_id")
+	a.record(&prev, *af, uid, "ATTACH")
+	a.logAction(uid, "ATTACH_ANNOUNCEMENT", af.ID, gin.H{"attachment": att.ID, "name": att.Name})
+	md := fmt.Sprintf("[%s](attachments/%s)", att.Name, att.ID)
+	if inline {
+		md = "!" + md
+	}
+	c.JSON(200, respOk(gin.H{"attachment": att, "markdown": md}))
+}
+
+func (a *AnnouncementsController) AdminRemoveAttachment(c *gin.Context) {
+	af, err := a.store.Get(c.Param("id"))
+	if err != nil || af.DeletedAt != nil {
+		c.JSON(404, respErr(1006, "not_found"))
+		return
+	}
+	attID := c.Param("aid")
+	prev := *af
+	kept := make([]AnnouncementAttachment, 0, len(af.Attachments))
+	for _, x := range af.Attachments {
+		if x.ID != attID {
+			kept = append(kept, x)
+		}
+	}
+	if len(kept) == len(af.Attachments) {
+		c.JSON(404, respErr(1006, "not_found"))
+		return
+	}
+	af.Attachments = kept
+	if err := a.store.Save(*af); err != nil {
+		c.JSON(500, respErr(1004, "write_error"))
+		return
+	}
+	_ = os.Remove(filepath.Join(attachmentDir(), af.ID, attID))
+	uid := c.GetString("user_id")
+	a.record(&prev, *af, uid, "DETACH")
+	a.logAction(uid, "DETACH_ANNOUNCEMENT", af.ID, gin.H{"attachment": attID})
+	c.JSON(200, respOk(gin.H{"ok": true}))
+}
+
+// AdminDownloadAttachment serves any attachment to admins.
+func (a *AnnouncementsController) AdminDownloadAttachment(c *gin.Context) {
+	af, err := a.store.Get(c.Param("id"))
+	if err != nil {
+		c.JSON(404, respErr(1006, "not_found"))
+		return
+	}
+	att := findAttachment(af, c.Param("aid"))
+	if att == nil {
+		c.JSON(404, respErr(1006, "not_found"))
+		return
+	}
+	serveAttachment(c, af.ID, att)
+}
+
+// PublicAttachment serves a signed URL while the announcement is still
+// visible to its user.
+func (a *AnnouncementsController) PublicAttachment(c *gin.Context) {
+	annID, attID := c.Param("id"), c.Param("aid")
+	uid := c.Query("uid")
+	exp, err := strconv.ParseInt(c.Query("exp"), 10, 64)
+	if err != nil || time.Now().Unix() > exp ||
+		!hmac.Equal([]byte(c.Query("sig")), []byte(attachmentSig(annID, attID, uid, exp))) {
+		c.JSON(403, respErr(1007, "forbidden"))
+		return
+	}
+	af, err := a.store.Get(annID)
+	if err != nil || !isVisibleTo(*af, time.Now(), loadViewer(a.db, uid, "")) {
+		c.JSON(404, respErr(1006, "not_found"))
+		return
+	}
+	att := findAttachment(af, attID)
+	if att == nil {
+		c.JSON(404, respErr(1006, "not_found"))
+		return
+	}
+	serveAttachment(c, af.ID, att)
+}
+
+// sweepAttachments removes files no announcement lists, including those of
+// purged ones. It never changes announcements: a listed file may live on
+// another replica's disk. Files younger than an hour may still be being
+// recorded and are left alone.
+func (a *AnnouncementsController) sweepAttachments(now time.Time) int {
+	live, err := a.store.List(AnnouncementFilter{})
+	if err != nil {
+		log.Printf("attachment sweep list error: %v", err)
+		return 0
+	}
+	trashed, err := a.store.List(AnnouncementFilter{Trashed: true})
+	if err != nil {
+		log.Printf("attachment sweep list error: %v", err)
+		return 0
+	}
+	keep := map[string]map[string]bool{}
+	for _, af := range append(live, trashed...) {
+		ids := map[string]bool{}
+		for _, x := range af.Attachments {
+			ids[x.ID] = true
+		}
+		keep[af.ID] = ids
+	}
+	root := attachmentDir()
+	dirs, err := os.ReadDir(root)
+	if err != nil {
+		return 0
+	}
+	removed := 0
+	cutoff := now.Add(-time.Hour)
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		ids := keep[d.Name()]
+		files, _ := os.ReadDir(filepath.Join(root, d.Name()))
+		for _, f := range files {
+			if ids[f.Name()] {
+				continue
+			}
+			if info, err := f.Info(); err != nil || info.ModTime().After(cutoff) {
+				continue
+			}
+			if os.Remove(filepath.Join(root, d.Name(), f.Name())) == nil {
+				removed++
+			}
+		}
+		if len(ids) == 0 {
+			_ = os.Remove(filepath.Join(root, d.Name()))
+		}
+	}
+	return removed
+}
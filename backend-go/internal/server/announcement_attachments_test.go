@@ -0,0 +1,127 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"scholarhub/backend-go/internal/models"
+)
+
+func TestSignAttachmentsRewritesInlineReferences(t *testing.T) {
+	af := AnnouncementFile{
+		ID:          "a1",
+		HTML:        renderMarkdown("![plan](attachments/42) [pdf](attachments/43) [other](attachments/99)"),
+		Attachments: []AnnouncementAttachment{{ID: "42", Inline: true}, {ID: "43"}},
+	}
+	signAttachments(&af, "u1", "/api")
+	if !strings.Contains(af.HTML, `src="/api/announcements/a1/attachments/42?`) ||
+		!strings.Contains(af.HTML, `href="/api/announcements/a1/attachments/43?`) {
+		t.Fatalf("references not rewritten: %s", af.HTML)
+	}
+	if !strings.Contains(af.HTML, `href="attachments/99"`) {
+		t.Fatalf("unknown attachment should be left alone: %s", af.HTML)
+	}
+	if af.Attachments[1].URL == "" || !strings.Contains(af.Attachments[1].URL, "uid=u1") {
+		t.Fatalf("missing signed url: %+v", af.Attachments[1])
+	}
+	exp := time.Now().Add(time.Hour).Unix()
+	if attachmentSig("a1", "42", "u1", exp) == attachmentSig("a1", "42", "u2", exp) {
+		t.Fatalf("signature must bind the user")
+	}
+}
+
+func TestSweepAttachments(t *testing.T) {
+	base := t.TempDir()
+	t.Setenv("ANNOUNCE_ATTACH_DIR", filepath.Join(base, "files"))
+	store := NewFileAnnouncementStore(filepath.Join(base, "data"), nil)
+	a := &AnnouncementsController{store: store}
+	now := time.Now().UTC()
+	_ = store.Save(AnnouncementFile{ID: "live", PublishAt: now, Attachments: []AnnouncementAttachment{{ID: "1"}, {ID: "lost"}}})
+	_ = store.Save(AnnouncementFile{ID: "old", PublishAt: now, Archived: true, Attachments: []AnnouncementAttachment{{ID: "2"}}})
+	for _, p := range []string{"live/1", "live/stray", "old/2", "gone/3"} {
+		path := filepath.Join(attachmentDir(), p)
+		_ = os.MkdirAll(filepath.Dir(path), 0755)
+		_ = os.WriteFile(path, []byte("x"), 0644)
+		_ = os.Chtimes(path, now.Add(-2*time.Hour), now.Add(-2*time.Hour))
+	}
+	if n := a.sweepAttachments(now); n != 2 {
+		t.Fatalf("expected the stray and purged files removed, got %d", n)
+	}
+	for _, p := range []string{"live/1", "old/2"} {
+		if _, err := os.Stat(filepath.Join(attachmentDir(), p)); err != nil {
+			t.Fatalf("listed attachment %s removed: %v", p, err)
+		}
+	}
+	if af, _ := store.Get("old"); len(af.Attachments) != 1 {
+		t.Fatalf("archived announcement should keep its attachments, got %+v", af.Attachments)
+	}
+	af, _ := store.Get("live")
+	revs, _ := store.Revisions("live")
+	if len(af.Attachments) != 2 || len(revs) != 0 {
+		t.Fatalf("the sweep must not change announcements, got %+v %+v", af.Attachments, revs)
+	}
+
+	if err := a.purge("old"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(attachmentDir(), "old")); !os.IsNotExist(err) {
+		t.Fatalf("purging should remove the archived files, got %v", err)
+	}
+}
+
+func TestUploadsDoNotServeAttachments(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("UPLOAD_DIR", t.TempDir())
+	t.Setenv("ANNOUNCE_ATTACH_DIR", "")
+	for _, p := range []string{filepath.Join(uploadDir(), "notes.txt"), filepath.Join(attachmentDir(), "a1", "1")} {
+		_ = os.MkdirAll(filepath.Dir(p), 0755)
+		_ = os.WriteFile(p, []byte("x"), 0644)
+	}
+	r := gin.New()
+	RegisterStatic(r)
+	for p, want := range map[string]int{
+		"/uploads/notes.txt":                   200,
+		"/uploads/announcements/a1/1":          404,
+		"/uploads/announcements/":              404,
+		"/uploads/other/../announcements/a1/1": 404,
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, p, nil))
+		if w.Code != want {
+			t.Fatalf("GET %s: want %d, got %d", p, want, w.Code)
+		}
+	}
+}
+
+func TestPublicAttachmentFollowsVisibility(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("ANNOUNCE_ATTACH_DIR", t.TempDir())
+	t.Setenv("JWT_SECRET", "s3cret")
+	now := time.Now().UTC()
+	past := now.Add(-time.Hour)
+	store := newTestStore(t,
+		AnnouncementFile{ID: "live", PublishAt: past, Attachments: []AnnouncementAttachment{{ID: "1"}}},
+		AnnouncementFile{ID: "archived", PublishAt: past, Archived: true, Attachments: []AnnouncementAttachment{{ID: "1"}}},
+		AnnouncementFile{ID: "expired", PublishAt: past, ValidTo: &past, Attachments: []AnnouncementAttachment{{ID: "1"}}},
+	)
+	a := &AnnouncementsController{db: newTestDB(t, &models.User{}, &models.Course{}, &models.CourseEnrollment{}), store: store}
+	r := gin.New()
+	r.GET("/api/announcements/:id/attachments/:aid", a.PublicAttachment)
+	for id, want := range map[string]int{"live": 200, "archived": 404, "expired": 404} {
+		p := filepath.Join(attachmentDir(), id, "1")
+		_ = os.MkdirAll(filepath.Dir(p), 0755)
+		_ = os.WriteFile(p, []byte("x"), 0644)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, signedAttachmentURL("/api", id, "1", "u1", now), nil))
+		if w.Code != want {
+			t.Fatalf("%s: a link signed earlier should answer %d, got %d", id, want, w.Code)
+		}
+	}
+}
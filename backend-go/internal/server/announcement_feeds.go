@@ -0,0 +1,305 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"scholarhub/backend-go/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+)
+
+const feedMaxEntries = 50
+
+// feedToken returns uid's feed secret, creating it on first use.
+func (a *AnnouncementsController) feedToken(uid string) (string, error) {
+	row := models.FeedToken{UserID: uid, Token: newFeedToken()}
+	if err := a.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&row).Error; err != nil {
+		return "", err
+	}
+	if err := a.db.First(&row, "\"userId\" = ?", uid).Error; err != nil {
+		return "", err
+	}
+	return row.Token, nil
+}
+
+func newFeedToken() string {
+	b := make([]byte, 24)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func feedURL(kind, uid, token string) string {
+	return publicAPIURL(publicBaseURL()) + "/announcements/feed." + kind + "?uid=" + url.QueryEscape(uid) + "&token=" + token
+}
+
+func feedLinks(uid, token string) gin.H {
+	return gin.H{"atom": feedURL("atom", uid, token), "ics": feedURL("ics", uid, token)}
+}
+
+// PublicFeedLinks returns the caller's personal Atom and iCalendar URLs.
+func (a *AnnouncementsController) PublicFeedLinks(c *gin.Context) {
+	uid := c.GetString("user_id")
+	if uid == "" {
+		c.JSON(401, respErr(1001, "unauthorized"))
+		return
+	}
+	token, err := a.feedToken(uid)
+	if err != nil {
+		c.JSON(500, respErr(1004, "db_error"))
+		return
+	}
+	c.JSON(200, respOk(feedLinks(uid, token)))
+}
+
+// RotateFeedToken revokes the caller's feed URLs and returns new ones.
+func (a *AnnouncementsController) RotateFeedToken(c *gin.Context) {
+	uid := c.GetString("user_id")
+	if uid == "" {
+		c.JSON(401, respErr(1001, "unauthorized"))
+		return
+	}
+	row := models.FeedToken{UserID: uid, Token: newFeedToken()}
+	if err := a.db.Save(&row).Error; err != nil {
+		c.JSON(500, respErr(1004, "db_error"))
+		return
+	}
+	c.JSON(200, respOk(feedLinks(uid, row.Token)))
+}
+
+// feedViewer checks the uid/token pair on a feed request.
+func (a *AnnouncementsController) feedViewer(c *gin.Context) *viewer {
+	uid := c.Query("uid")
+	var row models.FeedToken
+	if uid == "" || a.db.First(&row, "\"userId\" = ?", uid).Error != nil ||
+		!hmac.Equal([]byte(c.Query("token")), []byte(row.Token)) {
+		c.JSON(401, respErr(1001, "unauthorized"))
+		return nil
+	}
+	return loadViewer(a.db, uid, "")
+}
+
+// writeFeed sends body with a content-hash ETag.
+func writeFeed(c *gin.Context, contentType string, body []byte) {
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "private, max-age=300")
+	if inm := c.GetHeader("If-None-Match"); inm != "" {
+		for _, t := range strings.Split(inm, ",") {
+			t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+			if t == etag || t == "*" {
+				c.Status(304)
+				return
+			}
+		}
+	}
+	c.Data(200, contentType, body)
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr,omitempty"`
+	Body string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	ID        string     `xml:"id"`
+	Title     string     `xml:"title"`
+	Updated   string     `xml:"updated"`
+	Published string     `xml:"published"`
+	Link      atomLink   `xml:"link"`
+	Category  *atomCat   `xml:"category,omitempty"`
+	Content   atomText   `xml:"content"`
+	Author    atomAuthor `xml:"author"`
+}
+
+type atomCat struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+func announcementLink(id string) string {
+	return publicBaseURL() + "/student/announcements/" + id
+}
+
+// buildAtom renders items; an empty feed is dated by the hour of now.
+func buildAtom(items []AnnouncementFile, self string, now time.Time) ([]byte, error) {
+	feed := atomFeed{
+		ID:    "urn:scholarhub:announcements",
+		Title: "ScholarHub",
+		Links: []atomLink{{Href: self, Rel: "self", Type: "application/atom+xml"}, {Href: publicBaseURL() + "/student/announcements"}},
+	}
+	var updated time.Time
+	for _, x := range items {
+		if x.PublishAt.After(updated) {
+			updated = x.PublishAt
+		}
+		e := atomEntry{
+			ID:        "urn:scholarhub:announcement:" + x.ID,
+			Title:     x.Title,
+			Updated:   x.PublishAt.UTC().Format(time.RFC3339),
+			Published: x.PublishAt.UTC().Format(time.RFC3339),
+			Link:      atomLink{Href: announcementLink(x.ID)},
+			Content:   atomText{Type: "html", Body: x.HTML},
+			Author:    atomAuthor{Name: "ScholarHub"},
+		}
+		if x.Severity != "" {
+			e.Category = &atomCat{Term: x.Severity}
+		}
+		feed.Entries = append(feed.Entries, e)
+	}
+	if updated.IsZero() {
+		updated = now.Truncate(time.Hour)
+	}
+	feed.Updated = updated.UTC().Format(time.RFC3339)
+	b, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), b...), nil
+}
+
+// PublicAtomFeed serves what the in-app list would show.
+func (a *AnnouncementsController) PublicAtomFeed(c *gin.Context) {
+	v := a.feedViewer(c)
+	if v == nil {
+		return
+	}
+	now := time.Now()
+	items, err := a.visibleTo(v, AnnouncementFilter{VisibleAt: &now})
+	if err != nil {
+		c.JSON(500, respErr(1004, "db_error"))
+		return
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].PublishAt.After(items[j].PublishAt) })
+	if len(items) > feedMaxEntries {
+		items = items[:feedMaxEntries]
+	}
+	locale := negotiateLocale(a.savedLocale(v.UID), c.GetHeader("Accept-Language"))
+	api := publicAPIURL(publicBaseURL())
+	for i := range items {
+		items[i] = localize(items[i], locale)
+		signAttachments(&items[i], v.UID, api)
+	}
+	body, err := buildAtom(items, feedURL("atom", v.UID, c.Query("token")), now)
+	if err != nil {
+		c.JSON(500, respErr(1004, "render_error"))
+		return
+	}
+	writeFeed(c, "application/atom+xml; charset=utf-8", body)
+}
+
+// icsEscape escapes TEXT values per RFC 5545 3.3.11.
+func icsEscape(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+	return r.Replace(s)
+}
+
+// icsFold folds lines at 75 octets without splitting UTF-8 sequences.
+func icsFold(line string) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range line {
+		l := len(string(r))
+		if n+l > 75 {
+			b.WriteString("\r\n ")
+			n = 1
+		}
+		b.WriteRune(r)
+		n += l
+	}
+	b.WriteString("\r\n")
+	return b.String()
+}
+
+func icsTime(t time.Time) string { return t.UTC().Format("20060102T150405Z") }
+
+// buildICS turns validity windows into events.
+func buildICS(items []AnnouncementFile, now time.Time) []byte {
+	var b strings.Builder
+	for _, l := range []string{"BEGIN:VCALENDAR", "VERSION:2.0", "PRODID:-//ScholarHub//Announcements//ZH", "CALSCALE:GREGORIAN", "X-WR-CALNAME:ScholarHub"} {
+		b.WriteString(icsFold(l))
+	}
+	for _, x := range items {
+		start := x.PublishAt
+		if x.ValidFrom != nil {
+			start = *x.ValidFrom
+		}
+		end := start
+		if x.ValidTo != nil {
+			end = *x.ValidTo
+		}
+		lines := []string{
+			"BEGIN:VEVENT",
+			"UID:" + x.ID + "@scholarhub",
+			"DTSTAMP:" + icsTime(now),
+			"DTSTART:" + icsTime(start),
+			"DTEND:" + icsTime(end),
+			"SUMMARY:" + icsEscape(x.Title),
+			"DESCRIPTION:" + icsEscape(x.Markdown),
+			"URL:" + announcementLink(x.ID),
+			"END:VEVENT",
+		}
+		for _, l := range lines {
+			b.WriteString(icsFold(l))
+		}
+	}
+	b.WriteString(icsFold("END:VCALENDAR"))
+	return []byte(b.String())
+}
+
+// PublicICSFeed serves announcements with a validity window as events,
+// including windows not yet open or already closed.
+func (a *AnnouncementsController) PublicICSFeed(c *gin.Context) {
+	v := a.feedViewer(c)
+	if v == nil {
+		return
+	}
+	items, err := a.visibleTo(v, AnnouncementFilter{})
+	if err != nil {
+		c.JSON(500, respErr(1004, "db_error"))
+		return
+	}
+	locale := negotiateLocale(a.savedLocale(v.UID), c.GetHeader("Accept-Language"))
+	out := make([]AnnouncementFile, 0)
+	for _, x := range items {
+		if x.ValidFrom != nil || x.ValidTo != nil {
+			out = append(out, localize(x, locale))
+		}
+	}
+	// A fixed DTSTAMP keeps the ETag of an unchanged feed.
+	var stamp time.Time
+	for _, x := range out {
+		if x.PublishAt.After(stamp) {
+			stamp = x.PublishAt
+		}
+	}
+	writeFeed(c, "text/calendar; charset=utf-8", buildICS(out, stamp))
+}
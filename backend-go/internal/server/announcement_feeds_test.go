@@ -0,0 +1,115 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"scholarhub/backend-go/internal/models"
+)
+
+func TestICSEscapeAndFold(t *testing.T) {
+	if got := icsEscape("a,b;c\\d\ne"); got != `a\,b\;c\\d\ne` {
+		t.Fatalf("unexpected escape %q", got)
+	}
+	line := "SUMMARY:" + strings.Repeat("停电", 30)
+	folded := icsFold(line)
+	for _, l := range strings.Split(strings.TrimSuffix(folded, "\r\n"), "\r\n") {
+		if len(l) > 75 {
+			t.Fatalf("line longer than 75 octets: %d", len(l))
+		}
+	}
+	if strings.ReplaceAll(strings.TrimSuffix(folded, "\r\n"), "\r\n ", "") != line {
+		t.Fatalf("unfolding must give back the original line")
+	}
+}
+
+func TestBuildICSUsesValidityWindow(t *testing.T) {
+	from := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	to := from.Add(4 * time.Hour)
+	out := string(buildICS([]AnnouncementFile{{ID: "a1", Title: "停电, 维修", PublishAt: from.AddDate(0, 0, -1), ValidFrom: &from, ValidTo: &to}}, from))
+	for _, want := range []string{"BEGIN:VEVENT\r\n", "DTSTART:20260301T080000Z", "DTEND:20260301T120000Z", `SUMMARY:停电\, 维修`, "UID:a1@scholarhub"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("calendar is missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestWriteFeedConditionalGet(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/f", func(c *gin.Context) { writeFeed(c, "text/plain", []byte("body")) })
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/f", nil))
+	etag := w.Header().Get("ETag")
+	if w.Code != 200 || etag == "" {
+		t.Fatalf("expected 200 with an ETag, got %d %q", w.Code, etag)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/f", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != 304 || w.Body.Len() != 0 {
+		t.Fatalf("expected 304 without body, got %d", w.Code)
+	}
+}
+
+func TestFeedsUseRevocableTokensAndListFiltering(t *testing.T) {
+	t.Setenv("PUBLIC_API_URL", "https://campus.test/api")
+	gin.SetMode(gin.TestMode)
+	db := newTestDB(t, &models.User{}, &models.Course{}, &models.CourseEnrollment{}, &models.NotificationPreference{}, &models.FeedToken{})
+	db.Create(&models.User{ID: "u1", Username: "u1", Role: "STUDENT"})
+	now := time.Now().UTC()
+	from, to := now.Add(24*time.Hour), now.Add(48*time.Hour)
+	a := &AnnouncementsController{db: db, store: newTestStore(t,
+		AnnouncementFile{ID: "img", Title: "plan", PublishAt: now.Add(-time.Hour), HTML: `<p><img src="attachments/9" alt="plan"></p>`,
+			Attachments: []AnnouncementAttachment{{ID: "9", Inline: true}}},
+		AnnouncementFile{ID: "exam", Title: "exam week", PublishAt: now.Add(-2 * time.Hour), ValidFrom: &from, ValidTo: &to},
+		AnnouncementFile{ID: "staff", Title: "staff only", PublishAt: now.Add(-time.Hour), ValidFrom: &from, Audience: &Audience{Roles: []string{"TEACHER"}}},
+	)}
+	r := gin.New()
+	r.GET("/feeds", asUser("u1", "STUDENT"), a.PublicFeedLinks)
+	r.POST("/feeds/rotate", asUser("u1", "STUDENT"), a.RotateFeedToken)
+	r.GET("/api/announcements/feed.atom", a.PublicAtomFeed)
+	r.GET("/api/announcements/feed.ics", a.PublicICSFeed)
+	get := func(link string) (int, string) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, strings.TrimPrefix(link, "https://campus.test"), nil))
+		return w.Code, w.Body.String()
+	}
+
+	_, resp := doJSON(t, r, "GET", "/feeds", nil)
+	links := resp.Data.(map[string]interface{})
+	code, atom := get(links["atom"].(string))
+	if code != 200 || !strings.Contains(atom, `src=&#34;https://campus.test/api/announcements/img/attachments/9?`) {
+		t.Fatalf("atom should link inline images through signed absolute URLs, got %d\n%s", code, atom)
+	}
+	if strings.Contains(atom, "staff only") {
+		t.Fatalf("atom must respect the audience")
+	}
+	code, ics := get(links["ics"].(string))
+	if code != 200 || !strings.Contains(ics, "SUMMARY:exam week") || strings.Contains(ics, "staff only") || strings.Contains(ics, "SUMMARY:plan") {
+		t.Fatalf("ics should list the visible windowed announcement only, got %d\n%s", code, ics)
+	}
+
+	_, rotated := doJSON(t, r, "POST", "/feeds/rotate", nil)
+	if code, _ := get(links["atom"].(string)); code != 401 {
+		t.Fatalf("the old token should stop working after rotation, got %d", code)
+	}
+	if code, _ := get(rotated.Data.(map[string]interface{})["atom"].(string)); code != 200 {
+		t.Fatalf("the new token should work, got %d", code)
+	}
+}
+
+func TestEmptyAtomFeedHasUpdatedTime(t *testing.T) {
+	now := time.Date(2026, 3, 1, 8, 30, 0, 0, time.UTC)
+	b, err := buildAtom(nil, "self", now)
+	if err != nil || !strings.Contains(string(b), "<updated>2026-03-01T08:00:00Z</updated>") {
+		t.Fatalf("empty feed should be dated by the hour, got %v\n%s", err, b)
+	}
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"scholarhub/backend-go/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+var supportedLocales = []string{"zh", "en"}
+
+func isSupportedLocale(l string) bool {
+	for _, x := range supportedLocales {
+		if x == l {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultLocale is ANNOUNCE_DEFAULT_LOCALE (default zh).
+func defaultLocale() string {
+	if l := strings.ToLower(strings.TrimSpace(os.Getenv("ANNOUNCE_DEFAULT_LOCALE"))); isSupportedLocale(l) {
+		return l
+	}
+	return "zh"
+}
+
+// AnnouncementTranslation is an announcement in one more language.
+type AnnouncementTranslation struct {
+	Title    string `json:"title"`
+	Markdown string `json:"markdown"`
+	HTML     string `json:"html"`
+}
+
+// announcementLocale is the language of af's own Title and Markdown.
+func announcementLocale(af AnnouncementFile) string {
+	if af.Locale != "" {
+		return af.Locale
+	}
+	return defaultLocale()
+}
+
+// missingLocales lists the supported languages af has no text for.
+func missingLocales(af AnnouncementFile) []string {
+	base := announcementLocale(af)
+	out := []string{}
+	for _, l := range supportedLocales {
+		if _, ok := af.Translations[l]; l != base && !ok {
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+// localize returns af in locale if translated; Lang says which it got.
+func localize(af AnnouncementFile, locale string) AnnouncementFile {
+	af.Lang = announcementLocale(af)
+	if t, ok := af.Translations[locale]; ok && locale != af.Lang {
+		af.Title, af.Markdown, af.HTML = t.Title, t.Markdown, t.HTML
+		af.Lang = locale
+	}
+	af.Translations = nil
+	return af
+}
+
+// parseTranslations validates and renders Translations for base.
+func parseTranslations(in map[string]AnnouncementTranslation, base string) (map[string]AnnouncementTranslation, string) {
+	if len(in) == 0 {
+		return nil, ""
+	}
+	out := make(map[string]AnnouncementTranslation, len(in))
+	for l, t := range in {
+		l = strings.ToLower(strings.TrimSpace(l))
+		if !isSupportedLocale(l) || l == base {
+			return nil, "invalid_locale"
+		}
+		t.Title = strings.TrimSpace(t.Title)
+		if t.Title == "" || len([]rune(t.Title)) > 100 {
+			return nil, "invalid_title"
+		}
+		t.Markdown = strings.TrimSpace(t.Markdown)
+		t.HTML = renderMarkdown(t.Markdown)
+		out[l] = t
+	}
+	return out, ""
+}
+
+// negotiateLocale prefers saved, then Accept-Language, then the default.
+func negotiateLocale(saved, acceptLanguage string) string {
+	if isSupportedLocale(saved) {
+		return saved
+	}
+	type choice struct {
+		lang string
+		q    float64
+	}
+	var choices []choice
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.ToLower(strings.TrimSpace(fields[0]))
+		if tag == "" {
+			continue
+		}
+		q := 1.0
+		for _, p := range fields[1:] {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(p), "q="); ok {
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					q = f
+				}
+			}
+		}
+		if primary, _, _ := strings.Cut(tag, "-"); isSupportedLocale(primary) && q > 0 {
+			choices = append(choices, choice{primary, q})
+		}
+	}
+	sort.SliceStable(choices, func(i, j int) bool { return choices[i].q > choices[j].q })
+	if len(choices) > 0 {
+		return choices[0].lang
+	}
+	return defaultLocale()
+}
+
+// savedLocale is uid's chosen announcement language, or "".
+func (a *AnnouncementsController) savedLocale(uid string) string {
+	if uid == "" || a.db == nil {
+		return ""
+	}
+	var row models.NotificationPreference
+	if err := a.db.Select("announcementLocale").First(&row, "\"userId\" = ?", uid).Error; err != nil || row.AnnouncementLocale == nil {
+		return ""
+	}
+	return *row.AnnouncementLocale
+}
+
+// readerLocale is negotiateLocale for the request's user.
+func (a *AnnouncementsController) readerLocale(c *gin.Context) string {
+	return negotiateLocale(a.savedLocale(c.GetString("user_id")), c.GetHeader("Accept-Language"))
+}
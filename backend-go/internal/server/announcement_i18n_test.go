@@ -0,0 +1,78 @@
+package server
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNegotiateLocale(t *testing.T) {
+	t.Setenv("ANNOUNCE_DEFAULT_LOCALE", "")
+	cases := []struct {
+		saved, header, want string
+	}{
+		{"", "", "zh"},
+		{"", "en-US,en;q=0.9", "en"},
+		{"", "fr-FR, zh-CN;q=0.5, en;q=0.8", "en"},
+		{"", "fr, de;q=0.5", "zh"},
+		{"", "en;q=0, zh-TW", "zh"},
+		{"zh", "en-US", "zh"},
+		{"xx", "en", "en"},
+	}
+	for _, c := range cases {
+		if got := negotiateLocale(c.saved, c.header); got != c.want {
+			t.Errorf("negotiateLocale(%q, %q) = %q, want %q", c.saved, c.header, got, c.want)
+		}
+	}
+}
+
+func TestLocalizeAndMissingLocales(t *testing.T) {
+	t.Setenv("ANNOUNCE_DEFAULT_LOCALE", "")
+	tr, code := parseTranslations(map[string]AnnouncementTranslation{" EN ": {Title: " Power outage ", Markdown: "**today**"}}, "zh")
+	if code != "" {
+		t.Fatalf("unexpected error %s", code)
+	}
+	af := AnnouncementFile{ID: "a1", Title: "停电通知", Markdown: "今天", HTML: "<p>今天</p>", Translations: tr}
+	if m := missingLocales(af); len(m) != 0 {
+		t.Fatalf("expected no missing locales, got %v", m)
+	}
+	en := localize(af, "en")
+	if en.Title != "Power outage" || !strings.Contains(en.HTML, "<strong>today</strong>") || en.Lang != "en" || en.Translations != nil {
+		t.Fatalf("unexpected english variant %+v", en)
+	}
+	if zh := localize(af, "zh"); zh.Title != "停电通知" || zh.Lang != "zh" {
+		t.Fatalf("unexpected chinese variant %+v", zh)
+	}
+	af.Translations = nil
+	if m := missingLocales(af); len(m) != 1 || m[0] != "en" {
+		t.Fatalf("expected en missing, got %v", m)
+	}
+	if got := localize(af, "en"); got.Title != "停电通知" || got.Lang != "zh" {
+		t.Fatalf("expected fallback to the original, got %+v", got)
+	}
+	if _, code := parseTranslations(map[string]AnnouncementTranslation{"zh": {Title: "x"}}, "zh"); code != "invalid_locale" {
+		t.Fatalf("a translation into the original language must be rejected, got %q", code)
+	}
+	if _, code := parseTranslations(map[string]AnnouncementTranslation{"en": {Title: " "}}, "zh"); code != "invalid_title" {
+		t.Fatalf("an empty translated title must be rejected, got %q", code)
+	}
+}
+
+func TestSavedLocaleIgnoresDigestDefaults(t *testing.T) {
+	n := notificationsTestDB(t)
+	a := &AnnouncementsController{db: n.db}
+	r := notificationsRouter(n, "u1")
+	if code, _ := doJSON(t, r, "PUT", "/notifications/preferences", map[string]string{"digest": "DAILY"}); code != 200 {
+		t.Fatalf("saving preferences failed: %d", code)
+	}
+	if got := negotiateLocale(a.savedLocale("u1"), "en-US,en;q=0.9"); got != "en" {
+		t.Fatalf("a digest preference must not pin the announcement language, got %q", got)
+	}
+	doJSON(t, r, "PUT", "/notifications/preferences", map[string]string{"announcementLocale": "zh"})
+	if got := negotiateLocale(a.savedLocale("u1"), "en-US"); got != "zh" {
+		t.Fatalf("an explicit choice should win over the browser, got %q", got)
+	}
+	doJSON(t, r, "PUT", "/notifications/preferences", map[string]string{"announcementLocale": ""})
+	if got := a.savedLocale("u1"); got != "" {
+		t.Fatalf("clearing the choice should hand back to the browser, got %q", got)
+	}
+}
@@ -0,0 +1,410 @@
+package server
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// record appends cur as the next revision and returns its number (0 on
+// failure). Announcements created before revisions existed have none, so
+// their prior state prev is stored first as a BASELINE revision. A number
+// taken by a concurrent writer is retried with the next one.
+func (a *AnnouncementsController) record(prev *AnnouncementFile, cur AnnouncementFile, uid, action string) int {
+	for attempt := 0; attempt < 5; attempt++ {
+		rev, err := a.appendRevision(prev, cur, uid, action)
+		if err == errRevisionTaken {
+			continue
+		}
+		if err != nil {
+			log.Printf("announcement revision write error id=%s err=%v", cur.ID, err)
+			return 0
+		}
+		return rev
+	}
+	log.Printf("announcement revision write error id=%s err=%v", cur.ID, errRevisionTaken)
+	return 0
+}
+
+func (a *AnnouncementsController) appendRevision(prev *AnnouncementFile, cur AnnouncementFile, uid, action string) (int, error) {
+	revs, err := a.store.Revisions(cur.ID)
+	if err != nil {
+		return 0, err
+	}
+	next := 1
+	if n := len(revs); n > 0 {
+		next = revs[n-1].Rev + 1
+	} else if prev != nil {
+		base := AnnouncementRevision{Rev: 1, Action: "BASELINE", Editor: prev.Publisher, Time: prev.PublishAt, Snapshot: snapshotOf(*prev)}
+		if err := a.store.AddRevision(base); err != nil {
+			return 0, err
+		}
+		next = 2
+	}
+	rev := AnnouncementRevision{Rev: next, Action: action, Editor: uid, Time: time.Now().UTC(), Snapshot: snapshotOf(cur)}
+	return next, a.store.AddRevision(rev)
+}
+
+func snapshotOf(af AnnouncementFile) AnnouncementFile {
+	af.Read = false
+	af.Fav = false
+	return af
+}
+
+func (a *AnnouncementsController) findRevision(id string, rev int) (*AnnouncementRevision, error) {
+	revs, err := a.store.Revisions(id)
+	if err != nil {
+		return nil, err
+	}
+	for i := range revs {
+		if revs[i].Rev == rev {
+			return &revs[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// AdminRevisions lists an announcement's revisions without their content.
+func (a *AnnouncementsController) AdminRevisions(c *gin.Context) {
+	revs, err := a.store.Revisions(c.Param("id"))
+	if err != nil {
+		c.JSON(500, respErr(1004, "db_error"))
+		return
+	}
+	items := make([]gin.H, 0, len(revs))
+	for i := len(revs) - 1; i >= 0; i-- {
+		r := revs[i]
+		items = append(items, gin.H{"rev": r.Rev, "action": r.Action, "editor": r.Editor, "time": r.Time, "title": r.Snapshot.Title})
+	}
+	c.JSON(200, respOk(gin.H{"items": items, "total": len(items)}))
+}
+
+// AdminRevision returns one revision with its full content.
+func (a *AnnouncementsController) AdminRevision(c *gin.Context) {
+	rev, err := strconv.Atoi(c.Param("rev"))
+	if err != nil {
+		c.JSON(400, respErr(1002, "bad_request"))
+		return
+	}
+	r, err := a.findRevision(c.Param("id"), rev)
+	if err != nil {
+		c.JSON(500, respErr(1004, "db_error"))
+		return
+	}
+	if r == nil {
+		c.JSON(404, respErr(1006, "not_found"))
+		return
+	}
+	c.JSON(200, respOk(r))
+}
+
+// AdminDiff compares two revisions: changed fields side by side and a line
+// diff of the markdown. Without parameters it shows what the latest
+// revision changed; from=0 compares against an empty announcement.
+func (a *AnnouncementsController) AdminDiff(c *gin.Context) {
+	revs, err := a.store.Revisions(c.Param("id"))
+	if err != nil {
+		c.JSON(500, respErr(1004, "db_error"))
+		return
+	}
+	if len(revs) == 0 {
+		c.JSON(404, respErr(1006, "not_found"))
+		return
+	}
+	to := revs[len(revs)-1].Rev
+	if v, err := strconv.Atoi(c.Query("to")); err == nil {
+		to = v
+	}
+	from := to - 1
+	if v, err := strconv.Atoi(c.Query("from")); err == nil {
+		from = v
+	}
+	var left, right *AnnouncementFile
+	for i := range revs {
+		if revs[i].Rev == from {
+			left = &revs[i].Snapshot
+		}
+		if revs[i].Rev == to {
+			right = &revs[i].Snapshot
+		}
+	}
+	if right == nil || (left == nil && from != 0) {
+		c.JSON(404, respErr(1006, "not_found"))
+		return
+	}
+	if left == nil {
+		left = &AnnouncementFile{}
+	}
+	c.JSON(200, respOk(gin.H{
+		"from":     from,
+		"to":       to,
+		"fields":   diffFields(*left, *right),
+		"markdown": diffLines(splitLines(left.Markdown), splitLines(right.Markdown)),
+	}))
+}
+
+// AdminRollback restores the content of an earlier revision (title, body,
+// translations, severity, pinning, audience, validity) as a new revision. Like an edit,
+// it needs a fresh review when approval is required.
+func (a *AnnouncementsController) AdminRollback(c *gin.Context) {
+	var req struct {
+		Rev int `json:"rev"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.Rev < 1 {
+		c.JSON(400, respErr(1002, "bad_request"))
+		return
+	}
+	id := c.Param("id")
+	af, err := a.store.Get(id)
+	if err != nil || af.DeletedAt != nil {
+		c.JSON(404, respErr(1006, "not_found"))
+		return
+	}
+	r, err := a.findRevision(id, req.Rev)
+	if err != nil {
+		c.JSON(500, respErr(1004, "db_error"))
+		return
+	}
+	if r == nil {
+		c.JSON(404, respErr(1006, "not_found"))
+		return
+	}
+	prev := *af
+	s := r.Snapshot
+	af.Title = s.Title
+	af.Severity = s.Severity
+	af.Pinned = s.Pinned
+	af.Scope = s.Scope
+	af.Audience = s.Audience
+	af.ValidFrom = s.ValidFrom
+	af.ValidTo = s.ValidTo
+	af.Markdown = s.Markdown
+	af.HTML = renderMarkdown(s.Markdown)
+	af.Locale = s.Locale
+	af.Translations = nil
+	for l, t := range s.Translations {
+		if af.Translations == nil {
+			af.Translations = map[string]AnnouncementTranslation{}
+		}
+		t.HTML = renderMarkdown(t.Markdown)
+		af.Translations[l] = t
+	}
+	uid := c.GetString("user_id")
+	if contentEdited(prev, *af) && !a.reopenForReview(af, uid) {
+		c.JSON(409, respErr(1003, "review_required"))
+		return
+	}
+	if err := a.store.Save(*af); err != nil {
+		c.JSON(500, respErr(1004, "write_error"))
+		return
+	}
+	rev := a.record(&prev, *af, uid, "ROLLBACK")
+	a.logAction(uid, "ROLLBACK_ANNOUNCEMENT", id, gin.H{"title": af.Title, "toRev": req.Rev, "rev": rev})
+	c.JSON(200, respOk(gin.H{"ok": true, "rev": rev}))
+}
+
+// AdminTrash lists soft-deleted announcements, most recently deleted first.
+func (a *AnnouncementsController) AdminTrash(c *gin.Context) {
+	page, _ := strconv.Atoi(c.Query("page"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(c.Query("pageSize"))
+	if pageSize < 1 {
+		pageSize = 10
+	}
+	items, err := a.store.List(AnnouncementFilter{Trashed: true})
+	if err != nil {
+		c.JSON(500, respErr(1004, "db_error"))
+		return
+	}
+	sortByDeleted(items)
+	total := len(items)
+	start := (page - 1) * pageSize
+	if start > total {
+		start = total
+	}
+	end := start + pageSize
+	if end > total {
+		end = total
+	}
+	c.JSON(200, respOk(gin.H{"items": items[start:end], "total": total}))
+}
+
+func sortByDeleted(items []AnnouncementFile) {
+	sort.Slice(items, func(i, j int) bool { return items[i].DeletedAt.After(*items[j].DeletedAt) })
+}
+
+// AdminRestore takes an announcement out of the trash.
+func (a *AnnouncementsController) AdminRestore(c *gin.Context) {
+	id := c.Param("id")
+	af, err := a.store.Get(id)
+	if err != nil || af.DeletedAt == nil {
+		c.JSON(404, respErr(1006, "not_found"))
+		return
+	}
+	prev := *af
+	af.DeletedAt = nil
+	af.DeletedBy = ""
+	if err := a.store.Save(*af); err != nil {
+		c.JSON(500, respErr(1004, "write_error"))
+		return
+	}
+	uid := c.GetString("user_id")
+	a.record(&prev, *af, uid, "RESTORE")
+	a.logAction(uid, "RESTORE_ANNOUNCEMENT", id, gin.H{"title": af.Title})
+	c.JSON(200, respOk(gin.H{"ok": true}))
+}
+
+// AdminPurge permanently removes a trashed announcement, its history and
+// its attachments.
+func (a *AnnouncementsController) AdminPurge(c *gin.Context) {
+	id := c.Param("id")
+	af, err := a.store.Get(id)
+	if err != nil || af.DeletedAt == nil {
+		c.JSON(404, respErr(1006, "not_found"))
+		return
+	}
+	if err := a.purge(id); err != nil {
+		c.JSON(500, respErr(1004, "delete_error"))
+		return
+	}
+	a.logAction(c.GetString("user_id"), "PURGE_ANNOUNCEMENT", id, gin.H{"title": af.Title})
+	c.JSON(200, respOk(gin.H{"ok": true}))
+}
+
+// purge removes an announcement for good, with its revisions, user state
+// and attachment files.
+func (a *AnnouncementsController) purge(id string) error {
+	if err := a.store.Delete(id); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(filepath.Join(attachmentDir(), id)); err != nil {
+		log.Printf("announcement purge attachments error id=%s err=%v", id, err)
+	}
+	return nil
+}
+
+type fieldChange struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+func diffFields(a, b AnnouncementFile) []fieldChange {
+	fields := []struct {
+		name string
+		get  func(AnnouncementFile) string
+	}{
+		{"title", func(x AnnouncementFile) string { return x.Title }},
+		{"severity", func(x AnnouncementFile) string { return x.Severity }},
+		{"pinned", func(x AnnouncementFile) string { return strconv.FormatBool(x.Pinned) }},
+		{"scope", func(x AnnouncementFile) string { return x.Scope }},
+		{"audience", func(x AnnouncementFile) string {
+			if x.Audience.Empty() {
+				return ""
+			}
+			return string(mustJSON(x.Audience))
+		}},
+		{"validFrom", func(x AnnouncementFile) string { return fmtTimePtr(x.ValidFrom) }},
+		{"validTo", func(x AnnouncementFile) string { return fmtTimePtr(x.ValidTo) }},
+		{"status", func(x AnnouncementFile) string { return x.Status }},
+		{"scheduledAt", func(x AnnouncementFile) string { return fmtTimePtr(x.ScheduledAt) }},
+		{"deletedAt", func(x AnnouncementFile) string { return fmtTimePtr(x.DeletedAt) }},
+		{"locale", func(x AnnouncementFile) string { return x.Locale }},
+		{"translations", func(x AnnouncementFile) string {
+			if len(x.Translations) == 0 {
+				return ""
+			}
+			return string(mustJSON(x.Translations))
+		}},
+	}
+	out := make([]fieldChange, 0)
+	for _, f := range fields {
+		if from, to := f.get(a), f.get(b); from != to {
+			out = append(out, fieldChange{Field: f.name, From: from, To: to})
+		}
+	}
+	return out
+}
+
+func fmtTimePtr(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+type diffLine struct {
+	Op   string `json:"op"` // "=", "+" or "-"
+	Text string `json:"text"`
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+}
+
+// diffLineLimit bounds the LCS table; bigger inputs are shown as a full
+// replacement rather than spending quadratic memory.
+const diffLineLimit = 2000
+
+// diffLines is a longest-common-subsequence line diff.
+func diffLines(a, b []string) []diffLine {
+	out := make([]diffLine, 0, len(a)+len(b))
+	if len(a) > diffLineLimit || len(b) > diffLineLimit {
+		for _, l := range a {
+			out = append(out, diffLine{"-", l})
+		}
+		for _, l := range b {
+			out = append(out, diffLine{"+", l})
+		}
+		return out
+	}
+	// lcs[i][j] is the LCS length of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			out = append(out, diffLine{"=", a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, diffLine{"-", a[i]})
+			i++
+		default:
+			out = append(out, diffLine{"+", b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		out = append(out, diffLine{"-", a[i]})
+	}
+	for ; j < len(b); j++ {
+		out = append(out, diffLine{"+", b[j]})
+	}
+	return out
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"scholarhub/backend-go/internal/models"
+)
+
+func TestDiffLines(t *testing.T) {
+	got := diffLines(splitLines("a\nb\nc"), splitLines("a\nx\nc\nd"))
+	want := []diffLine{{"=", "a"}, {"-", "b"}, {"+", "x"}, {"=", "c"}, {"+", "d"}}
+	if len(got) != len(want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("line %d: got %v want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestRevisionsBaselineAndTrash(t *testing.T) {
+	store := newTestStore(t)
+	a := &AnnouncementsController{store: store}
+	now := time.Now().UTC()
+	legacy := AnnouncementFile{ID: "a1", Title: "v1", Markdown: "one", Publisher: "admin0", PublishAt: now}
+	_ = store.Save(legacy)
+
+	edited := legacy
+	edited.Title = "v2"
+	_ = store.Save(edited)
+	if rev := a.record(&legacy, edited, "admin1", "UPDATE"); rev != 2 {
+		t.Fatalf("expected baseline plus update as rev 2, got %d", rev)
+	}
+	revs, _ := store.Revisions("a1")
+	if len(revs) != 2 || revs[0].Action != "BASELINE" || revs[0].Snapshot.Title != "v1" || revs[1].Editor != "admin1" {
+		t.Fatalf("unexpected revisions %+v", revs)
+	}
+	if ch := diffFields(revs[0].Snapshot, revs[1].Snapshot); len(ch) != 1 || ch[0].Field != "title" {
+		t.Fatalf("unexpected field diff %+v", ch)
+	}
+
+	edited.DeletedAt = &now
+	_ = store.Save(edited)
+	if live, _ := store.List(AnnouncementFilter{}); len(live) != 0 {
+		t.Fatalf("trashed item still listed: %+v", live)
+	}
+	if trash, _ := store.List(AnnouncementFilter{Trashed: true}); len(trash) != 1 {
+		t.Fatalf("expected one trashed item, got %+v", trash)
+	}
+	if err := store.Delete("a1"); err != nil {
+		t.Fatal(err)
+	}
+	if revs, _ := store.Revisions("a1"); len(revs) != 0 {
+		t.Fatalf("purge left %d revisions", len(revs))
+	}
+}
+
+func TestConcurrentRevisionsGetDistinctNumbers(t *testing.T) {
+	for name, store := range map[string]AnnouncementStore{
+		"file":     newTestStore(t),
+		"postgres": NewPGAnnouncementStore(newTestDB(t, &models.AnnouncementRevision{})),
+	} {
+		a := &AnnouncementsController{store: store}
+		af := AnnouncementFile{ID: "a1", Title: "v1"}
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() { defer wg.Done(); a.record(nil, af, "admin1", "UPDATE") }()
+		}
+		wg.Wait()
+		revs, _ := store.Revisions("a1")
+		if len(revs) != 5 {
+			t.Fatalf("%s: lost revisions, got %d", name, len(revs))
+		}
+		for i, r := range revs {
+			if r.Rev != i+1 {
+				t.Fatalf("%s: revisions not numbered 1..5: %+v", name, revs)
+			}
+		}
+		if err := store.AddRevision(AnnouncementRevision{Rev: 3, Snapshot: af}); err != errRevisionTaken {
+			t.Fatalf("%s: reusing a number should fail with errRevisionTaken, got %v", name, err)
+		}
+	}
+}
+
+func TestRollbackNeedsReview(t *testing.T) {
+	later := time.Now().UTC().Add(time.Hour)
+	store := newTestStore(t,
+		AnnouncementFile{ID: "p", Title: "approved", Markdown: "approved", ApprovedBy: "reviewer", Status: StatusPublished},
+		AnnouncementFile{ID: "s", Title: "approved", Markdown: "approved", ApprovedBy: "reviewer", Status: StatusScheduled, ScheduledAt: &later},
+	)
+	a := &AnnouncementsController{db: newTestDB(t, &models.AdminLog{}), store: store, requireApproval: true}
+	for _, id := range []string{"p", "s"} {
+		a.record(nil, AnnouncementFile{ID: id, Title: "rejected", Markdown: "rejected"}, "author", "REJECT")
+	}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/announcements/:id/rollback", asUser("editor", "ADMIN"), a.AdminRollback)
+
+	if code, resp := doJSON(t, r, "POST", "/announcements/p/rollback", gin.H{"rev": 1}); code != 409 || resp.Msg != "review_required" {
+		t.Fatalf("rolling back a published item must not skip review, got %d %+v", code, resp)
+	}
+	if af, _ := store.Get("p"); af.Markdown != "approved" {
+		t.Fatalf("the live item changed: %+v", af)
+	}
+	if code, _ := doJSON(t, r, "POST", "/announcements/s/rollback", gin.H{"rev": 1}); code != 200 {
+		t.Fatalf("rollback of a scheduled item: %d", code)
+	}
+	if af, _ := store.Get("s"); af.Markdown != "rejected" || af.Status != StatusPending || af.ApprovedBy != "" {
+		t.Fatalf("a rolled back scheduled item should go back to review, got %+v", af)
+	}
+}
+
+func TestRollbackRestoresTranslations(t *testing.T) {
+	store := newTestStore(t, AnnouncementFile{ID: "a1", Title: "新版", Markdown: "新版", Locale: "zh",
+		Translations: map[string]AnnouncementTranslation{"en": {Title: "new", Markdown: "new"}}})
+	a := &AnnouncementsController{db: newTestDB(t, &models.AdminLog{}), store: store}
+	a.record(nil, AnnouncementFile{ID: "a1", Title: "old", Markdown: "old", Locale: "en",
+		Translations: map[string]AnnouncementTranslation{"zh": {Title: "旧版", Markdown: "**旧版**"}}}, "author", "CREATE")
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/announcements/:id/rollback", asUser("editor", "ADMIN"), a.AdminRollback)
+
+	if code, _ := doJSON(t, r, "POST", "/announcements/a1/rollback", gin.H{"rev": 1}); code != 200 {
+		t.Fatalf("rollback: %d", code)
+	}
+	af, _ := store.Get("a1")
+	zh, ok := af.Translations["zh"]
+	if af.Locale != "en" || len(af.Translations) != 1 || !ok || zh.HTML != renderMarkdown("**旧版**") {
+		t.Fatalf("the translations should match the restored body, got %q %+v", af.Locale, af.Translations)
+	}
+}
@@ -0,0 +1,586 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AnnouncementFilter narrows List. Zero values mean "no constraint".
+type AnnouncementFilter struct {
+	Severity string
+	// Status keeps items in one workflow state (see announcementStatus).
+	Status string
+	// VisibleAt keeps only published, non-archived items whose validity
+	// window contains the instant. Audience rules are applied by the
+	// controller.
+	VisibleAt *time.Time
+	// PublishedAfter keeps items published strictly after the instant.
+	PublishedAfter *time.Time
+	// Trashed selects soft-deleted items instead of live ones.
+	Trashed bool
+	// Archived keeps only archived items.
+	Archived bool
+	// Query keeps items whose title or markdown contains it, ignoring case.
+	Query string
+}
+
+// AnnouncementStore is where announcements and per-user read/favourite
+// state live. List returns items ordered pinned first, newest first.
+type AnnouncementStore interface {
+	Save(af AnnouncementFile) error
+	Delete(id string) error
+	Get(id string) (*AnnouncementFile, error)
+	List(f AnnouncementFilter) ([]AnnouncementFile, error)
+	UserState(uid string) (*userState, error)
+	MarkRead(uid string, ids []string) error
+	SetFavorite(uid, id string, fav bool) error
+	// ArchiveBefore archives published items whose retention started
+	// before cutoff: their publish time, or the last unarchive if later.
+	ArchiveBefore(cutoff time.Time) (int, error)
+	// Ping checks that the backing storage can be reached.
+	Ping(ctx context.Context) error
+	// PublishScheduled moves a SCHEDULED item to PUBLISHED with PublishAt
+	// set to at. It reports false when the item was no longer scheduled, so
+	// only one caller (or replica) ever announces it.
+	PublishScheduled(id string, at time.Time) (bool, error)
+	// AddRevision appends a snapshot, failing with errRevisionTaken if its
+	// number is already used; Revisions returns them oldest first. Delete
+	// removes an announcement's revisions along with it.
+	AddRevision(r AnnouncementRevision) error
+	Revisions(id string) ([]AnnouncementRevision, error)
+	// Readers returns every user with read or favourite state on id.
+	Readers(id string) ([]AnnouncementReader, error)
+}
+
+// AnnouncementReader is one user's state on one announcement. ReadAt is
+// nil when the item is unread or the read predates read timestamps.
+type AnnouncementReader struct {
+	UserID string
+	Read   bool
+	ReadAt *time.Time
+	Fav    bool
+}
+
+// AnnouncementRevision is the full state of an announcement after one
+// change, numbered from 1 per announcement.
+type AnnouncementRevision struct {
+	Rev      int              `json:"rev"`
+	Action   string           `json:"action"`
+	Editor   string           `json:"editor"`
+	Time     time.Time        `json:"time"`
+	Snapshot AnnouncementFile `json:"snapshot"`
+}
+
+var (
+	errAnnouncementNotFound = errors.New("announcement not found")
+	errRevisionTaken        = errors.New("revision number taken")
+)
+
+type userState struct {
+	Read   map[string]bool      `json:"read"`
+	Fav    map[string]bool      `json:"fav"`
+	ReadAt map[string]time.Time `json:"readAt,omitempty"`
+}
+
+func newUserState() *userState {
+	return &userState{Read: map[string]bool{}, Fav: map[string]bool{}}
+}
+
+func sortAnnouncements(items []AnnouncementFile) {
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Pinned != items[j].Pinned {
+			return items[i].Pinned && !items[j].Pinned
+		}
+		return items[i].PublishAt.After(items[j].PublishAt)
+	})
+}
+
+func (f AnnouncementFilter) match(x AnnouncementFile) bool {
+	if (x.DeletedAt != nil) != f.Trashed {
+		return false
+	}
+	if f.Severity != "" && strings.ToUpper(x.Severity) != f.Severity {
+		return false
+	}
+	if f.Status != "" && announcementStatus(x) != f.Status {
+		return false
+	}
+	if f.PublishedAfter != nil && !x.PublishAt.After(*f.PublishedAfter) {
+		return false
+	}
+	if f.Archived && !x.Archived {
+		return false
+	}
+	if q := strings.ToLower(f.Query); q != "" &&
+		!strings.Contains(strings.ToLower(x.Title), q) && !strings.Contains(strings.ToLower(x.Markdown), q) {
+		return false
+	}
+	if t := f.VisibleAt; t != nil {
+		if x.Archived || announcementStatus(x) != StatusPublished {
+			return false
+		}
+		if x.ValidFrom != nil && t.Before(*x.ValidFrom) {
+			return false
+		}
+		if x.ValidTo != nil && t.After(*x.ValidTo) {
+			return false
+		}
+	}
+	return true
+}
+
+// FileAnnouncementStore keeps one JSON file per announcement under
+// base/YYYYMMDD/ and per-user state under base/user_states/. Legacy bases
+// are read but never written.
+type FileAnnouncementStore struct {
+	base        string
+	legacyBases []string
+	locks       sync.Map
+}
+
+func NewFileAnnouncementStore(base string, legacy []string) *FileAnnouncementStore {
+	_ = os.MkdirAll(base, 0755)
+	return &FileAnnouncementStore{base: base, legacyBases: legacy}
+}
+
+// AnnounceDirs resolves ANNOUNCE_DIR / PROJECT_ROOT and the legacy
+// directories older deployments wrote to.
+func AnnounceDirs() (string, []string) {
+	dir := os.Getenv("ANNOUNCE_DIR")
+	if dir == "" {
+		if pr := os.Getenv("PROJECT_ROOT"); pr != "" {
+			dir = filepath.Join(pr, "announcements")
+		} else if runtimeOS() == "windows" {
+			dir = "announcements"
+		} else {
+			dir = "/var/announcements"
+		}
+	}
+	legacy := make([]string, 0, 2)
+	if runtimeOS() == "windows" {
+		if dir != "announcements" {
+			if fi, err := os.Stat("announcements"); err == nil && fi.IsDir() {
+				legacy = append(legacy, "announcements")
+			}
+		}
+	} else {
+		if dir != "/var/announcements" {
+			if fi, err := os.Stat("/var/announcements"); err == nil && fi.IsDir() {
+				legacy = append(legacy, "/var/announcements")
+			}
+		}
+	}
+	return dir, legacy
+}
+
+func (s *FileAnnouncementStore) Save(af AnnouncementFile) error {
+	_, path := s.findByID(af.ID)
+	if path == "" || !strings.HasPrefix(path, s.base) {
+		path = s.makePath(af.PublishAt, af.ID)
+	}
+	return s.writeJSON(path, af)
+}
+
+func (s *FileAnnouncementStore) Delete(id string) error {
+	af, path := s.findByID(id)
+	if af == nil {
+		return errAnnouncementNotFound
+	}
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	if err := os.Remove(s.revisionPath(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *FileAnnouncementStore) Ping(ctx context.Context) error {
+	fi, err := os.Stat(s.base)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("%s is not a directory", s.base)
+	}
+	return nil
+}
+
+func (s *FileAnnouncementStore) Get(id string) (*AnnouncementFile, error) {
+	af, _ := s.findByID(id)
+	if af == nil {
+		return nil, errAnnouncementNotFound
+	}
+	return af, nil
+}
+
+func (s *FileAnnouncementStore) List(f AnnouncementFilter) ([]AnnouncementFile, error) {
+	items := s.scanAll()
+	out := make([]AnnouncementFile, 0, len(items))
+	for _, x := range items {
+		if f.match(x) {
+			out = append(out, x)
+		}
+	}
+	sortAnnouncements(out)
+	return out, nil
+}
+
+func (s *FileAnnouncementStore) UserState(uid string) (*userState, error) {
+	if st := s.readUserState(uid); st != nil {
+		if st.Read == nil {
+			st.Read = map[string]bool{}
+		}
+		if st.Fav == nil {
+			st.Fav = map[string]bool{}
+		}
+		return st, nil
+	}
+	return newUserState(), nil
+}
+
+func (s *FileAnnouncementStore) MarkRead(uid string, ids []string) error {
+	l := s.getLock("user:" + uid)
+	l.Lock()
+	defer l.Unlock()
+	st, _ := s.UserState(uid)
+	if st.ReadAt == nil {
+		st.ReadAt = map[string]time.Time{}
+	}
+	now := time.Now().UTC()
+	for _, id := range ids {
+		if !st.Read[id] {
+			st.ReadAt[id] = now
+		}
+		st.Read[id] = true
+	}
+	return s.writeUserState(uid, *st)
+}
+
+// importRead marks id read at its original time, or an unknown one for
+// nil; an earlier time replaces a later one.
+func (s *FileAnnouncementStore) importRead(uid, id string, at *time.Time) error {
+	l := s.getLock("user:" + uid)
+	l.Lock()
+	defer l.Unlock()
+	st, _ := s.UserState(uid)
+	if st.ReadAt == nil {
+		st.ReadAt = map[string]time.Time{}
+	}
+	if t, ok := st.ReadAt[id]; at != nil && (!ok || at.Before(t)) {
+		st.ReadAt[id] = at.UTC()
+	}
+	st.Read[id] = true
+	return s.writeUserState(uid, *st)
+}
+
+func (s *FileAnnouncementStore) SetFavorite(uid, id string, fav bool) error {
+	l := s.getLock("user:" + uid)
+	l.Lock()
+	defer l.Unlock()
+	st, _ := s.UserState(uid)
+	if fav {
+		st.Fav[id] = true
+	} else {
+		delete(st.Fav, id)
+	}
+	return s.writeUserState(uid, *st)
+}
+
+func (s *FileAnnouncementStore) Readers(id string) ([]AnnouncementReader, error) {
+	out := make([]AnnouncementReader, 0)
+	for _, uid := range s.userStateIDs() {
+		st := s.readUserState(uid)
+		if st == nil || (!st.Read[id] && !st.Fav[id]) {
+			continue
+		}
+		r := AnnouncementReader{UserID: uid, Read: st.Read[id], Fav: st.Fav[id]}
+		if t, ok := st.ReadAt[id]; ok && r.Read {
+			r.ReadAt = &t
+		}
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+func (s *FileAnnouncementStore) ArchiveBefore(cutoff time.Time) (int, error) {
+	n := 0
+	err := filepath.WalkDir(s.base, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if isMetaDir(d) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(strings.ToLower(path), ".json") {
+			return nil
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		var af AnnouncementFile
+		if err := json.Unmarshal(b, &af); err != nil {
+			return nil
+		}
+		if af.ID != "" && !af.Archived && af.DeletedAt == nil &&
+			announcementStatus(af) == StatusPublished && retentionStart(af).Before(cutoff) {
+			af.Archived = true
+			if s.writeJSON(path, af) == nil {
+				n++
+			}
+		}
+		return nil
+	})
+	return n, err
+}
+
+func (s *FileAnnouncementStore) PublishScheduled(id string, at time.Time) (bool, error) {
+	l := s.getLock("publish:" + id)
+	l.Lock()
+	defer l.Unlock()
+	af, err := s.Get(id)
+	if err != nil {
+		return false, err
+	}
+	if af.Status != StatusScheduled {
+		return false, nil
+	}
+	af.Status = StatusPublished
+	af.PublishAt = at
+	return true, s.Save(*af)
+}
+
+func (s *FileAnnouncementStore) AddRevision(r AnnouncementRevision) error {
+	id := r.Snapshot.ID
+	l := s.getLock("rev:" + id)
+	l.Lock()
+	defer l.Unlock()
+	revs, err := s.Revisions(id)
+	if err != nil {
+		return err
+	}
+	if n := len(revs); n > 0 && revs[n-1].Rev >= r.Rev {
+		return errRevisionTaken
+	}
+	revs = append(revs, r)
+	b, _ := json.Marshal(revs)
+	p := s.revisionPath(id)
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p)
+}
+
+func (s *FileAnnouncementStore) Revisions(id string) ([]AnnouncementRevision, error) {
+	b, err := os.ReadFile(s.revisionPath(id))
+	if os.IsNotExist(err) {
+		return []AnnouncementRevision{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var revs []AnnouncementRevision
+	if err := json.Unmarshal(b, &revs); err != nil {
+		return nil, err
+	}
+	return revs, nil
+}
+
+// revisionPath keeps every revision of an announcement in one file under
+// base/revisions/, outside the dated directories scanAll walks.
+func (s *FileAnnouncementStore) revisionPath(id string) string {
+	dir := filepath.Join(s.base, "revisions")
+	_ = os.MkdirAll(dir, 0755)
+	return filepath.Join(dir, id+".json")
+}
+
+// isMetaDir reports directories that hold state rather than announcements.
+func isMetaDir(d fs.DirEntry) bool {
+	return d.Name() == "revisions" || d.Name() == "user_states" || d.Name() == "attachments"
+}
+
+func (s *FileAnnouncementStore) makePath(t time.Time, id string) string {
+	dir := filepath.Join(s.base, t.UTC().Format("20060102"))
+	_ = os.MkdirAll(dir, 0755)
+	return filepath.Join(dir, id+".json")
+}
+
+func (s *FileAnnouncementStore) writeJSON(path string, v AnnouncementFile) error {
+	s.getLock(v.ID).Lock()
+	defer s.getLock(v.ID).Unlock()
+	tmp := path + ".tmp"
+	b, _ := json.Marshal(v)
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *FileAnnouncementStore) scanAll() []AnnouncementFile {
+	roots := append([]string{s.base}, s.legacyBases...)
+	m := make(map[string]AnnouncementFile, 64)
+	for _, root := range roots {
+		_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() {
+				if isMetaDir(d) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !strings.HasSuffix(strings.ToLower(path), ".json") {
+				return nil
+			}
+			b, err := os.ReadFile(path)
+			if err != nil {
+				return nil
+			}
+			var af AnnouncementFile
+			if err := json.Unmarshal(b, &af); err != nil {
+				return nil
+			}
+			if af.ID == "" {
+				return nil
+			}
+			if _, ok := m[af.ID]; ok {
+				return nil
+			}
+			m[af.ID] = af
+			return nil
+		})
+	}
+	items := make([]AnnouncementFile, 0, len(m))
+	for _, v := range m {
+		items = append(items, v)
+	}
+	return items
+}
+
+func (s *FileAnnouncementStore) findByID(id string) (*AnnouncementFile, string) {
+	roots := append([]string{s.base}, s.legacyBases...)
+	for _, root := range roots {
+		var found *AnnouncementFile
+		var foundPath string
+		_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() {
+				if isMetaDir(d) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !strings.HasSuffix(strings.ToLower(path), ".json") {
+				return nil
+			}
+			b, err := os.ReadFile(path)
+			if err != nil {
+				return nil
+			}
+			var af AnnouncementFile
+			if err := json.Unmarshal(b, &af); err != nil {
+				return nil
+			}
+			if af.ID == id {
+				found = &af
+				foundPath = path
+				return fmt.Errorf("stop")
+			}
+			return nil
+		})
+		if found != nil {
+			return found, foundPath
+		}
+	}
+	return nil, ""
+}
+
+func (s *FileAnnouncementStore) userStatePath(uid string) string {
+	dir := filepath.Join(s.base, "user_states")
+	_ = os.MkdirAll(dir, 0755)
+	return filepath.Join(dir, uid+".json")
+}
+
+func (s *FileAnnouncementStore) readUserState(uid string) *userState {
+	p := s.userStatePath(uid)
+	b, err := os.ReadFile(p)
+	if err == nil {
+		var st userState
+		if err := json.Unmarshal(b, &st); err == nil {
+			return &st
+		}
+	}
+	for _, root := range s.legacyBases {
+		p2 := filepath.Join(root, "user_states", uid+".json")
+		if b2, err2 := os.ReadFile(p2); err2 == nil {
+			var st userState
+			if err := json.Unmarshal(b2, &st); err == nil {
+				return &st
+			}
+		}
+	}
+	return nil
+}
+
+func (s *FileAnnouncementStore) writeUserState(uid string, st userState) error {
+	p := s.userStatePath(uid)
+	b, _ := json.Marshal(st)
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p)
+}
+
+// userStateIDs lists every uid with a state file across all bases; the
+// importer uses it to carry read/fav state over.
+func (s *FileAnnouncementStore) userStateIDs() []string {
+	seen := map[string]bool{}
+	out := make([]string, 0)
+	for _, root := range append([]string{s.base}, s.legacyBases...) {
+		entries, err := os.ReadDir(filepath.Join(root, "user_states"))
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			name := e.Name()
+			if e.IsDir() || !strings.HasSuffix(name, ".json") {
+				continue
+			}
+			uid := strings.TrimSuffix(name, ".json")
+			if !seen[uid] {
+				seen[uid] = true
+				out = append(out, uid)
+			}
+		}
+	}
+	return out
+}
+
+func (s *FileAnnouncementStore) getLock(id string) *sync.Mutex {
+	v, _ := s.locks.LoadOrStore(id, &sync.Mutex{})
+	return v.(*sync.Mutex)
+}
@@ -0,0 +1,414 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"scholarhub/backend-go/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PGAnnouncementStore keeps announcements in "Announcement" and per-user
+// state in "AnnouncementState", so every replica sees the same data and
+// list queries hit indexes instead of walking a directory tree.
+type PGAnnouncementStore struct{ db *gorm.DB }
+
+func NewPGAnnouncementStore(db *gorm.DB) *PGAnnouncementStore { return &PGAnnouncementStore{db: db} }
+
+func toAnnouncementRow(af AnnouncementFile) models.Announcement {
+	var audience, attachments *string
+	if !af.Audience.Empty() {
+		v := string(mustJSON(af.Audience))
+		audience = &v
+	}
+	if len(af.Attachments) > 0 {
+		v := string(mustJSON(af.Attachments))
+		attachments = &v
+	}
+	var translations *string
+	if len(af.Translations) > 0 {
+		v := string(mustJSON(af.Translations))
+		translations = &v
+	}
+	return models.Announcement{
+		ID:           af.ID,
+		Title:        af.Title,
+		Severity:     af.Severity,
+		Pinned:       af.Pinned,
+		Scope:        af.Scope,
+		Audience:     audience,
+		Publisher:    af.Publisher,
+		PublishAt:    af.PublishAt,
+		ValidFrom:    af.ValidFrom,
+		ValidTo:      af.ValidTo,
+		Markdown:     af.Markdown,
+		HTML:         af.HTML,
+		Archived:     af.Archived,
+		UnarchivedAt: af.UnarchivedAt,
+		Status:       af.Status,
+		ScheduledAt:  af.ScheduledAt,
+		SubmittedBy:  optString(af.SubmittedBy),
+		ApprovedBy:   optString(af.ApprovedBy),
+		EditedBy:     optString(af.EditedBy),
+		ReviewNote:   optString(af.ReviewNote),
+		DeletedAt:    af.DeletedAt,
+		DeletedBy:    optString(af.DeletedBy),
+		Attachments:  attachments,
+		Locale:       optString(af.Locale),
+		Translations: translations,
+	}
+}
+
+func optString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func fromAnnouncementRow(r models.Announcement) AnnouncementFile {
+	var audience *Audience
+	if r.Audience != nil && *r.Audience != "" {
+		var au Audience
+		if json.Unmarshal([]byte(*r.Audience), &au) == nil {
+			audience = &au
+		}
+	}
+	var files []AnnouncementAttachment
+	if r.Attachments != nil && *r.Attachments != "" {
+		_ = json.Unmarshal([]byte(*r.Attachments), &files)
+	}
+	var translations map[string]AnnouncementTranslation
+	if r.Translations != nil && *r.Translations != "" {
+		_ = json.Unmarshal([]byte(*r.Translations), &translations)
+	}
+	return AnnouncementFile{
+		ID:           r.ID,
+		Title:        r.Title,
+		Severity:     r.Severity,
+		Pinned:       r.Pinned,
+		Scope:        r.Scope,
+		Audience:     audience,
+		Publisher:    r.Publisher,
+		PublishAt:    r.PublishAt,
+		ValidFrom:    r.ValidFrom,
+		ValidTo:      r.ValidTo,
+		Markdown:     r.Markdown,
+		HTML:         r.HTML,
+		Archived:     r.Archived,
+		UnarchivedAt: r.UnarchivedAt,
+		Status:       r.Status,
+		ScheduledAt:  r.ScheduledAt,
+		SubmittedBy:  derefString(r.SubmittedBy),
+		ApprovedBy:   derefString(r.ApprovedBy),
+		EditedBy:     derefString(r.EditedBy),
+		ReviewNote:   derefString(r.ReviewNote),
+		DeletedAt:    r.DeletedAt,
+		DeletedBy:    derefString(r.DeletedBy),
+		Attachments:  files,
+		Locale:       derefString(r.Locale),
+		Translations: translations,
+	}
+}
+
+func (s *PGAnnouncementStore) Save(af AnnouncementFile) error {
+	row := toAnnouncementRow(af)
+	return s.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&row).Error
+}
+
+func (s *PGAnnouncementStore) Delete(id string) error {
+	res := s.db.Where("id = ?", id).Delete(&models.Announcement{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errAnnouncementNotFound
+	}
+	if err := s.db.Where("\"announcementId\" = ?", id).Delete(&models.AnnouncementRevision{}).Error; err != nil {
+		return err
+	}
+	return s.db.Where("\"announcementId\" = ?", id).Delete(&models.AnnouncementState{}).Error
+}
+
+func (s *PGAnnouncementStore) Ping(ctx context.Context) error {
+	return s.db.WithContext(ctx).Exec(`SELECT 1 FROM "Announcement" LIMIT 1`).Error
+}
+
+func (s *PGAnnouncementStore) Get(id string) (*AnnouncementFile, error) {
+	var row models.Announcement
+	if err := s.db.First(&row, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errAnnouncementNotFound
+		}
+		return nil, err
+	}
+	af := fromAnnouncementRow(row)
+	return &af, nil
+}
+
+func (s *PGAnnouncementStore) List(f AnnouncementFilter) ([]AnnouncementFile, error) {
+	tx := s.db.Model(&models.Announcement{})
+	if f.Trashed {
+		tx = tx.Where("\"deletedAt\" IS NOT NULL")
+	} else {
+		tx = tx.Where("\"deletedAt\" IS NULL")
+	}
+	if f.Severity != "" {
+		tx = tx.Where("UPPER(severity) = ?", f.Severity)
+	}
+	if f.Status == StatusPublished {
+		tx = tx.Where("(status = ? OR status = '' OR status IS NULL)", StatusPublished)
+	} else if f.Status != "" {
+		tx = tx.Where("status = ?", f.Status)
+	}
+	if f.PublishedAfter != nil {
+		tx = tx.Where("\"publishAt\" > ?", *f.PublishedAfter)
+	}
+	if f.Archived {
+		tx = tx.Where("archived = true")
+	}
+	if f.Query != "" {
+		like := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(f.Query) + "%"
+		tx = tx.Where("(title ILIKE ? OR markdown ILIKE ?)", like, like)
+	}
+	if t := f.VisibleAt; t != nil {
+		tx = tx.Where("archived = false").
+			Where("(status = ? OR status = '' OR status IS NULL)", StatusPublished).
+			Where("(\"validFrom\" IS NULL OR \"validFrom\" <= ?)", *t).
+			Where("(\"validTo\" IS NULL OR \"validTo\" >= ?)", *t)
+	}
+	var rows []models.Announcement
+	if err := tx.Order("pinned desc, \"publishAt\" desc").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make([]AnnouncementFile, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, fromAnnouncementRow(r))
+	}
+	return out, nil
+}
+
+func (s *PGAnnouncementStore) UserState(uid string) (*userState, error) {
+	st := newUserState()
+	var rows []models.AnnouncementState
+	if err := s.db.Where("\"userId\" = ?", uid).Find(&rows).Error; err != nil {
+		return st, err
+	}
+	for _, r := range rows {
+		if r.Read {
+			st.Read[r.AnnouncementID] = true
+		}
+		if r.Fav {
+			st.Fav[r.AnnouncementID] = true
+		}
+	}
+	return st, nil
+}
+
+func (s *PGAnnouncementStore) MarkRead(uid string, ids []string) error {
+	now := time.Now()
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		for _, id := range ids {
+			if err := tx.Exec(`INSERT INTO "AnnouncementState" ("userId", "announcementId", read, fav, "readAt")
+				VALUES (?, ?, true, false, ?)
+				ON CONFLICT ("userId", "announcementId")
+				DO UPDATE SET read = true, "readAt" = COALESCE("AnnouncementState"."readAt", EXCLUDED."readAt")`,
+				uid, id, now).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// importRead marks id read at its original time, or an unknown one for
+// nil; an earlier time replaces a later one, e.g. from a past import.
+func (s *PGAnnouncementStore) importRead(uid, id string, at *time.Time) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var row models.AnnouncementState
+		err := tx.Where("\"userId\" = ? AND \"announcementId\" = ?", uid, id).First(&row).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return tx.Create(&models.AnnouncementState{UserID: uid, AnnouncementID: id, Read: true, ReadAt: at}).Error
+		}
+		if err != nil {
+			return err
+		}
+		updates := map[string]interface{}{"read": true}
+		if at != nil && (row.ReadAt == nil || at.Before(*row.ReadAt)) {
+			updates["readAt"] = *at
+		}
+		return tx.Model(&models.AnnouncementState{}).Where("\"userId\" = ? AND \"announcementId\" = ?", uid, id).Updates(updates).Error
+	})
+}
+
+func (s *PGAnnouncementStore) SetFavorite(uid, id string, fav bool) error {
+	return s.db.Exec(`INSERT INTO "AnnouncementState" ("userId", "announcementId", read, fav)
+		VALUES (?, ?, false, ?)
+		ON CONFLICT ("userId", "announcementId") DO UPDATE SET fav = EXCLUDED.fav`, uid, id, fav).Error
+}
+
+func (s *PGAnnouncementStore) Readers(id string) ([]AnnouncementReader, error) {
+	var rows []models.AnnouncementState
+	if err := s.db.Where("\"announcementId\" = ?", id).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make([]AnnouncementReader, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, AnnouncementReader{UserID: r.UserID, Read: r.Read, ReadAt: r.ReadAt, Fav: r.Fav})
+	}
+	return out, nil
+}
+
+func (s *PGAnnouncementStore) ArchiveBefore(cutoff time.Time) (int, error) {
+	res := s.db.Model(&models.Announcement{}).
+		Where("archived = false AND \"deletedAt\" IS NULL").
+		Where("GREATEST(\"publishAt\", COALESCE(\"unarchivedAt\", \"publishAt\")) < ?", cutoff).
+		Where("(status = ? OR status = '' OR status IS NULL)", StatusPublished).
+		Update("archived", true)
+	return int(res.RowsAffected), res.Error
+}
+
+func (s *PGAnnouncementStore) PublishScheduled(id string, at time.Time) (bool, error) {
+	res := s.db.Model(&models.Announcement{}).
+		Where("id = ? AND status = ?", id, StatusScheduled).
+		Updates(map[string]interface{}{"status": StatusPublished, "publishAt": at})
+	return res.RowsAffected > 0, res.Error
+}
+
+func (s *PGAnnouncementStore) AddRevision(r AnnouncementRevision) error {
+	err := s.db.Create(&models.AnnouncementRevision{
+		AnnouncementID: r.Snapshot.ID,
+		Rev:            r.Rev,
+		Action:         r.Action,
+		Editor:         r.Editor,
+		Snapshot:       string(mustJSON(r.Snapshot)),
+		CreateTime:     r.Time,
+	}).Error
+	if isUniqueViolation(err) {
+		return errRevisionTaken
+	}
+	return err
+}
+
+func isUniqueViolation(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "23505") || strings.Contains(msg, "duplicate") || strings.Contains(msg, "UNIQUE constraint")
+}
+
+func (s *PGAnnouncementStore) Revisions(id string) ([]AnnouncementRevision, error) {
+	var rows []models.AnnouncementRevision
+	if err := s.db.Where("\"announcementId\" = ?", id).Order("rev asc").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make([]AnnouncementRevision, 0, len(rows))
+	for _, r := range rows {
+		rev := AnnouncementRevision{Rev: r.Rev, Action: r.Action, Editor: r.Editor, Time: r.CreateTime}
+		if err := json.Unmarshal([]byte(r.Snapshot), &rev.Snapshot); err != nil {
+			return nil, err
+		}
+		out = append(out, rev)
+	}
+	return out, nil
+}
+
+// readImporter takes reads with their original time.
+type readImporter interface {
+	importRead(uid, id string, at *time.Time) error
+}
+
+// ImportAnnouncements copies every announcement, its revisions and user
+// state file from the directory layout (including legacy bases) into dst.
+// Re-running it is safe: announcements are upserted, revisions appended
+// only past what dst has, and state is merged. Read times are kept when
+// dst is a readImporter.
+func ImportAnnouncements(src *FileAnnouncementStore, dst AnnouncementStore) (int, int, error) {
+	items := src.scanAll()
+	for _, af := range items {
+		if err := dst.Save(af); err != nil {
+			return 0, 0, err
+		}
+		if err := copyRevisions(src, dst, af.ID); err != nil {
+			return 0, 0, err
+		}
+	}
+	users := 0
+	for _, uid := range src.userStateIDs() {
+		st := src.readUserState(uid)
+		if st == nil {
+			continue
+		}
+		if err := importReads(dst, uid, st); err != nil {
+			return len(items), users, err
+		}
+		for id, ok := range st.Fav {
+			if ok {
+				if err := dst.SetFavorite(uid, id, true); err != nil {
+					return len(items), users, err
+				}
+			}
+		}
+		users++
+	}
+	return len(items), users, nil
+}
+
+func importReads(dst AnnouncementStore, uid string, st *userState) error {
+	ri, ok := dst.(readImporter)
+	read := make([]string, 0, len(st.Read))
+	for id, r := range st.Read {
+		if !r {
+			continue
+		}
+		if !ok {
+			read = append(read, id)
+			continue
+		}
+		var at *time.Time
+		if t, has := st.ReadAt[id]; has {
+			at = &t
+		}
+		if err := ri.importRead(uid, id, at); err != nil {
+			return err
+		}
+	}
+	if len(read) == 0 {
+		return nil
+	}
+	return dst.MarkRead(uid, read)
+}
+
+// copyRevisions appends the revisions dst does not have yet.
+func copyRevisions(src, dst AnnouncementStore, id string) error {
+	revs, err := src.Revisions(id)
+	if err != nil || len(revs) == 0 {
+		return err
+	}
+	have, err := dst.Revisions(id)
+	if err != nil {
+		return err
+	}
+	for _, r := range revs {
+		if len(have) > 0 && r.Rev <= have[len(have)-1].Rev {
+			continue
+		}
+		if err := dst.AddRevision(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
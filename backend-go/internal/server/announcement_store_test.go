@@ -0,0 +1,117 @@
+package server
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"scholarhub/backend-go/internal/models"
+)
+
+func TestFileStoreListFiltersAndOrders(t *testing.T) {
+	now := time.Now().UTC()
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+	s := newTestStore(t,
+		AnnouncementFile{ID: "old", Title: "old", Severity: "NORMAL", PublishAt: now.Add(-2 * time.Hour)},
+		AnnouncementFile{ID: "pin", Title: "pin", Severity: "URGENT", Pinned: true, PublishAt: now.Add(-3 * time.Hour)},
+		AnnouncementFile{ID: "later", Title: "later", Severity: "NORMAL", PublishAt: now, ValidFrom: &future},
+		AnnouncementFile{ID: "gone", Title: "gone", Severity: "NORMAL", PublishAt: now, ValidTo: &past},
+	)
+
+	all, _ := s.List(AnnouncementFilter{})
+	if len(all) != 4 || all[0].ID != "pin" {
+		t.Fatalf("expected 4 items with pinned first, got %+v", all)
+	}
+	vis, _ := s.List(AnnouncementFilter{VisibleAt: &now})
+	if len(vis) != 2 {
+		t.Fatalf("expected 2 visible items, got %d", len(vis))
+	}
+	sev, _ := s.List(AnnouncementFilter{Severity: "URGENT"})
+	if len(sev) != 1 || sev[0].ID != "pin" {
+		t.Fatalf("severity filter failed: %+v", sev)
+	}
+	if n, _ := s.ArchiveBefore(now.Add(-90 * time.Minute)); n != 2 {
+		t.Fatalf("expected 2 archived, got %d", n)
+	}
+	if af, _ := s.Get("old"); af == nil || !af.Archived {
+		t.Fatalf("expected old to be archived")
+	}
+}
+
+func TestFileStoreUserStateConcurrentWrites(t *testing.T) {
+	s := newTestStore(t)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		id := strconv.Itoa(i)
+		go func() { defer wg.Done(); _ = s.MarkRead("u1", []string{id}) }()
+		go func() { defer wg.Done(); _ = s.SetFavorite("u1", id, true) }()
+	}
+	wg.Wait()
+	st, _ := s.UserState("u1")
+	if len(st.Read) != 20 || len(st.Fav) != 20 {
+		t.Fatalf("concurrent updates were lost: %d read, %d fav", len(st.Read), len(st.Fav))
+	}
+}
+
+func TestImportAnnouncementsFromLegacyLayout(t *testing.T) {
+	legacy := t.TempDir()
+	_ = os.MkdirAll(filepath.Join(legacy, "20240101"), 0755)
+	_ = os.MkdirAll(filepath.Join(legacy, "user_states"), 0755)
+	b, _ := json.Marshal(AnnouncementFile{ID: "a1", Title: "legacy", PublishAt: time.Now()})
+	_ = os.WriteFile(filepath.Join(legacy, "20240101", "a1.json"), b, 0644)
+	st, _ := json.Marshal(userState{Read: map[string]bool{"a1": true}, Fav: map[string]bool{"a1": true}})
+	_ = os.WriteFile(filepath.Join(legacy, "user_states", "u1.json"), st, 0644)
+
+	src := NewFileAnnouncementStore(t.TempDir(), []string{legacy})
+	dst := newTestStore(t)
+	n, users, err := ImportAnnouncements(src, dst)
+	if err != nil || n != 1 || users != 1 {
+		t.Fatalf("unexpected import result n=%d users=%d err=%v", n, users, err)
+	}
+	if af, _ := dst.Get("a1"); af == nil || af.Title != "legacy" {
+		t.Fatalf("announcement not imported")
+	}
+	got, _ := dst.UserState("u1")
+	if !got.Read["a1"] || !got.Fav["a1"] {
+		t.Fatalf("user state not imported: %+v", got)
+	}
+}
+
+func TestImportKeepsReadTimes(t *testing.T) {
+	readAt := time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC)
+	src := newTestStore(t, AnnouncementFile{ID: "a1", Title: "old", PublishAt: readAt}, AnnouncementFile{ID: "a2", Title: "older", PublishAt: readAt})
+	_ = src.writeUserState("u1", userState{Read: map[string]bool{"a1": true, "a2": true}, Fav: map[string]bool{}, ReadAt: map[string]time.Time{"a1": readAt}})
+	for name, dst := range map[string]AnnouncementStore{
+		"file":     newTestStore(t),
+		"postgres": NewPGAnnouncementStore(newTestDB(t, &models.Announcement{}, &models.AnnouncementState{}, &models.AnnouncementRevision{})),
+	} {
+		stamped := time.Now().UTC().Truncate(time.Second) // what an earlier import stamped
+		if err := dst.(readImporter).importRead("u1", "a1", &stamped); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 2; i++ {
+			if _, _, err := ImportAnnouncements(src, dst); err != nil {
+				t.Fatalf("%s: import: %v", name, err)
+			}
+		}
+		for _, id := range []string{"a1", "a2"} {
+			readers, _ := dst.Readers(id)
+			if len(readers) != 1 || !readers[0].Read {
+				t.Fatalf("%s: %s should be read, got %+v", name, id, readers)
+			}
+			got := readers[0].ReadAt
+			if id == "a1" && (got == nil || !got.Equal(readAt)) {
+				t.Fatalf("%s: the original read time should survive, got %v", name, got)
+			}
+			if id == "a2" && got != nil {
+				t.Fatalf("%s: a read without a time should stay without one, got %v", name, got)
+			}
+		}
+	}
+}
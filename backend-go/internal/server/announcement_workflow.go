@@ -0,0 +1,276 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Announcement workflow states; only PUBLISHED is visible to users.
+const (
+	StatusDraft     = "DRAFT"
+	StatusPending   = "PENDING"
+	StatusScheduled = "SCHEDULED"
+	StatusPublished = "PUBLISHED"
+)
+
+func announcementStatus(x AnnouncementFile) string {
+	if x.Status == "" {
+		return StatusPublished
+	}
+	return x.Status
+}
+
+// release schedules or publishes af and reports whether it went live now.
+func release(af *AnnouncementFile, now time.Time) bool {
+	if af.ScheduledAt != nil && af.ScheduledAt.After(now) {
+		af.Status = StatusScheduled
+		return false
+	}
+	af.Status = StatusPublished
+	af.PublishAt = now
+	return true
+}
+
+// submit sends a draft to review, or releases it when none is required.
+func (a *AnnouncementsController) submit(af *AnnouncementFile, uid string, now time.Time) bool {
+	af.SubmittedBy = uid
+	af.ReviewNote = ""
+	if a.requireApproval {
+		af.Status = StatusPending
+		return false
+	}
+	return release(af, now)
+}
+
+// contentFields need a fresh review when edited after submission.
+var contentFields = map[string]bool{"title": true, "scope": true, "audience": true, "locale": true, "translations": true}
+
+func contentEdited(prev, next AnnouncementFile) bool {
+	if prev.Markdown != next.Markdown {
+		return true
+	}
+	for _, ch := range diffFields(prev, next) {
+		if contentFields[ch.Field] {
+			return true
+		}
+	}
+	return false
+}
+
+// reopenForReview sends an edited item back to review; it returns false
+// for a published one, which must be unpublished first while approval is
+// required.
+func (a *AnnouncementsController) reopenForReview(af *AnnouncementFile, uid string) bool {
+	af.EditedBy = uid
+	af.ApprovedBy = ""
+	if !a.requireApproval {
+		return true
+	}
+	switch announcementStatus(*af) {
+	case StatusPublished:
+		return false
+	case StatusScheduled:
+		af.Status = StatusPending
+	}
+	return true
+}
+
+// announcePublished tells streams an item went live, with its audience.
+func announcePublished(af AnnouncementFile) {
+	hub.Publish(newEvent(TopicAnnouncement, "", "announcement", af.ID, gin.H{
+		"id": af.ID, "title": af.Title, "severity": af.Severity, "pinned": af.Pinned,
+		"scope": af.Scope, "audience": af.Audience,
+	}))
+}
+
+// announcementEventVisible applies an event's audience to v.
+func announcementEventVisible(data []byte, v *viewer) bool {
+	var x AnnouncementFile
+	if err := json.Unmarshal(data, &x); err != nil {
+		return false
+	}
+	return audienceAllows(x, v)
+}
+
+// startScheduler publishes scheduled items when due, checking at least
+// every interval.
+func (a *AnnouncementsController) startScheduler(interval time.Duration) {
+	a.wake = make(chan struct{}, 1)
+	a.stopCh = make(chan struct{})
+	go func() {
+		for {
+			next := a.publishDue(time.Now())
+			wait := interval
+			if !next.IsZero() {
+				if d := time.Until(next); d < wait {
+					wait = d
+				}
+			}
+			if wait < time.Second {
+				wait = time.Second
+			}
+			t := time.NewTimer(wait)
+			select {
+			case <-t.C:
+			case <-a.wake:
+				t.Stop()
+			case <-a.stopCh:
+				t.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the scheduler started by NewAnnouncementsController.
+func (a *AnnouncementsController) Stop() {
+	if a.stopCh != nil {
+		close(a.stopCh)
+	}
+}
+
+func (a *AnnouncementsController) wakeScheduler() {
+	if a.wake == nil {
+		return
+	}
+	select {
+	case a.wake <- struct{}{}:
+	default:
+	}
+}
+
+// publishDue releases due items and returns when the next one is due.
+func (a *AnnouncementsController) publishDue(now time.Time) time.Time {
+	items, err := a.store.List(AnnouncementFilter{Status: StatusScheduled})
+	if err != nil {
+		log.Printf("announcement scheduler list error: %v", err)
+		return time.Time{}
+	}
+	var next time.Time
+	for _, x := range items {
+		if x.ScheduledAt != nil && x.ScheduledAt.After(now) {
+			if next.IsZero() || x.ScheduledAt.Before(next) {
+				next = *x.ScheduledAt
+			}
+			continue
+		}
+		ok, err := a.store.PublishScheduled(x.ID, now)
+		if err != nil {
+			log.Printf("announcement publish error id=%s err=%v", x.ID, err)
+			continue
+		}
+		if ok {
+			prev := x
+			x.Status = StatusPublished
+			x.PublishAt = now
+			a.record(&prev, x, "", "PUBLISH")
+			announcePublished(x)
+			log.Printf("announcement published id=%s", x.ID)
+		}
+	}
+	return next
+}
+
+func (a *AnnouncementsController) transition(c *gin.Context, from, action string, apply func(af *AnnouncementFile, uid string, now time.Time) (bool, string)) {
+	id := c.Param("id")
+	af, err := a.store.Get(id)
+	if err != nil || af.DeletedAt != nil {
+		c.JSON(404, respErr(1006, "not_found"))
+		return
+	}
+	if announcementStatus(*af) != from {
+		c.JSON(400, respErr(1002, "invalid_status"))
+		return
+	}
+	prev := *af
+	uid := c.GetString("user_id")
+	live, code := apply(af, uid, time.Now().UTC())
+	if code != "" {
+		c.JSON(403, respErr(1007, code))
+		return
+	}
+	if err := a.store.Save(*af); err != nil {
+		c.JSON(500, respErr(1004, "write_error"))
+		return
+	}
+	a.record(&prev, *af, uid, action)
+	if live {
+		announcePublished(*af)
+	} else if af.Status == StatusScheduled {
+		a.wakeScheduler()
+	}
+	c.JSON(200, respOk(gin.H{"id": af.ID, "status": af.Status}))
+}
+
+// AdminSubmit moves a draft on to review or release.
+func (a *AnnouncementsController) AdminSubmit(c *gin.Context) {
+	a.transition(c, StatusDraft, "SUBMIT", func(af *AnnouncementFile, uid string, now time.Time) (bool, string) {
+		live := a.submit(af, uid, now)
+		a.logAction(uid, "SUBMIT_ANNOUNCEMENT", af.ID, gin.H{"title": af.Title, "status": af.Status})
+		return live, ""
+	})
+}
+
+// AdminApprove releases a pending item; its author or editors may not.
+func (a *AnnouncementsController) AdminApprove(c *gin.Context) {
+	a.transition(c, StatusPending, "APPROVE", func(af *AnnouncementFile, uid string, now time.Time) (bool, string) {
+		if uid == af.Publisher || uid == af.SubmittedBy || uid == af.EditedBy {
+			return false, "self_approval"
+		}
+		af.ApprovedBy = uid
+		live := release(af, now)
+		a.logAction(uid, "APPROVE_ANNOUNCEMENT", af.ID, gin.H{"title": af.Title, "status": af.Status})
+		return live, ""
+	})
+}
+
+// AdminReject sends a pending announcement back to draft with a note.
+func (a *AnnouncementsController) AdminReject(c *gin.Context) {
+	var req struct {
+		Note string `json:"note"`
+	}
+	_ = c.ShouldBindJSON(&req)
+	a.transition(c, StatusPending, "REJECT", func(af *AnnouncementFile, uid string, now time.Time) (bool, string) {
+		af.Status = StatusDraft
+		af.ReviewNote = strings.TrimSpace(req.Note)
+		a.logAction(uid, "REJECT_ANNOUNCEMENT", af.ID, gin.H{"title": af.Title, "note": af.ReviewNote})
+		return false, ""
+	})
+}
+
+// AdminWithdraw returns a scheduled item to draft.
+func (a *AnnouncementsController) AdminWithdraw(c *gin.Context) {
+	a.transition(c, StatusScheduled, "WITHDRAW", func(af *AnnouncementFile, uid string, now time.Time) (bool, string) {
+		af.Status = StatusDraft
+		af.ApprovedBy = ""
+		a.logAction(uid, "WITHDRAW_ANNOUNCEMENT", af.ID, gin.H{"title": af.Title})
+		return false, ""
+	})
+}
+
+// AdminUnpublish takes a published item offline as a draft, so it can be
+// edited and submitted again.
+func (a *AnnouncementsController) AdminUnpublish(c *gin.Context) {
+	a.transition(c, StatusPublished, "UNPUBLISH", func(af *AnnouncementFile, uid string, now time.Time) (bool, string) {
+		af.Status = StatusDraft
+		af.ApprovedBy = ""
+		a.logAction(uid, "UNPUBLISH_ANNOUNCEMENT", af.ID, gin.H{"title": af.Title})
+		return false, ""
+	})
+}
+
+// AdminPreview renders markdown without saving it.
+func (a *AnnouncementsController) AdminPreview(c *gin.Context) {
+	var req struct {
+		Markdown string `json:"markdown"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, respErr(1002, "bad_request"))
+		return
+	}
+	c.JSON(200, respOk(gin.H{"html": renderMarkdown(strings.TrimSpace(req.Markdown))}))
+}
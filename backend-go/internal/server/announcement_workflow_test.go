@@ -0,0 +1,147 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"scholarhub/backend-go/internal/models"
+)
+
+func TestPublishDueReleasesScheduledOnce(t *testing.T) {
+	now := time.Now().UTC()
+	due := now.Add(-time.Minute)
+	later := now.Add(time.Hour)
+	store := newTestStore(t,
+		AnnouncementFile{ID: "due", Title: "due", Status: StatusScheduled, ScheduledAt: &due, PublishAt: now.Add(-time.Hour)},
+		AnnouncementFile{ID: "later", Title: "later", Status: StatusScheduled, ScheduledAt: &later, PublishAt: now},
+		AnnouncementFile{ID: "draft", Title: "draft", Status: StatusDraft, PublishAt: now},
+	)
+	a := &AnnouncementsController{store: store}
+
+	sub := hub.Subscribe(TopicAnnouncement, "u1")
+	defer hub.Unsubscribe(sub)
+
+	next := a.publishDue(now)
+	if !next.Equal(later) {
+		t.Fatalf("expected next due %v, got %v", later, next)
+	}
+	select {
+	case e := <-sub.C:
+		if e.ID != "due" {
+			t.Fatalf("unexpected event %+v", e)
+		}
+	default:
+		t.Fatalf("expected a publication event")
+	}
+	af, _ := store.Get("due")
+	if af.Status != StatusPublished || !af.PublishAt.Equal(now) {
+		t.Fatalf("expected due to be published at now, got %+v", af)
+	}
+	if revs, _ := store.Revisions("due"); len(revs) != 2 || revs[1].Action != "PUBLISH" || revs[1].Snapshot.Status != StatusPublished {
+		t.Fatalf("publication should be recorded as a revision, got %+v", revs)
+	}
+	vis, _ := store.List(AnnouncementFilter{VisibleAt: &now})
+	if len(vis) != 1 || vis[0].ID != "due" {
+		t.Fatalf("only the published item should be visible, got %+v", vis)
+	}
+
+	a.publishDue(now)
+	select {
+	case e := <-sub.C:
+		t.Fatalf("published twice: %+v", e)
+	default:
+	}
+}
+
+func TestSubmitRequiresSecondAdmin(t *testing.T) {
+	a := &AnnouncementsController{requireApproval: true}
+	now := time.Now()
+	af := AnnouncementFile{ID: "x", Status: StatusDraft}
+	if a.submit(&af, "admin1", now) || af.Status != StatusPending || af.SubmittedBy != "admin1" {
+		t.Fatalf("expected pending review, got %+v", af)
+	}
+	a.requireApproval = false
+	later := now.Add(time.Hour)
+	af = AnnouncementFile{ID: "y", Status: StatusDraft, ScheduledAt: &later}
+	if a.submit(&af, "admin1", now) || af.Status != StatusScheduled {
+		t.Fatalf("expected scheduled, got %+v", af)
+	}
+	af = AnnouncementFile{ID: "z", Status: StatusDraft}
+	if !a.submit(&af, "admin1", now) || af.Status != StatusPublished {
+		t.Fatalf("expected immediate publish, got %+v", af)
+	}
+}
+
+func TestContentEditsNeedFreshReview(t *testing.T) {
+	later := time.Now().UTC().Add(time.Hour)
+	store := newTestStore(t,
+		AnnouncementFile{ID: "s", Title: "s", Publisher: "author", SubmittedBy: "author", ApprovedBy: "reviewer", Status: StatusScheduled, ScheduledAt: &later},
+		AnnouncementFile{ID: "p", Title: "p", Publisher: "author", ApprovedBy: "reviewer", Status: StatusPublished},
+	)
+	a := &AnnouncementsController{db: newTestDB(t, &models.AdminLog{}), store: store, requireApproval: true}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	uid := ""
+	r.Use(func(c *gin.Context) { c.Set("user_id", uid) })
+	r.PUT("/announcements/:id", a.AdminUpdate)
+	r.POST("/announcements/:id/approve", a.AdminApprove)
+	r.POST("/announcements/:id/unpublish", a.AdminUnpublish)
+	r.POST("/announcements/:id/submit", a.AdminSubmit)
+
+	uid = "editor"
+	if code, _ := doJSON(t, r, "PUT", "/announcements/s", gin.H{"title": "changed"}); code != 200 {
+		t.Fatalf("editing a scheduled item should succeed, got %d", code)
+	}
+	af, _ := store.Get("s")
+	if af.Status != StatusPending || af.ApprovedBy != "" || af.EditedBy != "editor" {
+		t.Fatalf("an edited scheduled item should go back to review, got %+v", af)
+	}
+	for _, who := range []string{"author", "editor"} {
+		uid = who
+		if code, resp := doJSON(t, r, "POST", "/announcements/s/approve", nil); code != 403 || resp.Msg != "self_approval" {
+			t.Fatalf("%s must not approve, got %d %+v", who, code, resp)
+		}
+	}
+	uid = "reviewer"
+	if code, _ := doJSON(t, r, "POST", "/announcements/s/approve", nil); code != 200 {
+		t.Fatalf("a third admin may approve, got %d", code)
+	}
+	revs, _ := store.Revisions("s")
+	if len(revs) != 3 || revs[1].Action != "UPDATE" || revs[2].Action != "APPROVE" || revs[2].Editor != "reviewer" {
+		t.Fatalf("every transition should be recorded, got %+v", revs)
+	}
+
+	if code, resp := doJSON(t, r, "PUT", "/announcements/p", gin.H{"markdown": "new body"}); code != 409 || resp.Code != 1003 {
+		t.Fatalf("editing published content should be rejected, got %d %+v", code, resp)
+	}
+	if code, _ := doJSON(t, r, "PUT", "/announcements/p", gin.H{"pinned": true}); code != 200 {
+		t.Fatalf("pinning is not a content edit, got %d", code)
+	}
+	if af, _ := store.Get("p"); af.Markdown != "" || !af.Pinned || af.ApprovedBy != "reviewer" {
+		t.Fatalf("unexpected published item %+v", af)
+	}
+
+	uid = "editor"
+	if code, _ := doJSON(t, r, "POST", "/announcements/p/unpublish", nil); code != 200 {
+		t.Fatalf("unpublish: %d", code)
+	}
+	if code, _ := doJSON(t, r, "PUT", "/announcements/p", gin.H{"markdown": "fixed typo"}); code != 200 {
+		t.Fatalf("an unpublished item can be edited, got %d", code)
+	}
+	if code, _ := doJSON(t, r, "POST", "/announcements/p/submit", nil); code != 200 {
+		t.Fatalf("submit: %d", code)
+	}
+	uid = "reviewer"
+	if code, _ := doJSON(t, r, "POST", "/announcements/p/approve", nil); code != 200 {
+		t.Fatalf("approve: %d", code)
+	}
+	if af, _ := store.Get("p"); af.Status != StatusPublished || af.Markdown != "fixed typo" || af.ApprovedBy != "reviewer" {
+		t.Fatalf("the fix should go live after review, got %+v", af)
+	}
+	revs, _ = store.Revisions("p")
+	if got := revs[len(revs)-4].Action; got != "UNPUBLISH" {
+		t.Fatalf("unpublishing should be recorded, got %s", got)
+	}
+}
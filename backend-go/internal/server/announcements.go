@@ -3,16 +3,13 @@ package server
 import (
 	"encoding/json"
 	"fmt"
-	"io/fs"
 	"math/rand"
 	"os"
-	"path/filepath"
 	"regexp"
 	"runtime"
 	"sort"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"scholarhub/backend-go/internal/models"
@@ -22,11 +19,15 @@ import (
 )
 
 type AnnouncementsController struct {
-	db          *gorm.DB
-	base        string
-	mu          sync.Mutex
-	locks       sync.Map
-	legacyBases []string
+	db    *gorm.DB
+	store AnnouncementStore
+	// requireApproval turns on two-person review: whoever submits an
+	// announcement cannot be the one who approves it.
+	requireApproval bool
+	wake            chan struct{}
+	stopCh          chan struct{}
+	// archiveDays is the retention period; see archiveRetentionDays.
+	archiveDays int
 }
 
 type AnnouncementFile struct {
@@ -35,6 +36,7 @@ type AnnouncementFile struct {
 	Severity  string     `json:"severity"`
 	Pinned    bool       `json:"pinned"`
 	Scope     string     `json:"scope"`
+	Audience  *Audience  `json:"audience,omitempty"`
 	Publisher string     `json:"publisher"`
 	PublishAt time.Time  `json:"publishAt"`
 	ValidFrom *time.Time `json:"validFrom"`
@@ -42,38 +44,46 @@ type AnnouncementFile struct {
 	Markdown  string     `json:"markdown"`
 	HTML      string     `json:"html"`
 	Archived  bool       `json:"archived"`
-	Read      bool       `json:"read,omitempty"`
-	Fav       bool       `json:"fav,omitempty"`
+	// UnarchivedAt restarts the retention clock when an admin brings an
+	// archived item back.
+	UnarchivedAt *time.Time `json:"unarchivedAt,omitempty"`
+	// Status is DRAFT, PENDING, SCHEDULED or PUBLISHED; files written before
+	// the workflow existed have none and count as published.
+	Status      string                   `json:"status,omitempty"`
+	ScheduledAt *time.Time               `json:"scheduledAt,omitempty"`
+	SubmittedBy string                   `json:"submittedBy,omitempty"`
+	ApprovedBy  string                   `json:"approvedBy,omitempty"`
+	EditedBy    string                   `json:"editedBy,omitempty"`
+	ReviewNote  string                   `json:"reviewNote,omitempty"`
+	DeletedAt   *time.Time               `json:"deletedAt,omitempty"`
+	DeletedBy   string                   `json:"deletedBy,omitempty"`
+	Attachments []AnnouncementAttachment `json:"attachments,omitempty"`
+	// Locale is the language of Title and Markdown (empty: defaultLocale);
+	// Translations holds the other languages by locale.
+	Locale       string                             `json:"locale,omitempty"`
+	Translations map[string]AnnouncementTranslation `json:"translations,omitempty"`
+	// Lang and MissingLocales are filled in for responses only.
+	Lang           string   `json:"lang,omitempty"`
+	MissingLocales []string `json:"missingLocales,omitempty"`
+	Read           bool     `json:"read,omitempty"`
+	Fav            bool     `json:"fav,omitempty"`
 }
 
-func NewAnnouncementsController(db *gorm.DB) *AnnouncementsController {
-	dir := os.Getenv("ANNOUNCE_DIR")
-	if dir == "" {
-		if pr := os.Getenv("PROJECT_ROOT"); pr != "" {
-			dir = filepath.Join(pr, "announcements")
-		} else if runtimeOS() == "windows" {
-			dir = "announcements"
-		} else {
-			dir = "/var/announcements"
-		}
-	}
-	_ = os.MkdirAll(dir, 0755)
-	legacy := make([]string, 0, 2)
-	if runtimeOS() == "windows" {
-		if dir != "announcements" {
-			if fi, err := os.Stat("announcements"); err == nil && fi.IsDir() {
-				legacy = append(legacy, "announcements")
-			}
-		}
-	} else {
-		if dir != "/var/announcements" {
-			if fi, err := os.Stat("/var/announcements"); err == nil && fi.IsDir() {
-				legacy = append(legacy, "/var/announcements")
-			}
-		}
+// NewAnnouncementStore picks the store from ANNOUNCE_STORE: "postgres" uses
+// the database tables, anything else the JSON file layout.
+func NewAnnouncementStore(db *gorm.DB) AnnouncementStore {
+	if strings.EqualFold(os.Getenv("ANNOUNCE_STORE"), "postgres") {
+		return NewPGAnnouncementStore(db)
 	}
-	ac := &AnnouncementsController{db: db, base: dir, legacyBases: legacy}
-	ac.startArchive(90)
+	return NewFileAnnouncementStore(AnnounceDirs())
+}
+
+// NewAnnouncementsController serves announcements from NewAnnouncementStore.
+// ANNOUNCE_APPROVAL=required makes every announcement go through review.
+func NewAnnouncementsController(db *gorm.DB) *AnnouncementsController {
+	store := NewAnnouncementStore(db)
+	ac := &AnnouncementsController{db: db, store: store, requireApproval: strings.EqualFold(os.Getenv("ANNOUNCE_APPROVAL"), "required"), archiveDays: archiveRetentionDays()}
+	ac.startScheduler(30 * time.Second)
 	return ac
 }
 
@@ -83,13 +93,18 @@ func runtimeOS() string {
 
 func (a *AnnouncementsController) AdminCreate(c *gin.Context) {
 	var req struct {
-		Title     string `json:"title"`
-		Markdown  string `json:"markdown"`
-		Scope     string `json:"scope"`
-		Severity  string `json:"severity"`
-		Pinned    *bool  `json:"pinned"`
-		ValidFrom *int64 `json:"validFrom"`
-		ValidTo   *int64 `json:"validTo"`
+		Title        string                             `json:"title"`
+		Markdown     string                             `json:"markdown"`
+		Scope        string                             `json:"scope"`
+		Audience     *Audience                          `json:"audience"`
+		Severity     string                             `json:"severity"`
+		Pinned       *bool                              `json:"pinned"`
+		ValidFrom    *int64                             `json:"validFrom"`
+		ValidTo      *int64                             `json:"validTo"`
+		PublishAt    *int64                             `json:"publishAt"`
+		Draft        bool                               `json:"draft"`
+		Locale       string                             `json:"locale"`
+		Translations map[string]AnnouncementTranslation `json:"translations"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(400, respErr(1002, "bad_request"))
@@ -101,9 +116,22 @@ func (a *AnnouncementsController) AdminCreate(c *gin.Context) {
 		return
 	}
 	md := strings.TrimSpace(req.Markdown)
-	scope := strings.ToUpper(strings.TrimSpace(req.Scope))
-	if scope == "" {
-		scope = "ALL"
+	scope, audience, code := a.resolveAudience(req.Scope, req.Audience)
+	if code != "" {
+		c.JSON(400, respErr(1002, code))
+		return
+	}
+	locale := strings.ToLower(strings.TrimSpace(req.Locale))
+	if locale == "" {
+		locale = defaultLocale()
+	} else if !isSupportedLocale(locale) {
+		c.JSON(400, respErr(1002, "invalid_locale"))
+		return
+	}
+	translations, code := parseTranslations(req.Translations, locale)
+	if code != "" {
+		c.JSON(400, respErr(1002, code))
+		return
 	}
 	sev := strings.ToUpper(strings.TrimSpace(req.Severity))
 	if sev == "" {
@@ -119,32 +147,50 @@ func (a *AnnouncementsController) AdminCreate(c *gin.Context) {
 		t := time.UnixMilli(*req.ValidTo)
 		vt = &t
 	}
-	html := sanitizeHTML(md2html(md))
+	html := renderMarkdown(md)
 	id := genID()
 	now := time.Now().UTC()
-	fn := a.makePath(now, id)
 	pinned := false
 	if req.Pinned != nil {
 		pinned = *req.Pinned
 	}
-	if err := a.writeJSON(fn, AnnouncementFile{
-		ID:        id,
-		Title:     title,
-		Severity:  sev,
-		Pinned:    pinned,
-		Scope:     scope,
-		Publisher: c.GetString("user_id"),
-		PublishAt: now,
-		ValidFrom: vf,
-		ValidTo:   vt,
-		Markdown:  md,
-		HTML:      html,
-	}); err != nil {
+	af := AnnouncementFile{
+		ID:           id,
+		Title:        title,
+		Severity:     sev,
+		Pinned:       pinned,
+		Scope:        scope,
+		Audience:     audience,
+		Publisher:    c.GetString("user_id"),
+		PublishAt:    now,
+		ValidFrom:    vf,
+		ValidTo:      vt,
+		Markdown:     md,
+		HTML:         html,
+		Status:       StatusDraft,
+		Locale:       locale,
+		Translations: translations,
+	}
+	if req.PublishAt != nil && *req.PublishAt > 0 {
+		t := time.UnixMilli(*req.PublishAt).UTC()
+		af.ScheduledAt = &t
+	}
+	live := false
+	if !req.Draft {
+		live = a.submit(&af, c.GetString("user_id"), now)
+	}
+	if err := a.store.Save(af); err != nil {
 		c.JSON(500, respErr(1004, "write_error"))
 		return
 	}
-	a.logAction(c.GetString("user_id"), "CREATE_ANNOUNCEMENT", id, gin.H{"title": title, "severity": sev, "scope": scope})
-	c.JSON(200, respOk(gin.H{"id": id}))
+	a.record(nil, af, c.GetString("user_id"), "CREATE")
+	a.logAction(c.GetString("user_id"), "CREATE_ANNOUNCEMENT", id, gin.H{"title": title, "severity": sev, "scope": scope, "status": af.Status})
+	if live {
+		announcePublished(af)
+	} else if af.Status == StatusScheduled {
+		a.wakeScheduler()
+	}
+	c.JSON(200, respOk(gin.H{"id": id, "status": af.Status}))
 }
 
 func (a *AnnouncementsController) logAction(adminID, actionType, targetID string, details interface{}) {
@@ -165,23 +211,28 @@ func (a *AnnouncementsController) logAction(adminID, actionType, targetID string
 func (a *AnnouncementsController) AdminUpdate(c *gin.Context) {
 	id := c.Param("id")
 	var req struct {
-		Title     *string `json:"title"`
-		Markdown  *string `json:"markdown"`
-		Scope     *string `json:"scope"`
-		Severity  *string `json:"severity"`
-		Pinned    *bool   `json:"pinned"`
-		ValidFrom *int64  `json:"validFrom"`
-		ValidTo   *int64  `json:"validTo"`
+		Title        *string                             `json:"title"`
+		Markdown     *string                             `json:"markdown"`
+		Scope        *string                             `json:"scope"`
+		Audience     *Audience                           `json:"audience"`
+		Severity     *string                             `json:"severity"`
+		Pinned       *bool                               `json:"pinned"`
+		ValidFrom    *int64                              `json:"validFrom"`
+		ValidTo      *int64                              `json:"validTo"`
+		PublishAt    *int64                              `json:"publishAt"`
+		Locale       *string                             `json:"locale"`
+		Translations *map[string]AnnouncementTranslation `json:"translations"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(400, respErr(1002, "bad_request"))
 		return
 	}
-	af, path := a.findByID(id)
-	if af == nil {
+	af, err := a.store.Get(id)
+	if err != nil || af.DeletedAt != nil {
 		c.JSON(404, respErr(1006, "not_found"))
 		return
 	}
+	prev := *af
 	if req.Title != nil {
 		t := strings.TrimSpace(*req.Title)
 		if t == "" || len([]rune(t)) > 100 {
@@ -193,14 +244,39 @@ func (a *AnnouncementsController) AdminUpdate(c *gin.Context) {
 	if req.Markdown != nil {
 		md := strings.TrimSpace(*req.Markdown)
 		af.Markdown = md
-		af.HTML = sanitizeHTML(md2html(md))
+		af.HTML = renderMarkdown(md)
 	}
-	if req.Scope != nil {
-		s := strings.ToUpper(strings.TrimSpace(*req.Scope))
-		if s == "" {
-			s = "ALL"
+	if req.Locale != nil {
+		l := strings.ToLower(strings.TrimSpace(*req.Locale))
+		if !isSupportedLocale(l) {
+			c.JSON(400, respErr(1002, "invalid_locale"))
+			return
+		}
+		af.Locale = l
+	}
+	if req.Translations != nil {
+		tr, code := parseTranslations(*req.Translations, announcementLocale(*af))
+		if code != "" {
+			c.JSON(400, respErr(1002, code))
+			return
+		}
+		af.Translations = tr
+	} else if _, clash := af.Translations[announcementLocale(*af)]; clash {
+		c.JSON(400, respErr(1002, "invalid_locale"))
+		return
+	}
+	if req.Scope != nil || req.Audience != nil {
+		scope := ""
+		if req.Scope != nil {
+			scope = *req.Scope
+		}
+		s, au, code := a.resolveAudience(scope, req.Audience)
+		if code != "" {
+			c.JSON(400, respErr(1002, code))
+			return
 		}
 		af.Scope = s
+		af.Audience = au
 	}
 	if req.Severity != nil {
 		s := strings.ToUpper(strings.TrimSpace(*req.Severity))
@@ -220,26 +296,55 @@ func (a *AnnouncementsController) AdminUpdate(c *gin.Context) {
 		t := time.UnixMilli(*req.ValidTo)
 		af.ValidTo = &t
 	}
-	if err := a.writeJSON(path, *af); err != nil {
+	if req.PublishAt != nil {
+		// Once live, the publish time is history and cannot be moved.
+		if announcementStatus(*af) == StatusPublished {
+			c.JSON(400, respErr(1002, "invalid_status"))
+			return
+		}
+		if *req.PublishAt > 0 {
+			t := time.UnixMilli(*req.PublishAt).UTC()
+			af.ScheduledAt = &t
+		} else {
+			af.ScheduledAt = nil
+		}
+	}
+	if contentEdited(prev, *af) && !a.reopenForReview(af, c.GetString("user_id")) {
+		c.JSON(409, respErr(1003, "review_required"))
+		return
+	}
+	if err := a.store.Save(*af); err != nil {
 		c.JSON(500, respErr(1004, "write_error"))
 		return
 	}
-	a.logAction(c.GetString("user_id"), "UPDATE_ANNOUNCEMENT", id, gin.H{"title": af.Title})
+	if af.Status == StatusScheduled {
+		a.wakeScheduler()
+	}
+	rev := a.record(&prev, *af, c.GetString("user_id"), "UPDATE")
+	a.logAction(c.GetString("user_id"), "UPDATE_ANNOUNCEMENT", id, gin.H{"title": af.Title, "rev": rev})
 	c.JSON(200, respOk(gin.H{"ok": true}))
 }
 
+// AdminDelete moves an announcement to the trash; AdminRestore brings it
+// back and AdminPurge removes it for good.
 func (a *AnnouncementsController) AdminDelete(c *gin.Context) {
 	id := c.Param("id")
-	af, path := a.findByID(id)
-	if af == nil {
+	af, err := a.store.Get(id)
+	if err != nil || af.DeletedAt != nil {
 		c.JSON(404, respErr(1006, "not_found"))
 		return
 	}
-	if err := os.Remove(path); err != nil {
+	prev := *af
+	uid := c.GetString("user_id")
+	now := time.Now().UTC()
+	af.DeletedAt = &now
+	af.DeletedBy = uid
+	if err := a.store.Save(*af); err != nil {
 		c.JSON(500, respErr(1004, "delete_error"))
 		return
 	}
-	a.logAction(c.GetString("user_id"), "DELETE_ANNOUNCEMENT", id, nil)
+	a.record(&prev, *af, uid, "DELETE")
+	a.logAction(uid, "DELETE_ANNOUNCEMENT", id, gin.H{"title": af.Title})
 	c.JSON(200, respOk(gin.H{"ok": true}))
 }
 
@@ -253,22 +358,23 @@ func (a *AnnouncementsController) AdminList(c *gin.Context) {
 		pageSize = 10
 	}
 	sev := strings.ToUpper(strings.TrimSpace(c.Query("severity")))
-	items := a.scanAll()
-	if sev != "" {
-		tmp := make([]AnnouncementFile, 0, len(items))
-		for _, x := range items {
-			if strings.ToUpper(x.Severity) == sev {
-				tmp = append(tmp, x)
-			}
-		}
-		items = tmp
+	status := strings.ToUpper(strings.TrimSpace(c.Query("status")))
+	items, err := a.store.List(AnnouncementFilter{Severity: sev, Status: status})
+	if err != nil {
+		c.JSON(500, respErr(1004, "db_error"))
+		return
 	}
-	sort.Slice(items, func(i, j int) bool {
-		if items[i].Pinned != items[j].Pinned {
-			return items[i].Pinned && !items[j].Pinned
+	// untranslated=1 keeps only announcements missing a language.
+	untranslated := c.Query("untranslated") == "1" || c.Query("untranslated") == "true"
+	kept := items[:0]
+	for _, x := range items {
+		x.MissingLocales = missingLocales(x)
+		if untranslated && len(x.MissingLocales) == 0 {
+			continue
 		}
-		return items[i].PublishAt.After(items[j].PublishAt)
-	})
+		kept = append(kept, x)
+	}
+	items = kept
 	total := len(items)
 	start := (page - 1) * pageSize
 	if start > total {
@@ -292,42 +398,25 @@ func (a *AnnouncementsController) PublicList(c *gin.Context) {
 	}
 	sev := strings.ToUpper(strings.TrimSpace(c.Query("severity")))
 	status := strings.ToLower(strings.TrimSpace(c.Query("status")))
-	now := time.Now()
 	uid := c.GetString("user_id")
-	st := a.readUserState(uid)
-	items := a.scanAll()
+	st, _ := a.store.UserState(uid)
+	now := time.Now()
+	items, err := a.visibleTo(loadViewer(a.db, uid, c.GetString("role")), AnnouncementFilter{Severity: sev, VisibleAt: &now})
+	if err != nil {
+		c.JSON(500, respErr(1004, "db_error"))
+		return
+	}
+	locale := a.readerLocale(c)
 	out := make([]AnnouncementFile, 0, len(items))
 	for _, x := range items {
-		if x.Archived {
-			continue
-		}
-		if x.ValidFrom != nil && now.Before(*x.ValidFrom) {
-			continue
-		}
-		if x.ValidTo != nil && now.After(*x.ValidTo) {
-			continue
-		}
-		if sev != "" && strings.ToUpper(x.Severity) != sev {
-			continue
-		}
-		if strings.ToUpper(x.Scope) != "ALL" {
-			continue
-		}
-		if st != nil {
-			x.Read = st.Read[x.ID]
-			x.Fav = st.Fav[x.ID]
-		}
+		x = localize(x, locale)
+		x.Read = st.Read[x.ID]
+		x.Fav = st.Fav[x.ID]
 		if status == "unread" && x.Read {
 			continue
 		}
 		out = append(out, x)
 	}
-	sort.Slice(out, func(i, j int) bool {
-		if out[i].Pinned != out[j].Pinned {
-			return out[i].Pinned && !out[j].Pinned
-		}
-		return out[i].PublishAt.After(out[j].PublishAt)
-	})
 	total := len(out)
 	start := (page - 1) * pageSize
 	if start > total {
@@ -342,19 +431,21 @@ func (a *AnnouncementsController) PublicList(c *gin.Context) {
 
 func (a *AnnouncementsController) PublicDetail(c *gin.Context) {
 	id := c.Param("id")
-	af, _ := a.findByID(id)
-	if af == nil {
+	af, err := a.store.Get(id)
+	uid := c.GetString("user_id")
+	if err != nil || af.DeletedAt != nil || announcementStatus(*af) != StatusPublished || !audienceAllows(*af, loadViewer(a.db, uid, c.GetString("role"))) {
 		c.JSON(404, respErr(1006, "not_found"))
 		return
 	}
-	uid := c.GetString("user_id")
 	if uid != "" {
-		if st := a.readUserState(uid); st != nil {
+		if st, err := a.store.UserState(uid); err == nil {
 			af.Read = st.Read[af.ID]
 			af.Fav = st.Fav[af.ID]
 		}
 	}
-	c.JSON(200, respOk(af))
+	out := localize(*af, a.readerLocale(c))
+	signAttachments(&out, uid, "/api")
+	c.JSON(200, respOk(out))
 }
 
 func (a *AnnouncementsController) PublicMarkRead(c *gin.Context) {
@@ -364,12 +455,7 @@ func (a *AnnouncementsController) PublicMarkRead(c *gin.Context) {
 		c.JSON(401, respErr(1001, "unauthorized"))
 		return
 	}
-	st := a.readUserState(uid)
-	if st == nil {
-		st = &userState{Read: map[string]bool{}, Fav: map[string]bool{}}
-	}
-	st.Read[id] = true
-	_ = a.writeUserState(uid, *st)
+	_ = a.store.MarkRead(uid, []string{id})
 	c.JSON(200, respOk(gin.H{"ok": true}))
 }
 
@@ -380,17 +466,14 @@ func (a *AnnouncementsController) PublicToggleFavorite(c *gin.Context) {
 		c.JSON(401, respErr(1001, "unauthorized"))
 		return
 	}
-	st := a.readUserState(uid)
-	if st == nil {
-		st = &userState{Read: map[string]bool{}, Fav: map[string]bool{}}
-	}
-	if st.Fav[id] {
-		delete(st.Fav, id)
-	} else {
-		st.Fav[id] = true
+	st, err := a.store.UserState(uid)
+	if err != nil {
+		c.JSON(500, respErr(1004, "db_error"))
+		return
 	}
-	_ = a.writeUserState(uid, *st)
-	c.JSON(200, respOk(gin.H{"fav": st.Fav[id]}))
+	fav := !st.Fav[id]
+	_ = a.store.SetFavorite(uid, id, fav)
+	c.JSON(200, respOk(gin.H{"fav": fav}))
 }
 
 func (a *AnnouncementsController) PublicBatchMarkRead(c *gin.Context) {
@@ -410,16 +493,13 @@ func (a *AnnouncementsController) PublicBatchMarkRead(c *gin.Context) {
 		c.JSON(200, respOk(gin.H{"ok": true}))
 		return
 	}
-	st := a.readUserState(uid)
-	if st == nil {
-		st = &userState{Read: map[string]bool{}, Fav: map[string]bool{}}
-	}
+	ids := make([]string, 0, len(req.IDs))
 	for _, id := range req.IDs {
 		if id = strings.TrimSpace(id); id != "" {
-			st.Read[id] = true
+			ids = append(ids, id)
 		}
 	}
-	_ = a.writeUserState(uid, *st)
+	_ = a.store.MarkRead(uid, ids)
 	c.JSON(200, respOk(gin.H{"ok": true}))
 }
 
@@ -429,273 +509,90 @@ func (a *AnnouncementsController) PublicUnreadCount(c *gin.Context) {
 		c.JSON(200, respOk(gin.H{"count": 0}))
 		return
 	}
-	st := a.readUserState(uid)
-	items := a.scanAll()
+	st, _ := a.store.UserState(uid)
 	now := time.Now()
+	items, _ := a.visibleTo(loadViewer(a.db, uid, c.GetString("role")), AnnouncementFilter{VisibleAt: &now})
 	count := 0
 	for _, x := range items {
-		if x.Archived {
-			continue
-		}
-		if x.ValidFrom != nil && now.Before(*x.ValidFrom) {
-			continue
-		}
-		if x.ValidTo != nil && now.After(*x.ValidTo) {
-			continue
-		}
-		if strings.ToUpper(x.Scope) != "ALL" {
-			continue
-		}
-		if st == nil || !st.Read[x.ID] {
+		if !st.Read[x.ID] {
 			count++
 		}
 	}
 	c.JSON(200, respOk(gin.H{"count": count}))
 }
 
-func (a *AnnouncementsController) makePath(t time.Time, id string) string {
-	dir := filepath.Join(a.base, t.Format("20060102"))
-	_ = os.MkdirAll(dir, 0755)
-	return filepath.Join(dir, id+".json")
-}
-
-func (a *AnnouncementsController) writeJSON(path string, v AnnouncementFile) error {
-	a.getLock(v.ID).Lock()
-	defer a.getLock(v.ID).Unlock()
-	tmp := path + ".tmp"
-	b, _ := json.Marshal(v)
-	if err := os.WriteFile(tmp, b, 0644); err != nil {
-		return err
-	}
-	if err := os.Chmod(tmp, 0644); err != nil {
-		return err
-	}
-	return os.Rename(tmp, path)
-}
-
-func (a *AnnouncementsController) scanAll() []AnnouncementFile {
-	roots := append([]string{a.base}, a.legacyBases...)
-	m := make(map[string]AnnouncementFile, 64)
-	for _, root := range roots {
-		_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
-			if err != nil {
-				return nil
-			}
-			if d.IsDir() {
-				if strings.Contains(path, "archive") {
-					return filepath.SkipDir
-				}
-				return nil
-			}
-			if !strings.HasSuffix(strings.ToLower(path), ".json") {
-				return nil
-			}
-			b, err := os.ReadFile(path)
-			if err != nil {
-				return nil
-			}
-			var af AnnouncementFile
-			if err := json.Unmarshal(b, &af); err != nil {
-				return nil
-			}
-			if af.ID == "" {
-				return nil
-			}
-			if _, ok := m[af.ID]; ok {
-				return nil
-			}
-			m[af.ID] = af
-			return nil
-		})
-	}
-	items := make([]AnnouncementFile, 0, len(m))
-	for _, v := range m {
-		items = append(items, v)
-	}
-	return items
-}
-
-func (a *AnnouncementsController) findByID(id string) (*AnnouncementFile, string) {
-	roots := append([]string{a.base}, a.legacyBases...)
-	for _, root := range roots {
-		var found *AnnouncementFile
-		var foundPath string
-		_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
-			if err != nil {
-				return nil
-			}
-			if d.IsDir() {
-				return nil
-			}
-			if !strings.HasSuffix(strings.ToLower(path), ".json") {
-				return nil
-			}
-			b, err := os.ReadFile(path)
-			if err != nil {
-				return nil
-			}
-			var af AnnouncementFile
-			if err := json.Unmarshal(b, &af); err != nil {
-				return nil
-			}
-			if af.ID == id {
-				found = &af
-				foundPath = path
-				return fmt.Errorf("stop")
-			}
-			return nil
-		})
-		if found != nil {
-			return found, foundPath
-		}
+// isVisibleTo is the filter shared by the public list, unread count and
+// anything else that shows announcements to end users.
+func isVisibleTo(x AnnouncementFile, now time.Time, v *viewer) bool {
+	if x.Archived || x.DeletedAt != nil || announcementStatus(x) != StatusPublished {
+		return false
 	}
-	return nil, ""
-}
-
-type userState struct {
-	Read map[string]bool `json:"read"`
-	Fav  map[string]bool `json:"fav"`
-}
-
-func (a *AnnouncementsController) userStatePath(uid string) string {
-	dir := filepath.Join(a.base, "user_states")
-	_ = os.MkdirAll(dir, 0755)
-	return filepath.Join(dir, uid+".json")
+	if x.ValidFrom != nil && now.Before(*x.ValidFrom) {
+		return false
+	}
+	if x.ValidTo != nil && now.After(*x.ValidTo) {
+		return false
+	}
+	return audienceAllows(x, v)
 }
 
-func (a *AnnouncementsController) readUserState(uid string) *userState {
-	p := a.userStatePath(uid)
-	b, err := os.ReadFile(p)
-	if err == nil {
-		var st userState
-		if err := json.Unmarshal(b, &st); err == nil {
-			return &st
+// resolveAudience turns the request's scope/audience into what is stored.
+// A structured audience wins; otherwise scope must be ALL or a role name.
+func (a *AnnouncementsController) resolveAudience(scope string, au *Audience) (string, *Audience, string) {
+	if au != nil {
+		norm, code := normalizeAudience(a.db, au)
+		if code != "" {
+			return "", nil, code
 		}
-	}
-	for _, root := range a.legacyBases {
-		p2 := filepath.Join(root, "user_states", uid+".json")
-		if b2, err2 := os.ReadFile(p2); err2 == nil {
-			var st userState
-			if err := json.Unmarshal(b2, &st); err == nil {
-				return &st
-			}
+		if norm == nil {
+			return "ALL", nil, ""
 		}
+		return "TARGETED", norm, ""
 	}
-	return nil
-}
-
-func (a *AnnouncementsController) writeUserState(uid string, st userState) error {
-	p := a.userStatePath(uid)
-	b, _ := json.Marshal(st)
-	tmp := p + ".tmp"
-	if err := os.WriteFile(tmp, b, 0644); err != nil {
-		return err
+	scope = strings.ToUpper(strings.TrimSpace(scope))
+	if scope == "" || scope == "ALL" {
+		return "ALL", nil, ""
 	}
-	if err := os.Chmod(tmp, 0644); err != nil {
-		return err
+	if r := strings.TrimSuffix(scope, "S"); audienceRoles[r] {
+		return "TARGETED", &Audience{Roles: []string{r}}, ""
 	}
-	return os.Rename(tmp, p)
-}
-
-func (a *AnnouncementsController) getLock(id string) *sync.Mutex {
-	v, _ := a.locks.LoadOrStore(id, &sync.Mutex{})
-	return v.(*sync.Mutex)
-}
-
-func (a *AnnouncementsController) startArchive(days int) {
-	go func() {
-		t := time.NewTicker(1 * time.Hour)
-		defer t.Stop()
-		for {
-			cutoff := time.Now().AddDate(0, 0, -days)
-			_ = filepath.WalkDir(a.base, func(path string, d fs.DirEntry, err error) error {
-				if err != nil {
-					return nil
-				}
-				if d.IsDir() {
-					return nil
-				}
-				if !strings.HasSuffix(strings.ToLower(path), ".json") {
-					return nil
-				}
-				b, err := os.ReadFile(path)
-				if err != nil {
-					return nil
-				}
-				var af AnnouncementFile
-				if err := json.Unmarshal(b, &af); err != nil {
-					return nil
-				}
-				if af.PublishAt.Before(cutoff) && !af.Archived {
-					af.Archived = true
-					_ = a.writeJSON(path, af)
-				}
-				return nil
-			})
-			select {
-			case <-t.C:
-			}
-		}
-	}()
+	return "", nil, "invalid_scope"
 }
 
-func genID() string {
-	n := time.Now().UnixNano()
-	return fmt.Sprintf("%d%06d", n, rand.Intn(1000000))
+// PublishedSince lists announcements published after since that uid can
+// see, newest first.
+func (a *AnnouncementsController) PublishedSince(since time.Time, uid string) []AnnouncementFile {
+	now := time.Now()
+	out, _ := a.visibleTo(loadViewer(a.db, uid, ""), AnnouncementFilter{VisibleAt: &now, PublishedAfter: &since})
+	sort.Slice(out, func(i, j int) bool { return out[i].PublishAt.After(out[j].PublishAt) })
+	return out
 }
 
-func md2html(md string) string {
-	lines := strings.Split(md, "\n")
-	var out []string
-	code := false
-	for _, ln := range lines {
-		if strings.HasPrefix(strings.TrimSpace(ln), "```") {
-			if !code {
-				out = append(out, "<pre><code>")
-				code = true
-			} else {
-				out = append(out, "</code></pre>")
-				code = false
-			}
-			continue
-		}
-		if code {
-			out = append(out, htmlEscape(ln))
-			continue
-		}
-		if strings.HasPrefix(ln, "# ") {
-			out = append(out, "<h1>"+htmlEscape(strings.TrimPrefix(ln, "# "))+"</h1>")
-			continue
-		}
-		if strings.HasPrefix(ln, "## ") {
-			out = append(out, "<h2>"+htmlEscape(strings.TrimPrefix(ln, "## "))+"</h2>")
+// visibleTo is what v may see among the published items matching f,
+// pinned first. With f.VisibleAt set the validity window applies as well,
+// which is what the list, unread count, digest and Atom feed want; the
+// calendar feed leaves it unset to keep past and future windows.
+func (a *AnnouncementsController) visibleTo(v *viewer, f AnnouncementFilter) ([]AnnouncementFile, error) {
+	f.Status = StatusPublished
+	items, err := a.store.List(f)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]AnnouncementFile, 0, len(items))
+	for _, x := range items {
+		if f.VisibleAt != nil && !isVisibleTo(x, *f.VisibleAt, v) {
 			continue
 		}
-		if strings.HasPrefix(ln, "### ") {
-			out = append(out, "<h3>"+htmlEscape(strings.TrimPrefix(ln, "### "))+"</h3>")
-			continue
+		if !x.Archived && audienceAllows(x, v) {
+			out = append(out, x)
 		}
-		ln2 := ln
-		ln2 = boldItalic(ln2)
-		out = append(out, "<p>"+ln2+"</p>")
-	}
-	if code {
-		out = append(out, "</code></pre>")
 	}
-	return strings.Join(out, "\n")
-}
-
-func boldItalic(s string) string {
-	s = regexp.MustCompile(`\*\*([^\*]+)\*\*`).ReplaceAllString(s, "<b>$1</b>")
-	s = regexp.MustCompile(`\*([^*]+)\*`).ReplaceAllString(s, "<i>$1</i>")
-	return htmlEscape(s)
+	return out, nil
 }
 
-func htmlEscape(s string) string {
-	r := strings.ReplaceAll(s, "&", "&amp;")
-	r = strings.ReplaceAll(r, "<", "&lt;")
-	r = strings.ReplaceAll(r, ">", "&gt;")
-	return r
+func genID() string {
+	n := time.Now().UnixNano()
+	return fmt.Sprintf("%d%06d", n, rand.Intn(1000000))
 }
 
 func sanitizeHTML(h string) string {
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"scholarhub/backend-go/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type AnswersController struct {
+	db   *gorm.DB
+	noti *Notifier
+}
+
+func NewAnswersController(db *gorm.DB) *AnswersController {
+	return &AnswersController{db: db, noti: NewNotifier(db)}
+}
+
+func (a *AnswersController) List(c *gin.Context) {
+	var list []models.Answer
+	a.db.Where("\"questionId\" = ? AND hidden = false", c.Param("id")).
+		Order("\"isTop\" desc, id desc").Find(&list)
+	c.JSON(http.StatusOK, respOk(gin.H{"items": list}))
+}
+
+func (a *AnswersController) Create(c *gin.Context) {
+	uid := c.GetString("user_id")
+	role := strings.ToUpper(c.GetString("role"))
+	var req struct {
+		Content     string
+		Attachments []string
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Content) == "" {
+		c.JSON(http.StatusBadRequest, respErr(1002, "bad_request"))
+		return
+	}
+	var q models.Question
+	if err := a.db.First(&q, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, respErr(1006, "not_found"))
+		return
+	}
+	if q.Status == "MERGED" || q.Status == "VIOLATION" {
+		c.JSON(http.StatusBadRequest, respErr(1002, "question_closed"))
+		return
+	}
+	item := models.Answer{QuestionID: q.ID, TeacherID: uid, Content: sanitizeHTML(req.Content)}
+	if len(req.Attachments) > 0 {
+		s := string(toJSONB(req.Attachments))
+		item.Attachments = &s
+	}
+	if err := a.db.Create(&item).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, respErr(1004, "db_error"))
+		return
+	}
+	// 回答者自动关注该问题，后续追问/状态变化也能收到
+	_ = a.noti.Follow(q.ID, uid)
+	if role == "TEACHER" || role == "ADMIN" {
+		a.noti.NotifyFollowers(q.ID, NotiAnswer, "您关注的问题《"+q.Title+"》有新的回答", uid)
+		if q.Status == "UNANSWERED" {
+			a.db.Model(&models.Question{}).Where("id = ?", q.ID).Update("status", "ANSWERED")
+			a.noti.NotifyFollowers(q.ID, NotiStatus, "您关注的问题《"+q.Title+"》已被解答", uid)
+		}
+	} else {
+		a.noti.NotifyFollowers(q.ID, NotiComment, "您关注的问题《"+q.Title+"》有新的讨论", uid)
+	}
+	c.JSON(http.StatusOK, respOk(item))
+}
@@ -0,0 +1,242 @@
+package server
+
+import (
+	"strings"
+
+	"scholarhub/backend-go/internal/models"
+
+	"gorm.io/gorm"
+)
+
+const audienceMaxUsers = 2000
+
+var audienceRoles = map[string]bool{"STUDENT": true, "TEACHER": true, "ADMIN": true}
+
+// Audience says who an announcement is for. An empty audience means
+// everyone. Otherwise a user qualifies when listed in UserIDs, or when they
+// satisfy every non-empty dimension among Roles, CourseIDs and Departments
+// (any value within a dimension), so {roles:[STUDENT], courseIds:[12]}
+// reads as "students of course 12".
+type Audience struct {
+	Roles       []string `json:"roles,omitempty"`
+	CourseIDs   []int    `json:"courseIds,omitempty"`
+	Departments []string `json:"departments,omitempty"`
+	UserIDs     []string `json:"userIds,omitempty"`
+}
+
+func (au *Audience) Empty() bool {
+	return au == nil || (len(au.Roles) == 0 && len(au.CourseIDs) == 0 && len(au.Departments) == 0 && len(au.UserIDs) == 0)
+}
+
+// viewer is the part of a user that audience rules look at. Course
+// membership is enrollment plus courses the user teaches; departments are
+// those of the user's courses.
+type viewer struct {
+	UID         string
+	Role        string
+	Courses     map[int]bool
+	Departments map[string]bool
+}
+
+func loadViewer(db *gorm.DB, uid, role string) *viewer {
+	v := &viewer{UID: uid, Role: strings.ToUpper(role), Courses: map[int]bool{}, Departments: map[string]bool{}}
+	if uid == "" {
+		return v
+	}
+	if v.Role == "" {
+		var u models.User
+		if db.Select("id, role").First(&u, "id = ?", uid).Error == nil {
+			v.Role = strings.ToUpper(u.Role)
+		}
+	}
+	var courses []models.Course
+	db.Select("id, department").
+		Where("\"teacherId\" = ? OR id IN (?)", uid,
+			db.Model(&models.CourseEnrollment{}).Select("\"courseId\"").Where("\"userId\" = ?", uid)).
+		Find(&courses)
+	for _, c := range courses {
+		v.Courses[c.ID] = true
+		if c.Department != "" {
+			v.Departments[c.Department] = true
+		}
+	}
+	return v
+}
+
+// audienceUsers selects the users au covers, with the same rules as
+// Matches but evaluated in the database.
+func audienceUsers(db *gorm.DB, au *Audience) *gorm.DB {
+	q := db.Model(&models.User{})
+	if au.Empty() {
+		return q
+	}
+	members := func(courses *gorm.DB) *gorm.DB {
+		return db.Where("id IN (?)", db.Model(&models.Course{}).Select("\"teacherId\"").Where("id IN (?)", courses)).
+			Or("id IN (?)", db.Model(&models.CourseEnrollment{}).Select("\"userId\"").Where("\"courseId\" IN (?)", courses))
+	}
+	dims := db
+	if len(au.Roles) > 0 {
+		dims = dims.Where("UPPER(role) IN ?", au.Roles)
+	}
+	if len(au.CourseIDs) > 0 {
+		dims = dims.Where(members(db.Model(&models.Course{}).Select("id").Where("id IN ?", au.CourseIDs)))
+	}
+	if len(au.Departments) > 0 {
+		dims = dims.Where(members(db.Model(&models.Course{}).Select("id").Where("department IN ?", au.Departments)))
+	}
+	switch {
+	case len(au.UserIDs) == 0:
+		return q.Where(dims)
+	case len(au.Roles)+len(au.CourseIDs)+len(au.Departments) == 0:
+		return q.Where("id IN ?", au.UserIDs)
+	}
+	return q.Where(db.Where("id IN ?", au.UserIDs).Or(dims))
+}
+
+func (au *Audience) Matches(v *viewer) bool {
+	if au.Empty() {
+		return true
+	}
+	if v == nil {
+		return false
+	}
+	for _, id := range au.UserIDs {
+		if id == v.UID {
+			return true
+		}
+	}
+	dims := 0
+	if len(au.Roles) > 0 {
+		dims++
+		ok := false
+		for _, r := range au.Roles {
+			if r == v.Role {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	if len(au.CourseIDs) > 0 {
+		dims++
+		ok := false
+		for _, id := range au.CourseIDs {
+			if v.Courses[id] {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	if len(au.Departments) > 0 {
+		dims++
+		ok := false
+		for _, d := range au.Departments {
+			if v.Departments[d] {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return dims > 0
+}
+
+// effectiveAudience maps legacy free-text Scope values onto the structured
+// model: ALL (or empty) is everyone, a role name targets that role, and
+// anything else stays hidden as before. ok is false for the hidden case.
+func effectiveAudience(x AnnouncementFile) (*Audience, bool) {
+	if x.Audience != nil {
+		return x.Audience, true
+	}
+	scope := strings.ToUpper(strings.TrimSpace(x.Scope))
+	if scope == "" || scope == "ALL" {
+		return nil, true
+	}
+	if r := strings.TrimSuffix(scope, "S"); audienceRoles[r] {
+		return &Audience{Roles: []string{r}}, true
+	}
+	return nil, false
+}
+
+func audienceAllows(x AnnouncementFile, v *viewer) bool {
+	au, ok := effectiveAudience(x)
+	return ok && au.Matches(v)
+}
+
+// normalizeAudience trims, upper-cases and de-duplicates, then checks that
+// roles are known and that courses, departments and users exist. It returns
+// an error code suitable for respErr.
+func normalizeAudience(db *gorm.DB, au *Audience) (*Audience, string) {
+	if au == nil {
+		return nil, ""
+	}
+	out := &Audience{}
+	seenS := map[string]bool{}
+	for _, r := range au.Roles {
+		r = strings.ToUpper(strings.TrimSpace(r))
+		if r == "" || seenS["r:"+r] {
+			continue
+		}
+		if !audienceRoles[r] {
+			return nil, "invalid_audience_role"
+		}
+		seenS["r:"+r] = true
+		out.Roles = append(out.Roles, r)
+	}
+	seenI := map[int]bool{}
+	for _, id := range au.CourseIDs {
+		if id > 0 && !seenI[id] {
+			seenI[id] = true
+			out.CourseIDs = append(out.CourseIDs, id)
+		}
+	}
+	for _, d := range au.Departments {
+		d = strings.TrimSpace(d)
+		if d != "" && !seenS["d:"+d] {
+			seenS["d:"+d] = true
+			out.Departments = append(out.Departments, d)
+		}
+	}
+	for _, u := range au.UserIDs {
+		u = strings.TrimSpace(u)
+		if u != "" && !seenS["u:"+u] {
+			seenS["u:"+u] = true
+			out.UserIDs = append(out.UserIDs, u)
+		}
+	}
+	if len(out.UserIDs) > audienceMaxUsers {
+		return nil, "audience_too_large"
+	}
+	if len(out.CourseIDs) > 0 {
+		var n int64
+		db.Model(&models.Course{}).Where("id IN ?", out.CourseIDs).Count(&n)
+		if int(n) != len(out.CourseIDs) {
+			return nil, "invalid_audience_course"
+		}
+	}
+	if len(out.Departments) > 0 {
+		var n int64
+		db.Model(&models.Course{}).Where("department IN ?", out.Departments).Distinct("department").Count(&n)
+		if int(n) != len(out.Departments) {
+			return nil, "invalid_audience_department"
+		}
+	}
+	if len(out.UserIDs) > 0 {
+		var n int64
+		db.Model(&models.User{}).Where("id IN ?", out.UserIDs).Count(&n)
+		if int(n) != len(out.UserIDs) {
+			return nil, "invalid_audience_user"
+		}
+	}
+	if out.Empty() {
+		return nil, ""
+	}
+	return out, ""
+}
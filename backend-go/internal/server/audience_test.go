@@ -0,0 +1,80 @@
+package server
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"scholarhub/backend-go/internal/models"
+)
+
+func TestAudienceMatches(t *testing.T) {
+	student12 := &viewer{UID: "s1", Role: "STUDENT", Courses: map[int]bool{12: true}, Departments: map[string]bool{"CS": true}}
+	teacher := &viewer{UID: "t1", Role: "TEACHER", Courses: map[int]bool{3: true}, Departments: map[string]bool{"Math": true}}
+
+	cases := []struct {
+		name string
+		au   *Audience
+		v    *viewer
+		want bool
+	}{
+		{"nil is everyone", nil, student12, true},
+		{"role match", &Audience{Roles: []string{"TEACHER"}}, teacher, true},
+		{"role miss", &Audience{Roles: []string{"TEACHER"}}, student12, false},
+		{"role and course", &Audience{Roles: []string{"STUDENT"}, CourseIDs: []int{12}}, student12, true},
+		{"role and other course", &Audience{Roles: []string{"STUDENT"}, CourseIDs: []int{13}}, student12, false},
+		{"department", &Audience{Departments: []string{"Math", "Physics"}}, teacher, true},
+		{"explicit user overrides", &Audience{Roles: []string{"ADMIN"}, UserIDs: []string{"s1"}}, student12, true},
+		{"anonymous viewer", &Audience{Roles: []string{"STUDENT"}}, nil, false},
+	}
+	for _, tc := range cases {
+		if got := tc.au.Matches(tc.v); got != tc.want {
+			t.Errorf("%s: got %v want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestEffectiveAudienceLegacyScope(t *testing.T) {
+	v := &viewer{UID: "t1", Role: "TEACHER", Courses: map[int]bool{}, Departments: map[string]bool{}}
+	for scope, want := range map[string]bool{"ALL": true, "": true, "teachers": true, "STUDENT": false, "staff-only": false} {
+		if got := audienceAllows(AnnouncementFile{Scope: scope}, v); got != want {
+			t.Errorf("scope %q: got %v want %v", scope, got, want)
+		}
+	}
+}
+
+func TestAudienceUsersAgreesWithMatches(t *testing.T) {
+	db := newTestDB(t, &models.User{}, &models.Course{}, &models.CourseEnrollment{})
+	db.Create(&[]models.User{
+		{ID: "s1", Username: "s1", Role: "STUDENT"}, {ID: "s2", Username: "s2", Role: "student"},
+		{ID: "t1", Username: "t1", Role: "TEACHER"}, {ID: "a1", Username: "a1", Role: "ADMIN"},
+	})
+	db.Create(&[]models.Course{{ID: 12, Name: "OS", Department: "CS", TeacherID: "t1"}, {ID: 3, Name: "Algebra", Department: "Math", TeacherID: "a1"}})
+	db.Create(&[]models.CourseEnrollment{{CourseID: 12, UserID: "s1"}, {CourseID: 3, UserID: "s2"}})
+
+	for _, au := range []*Audience{
+		nil,
+		{Roles: []string{"STUDENT"}},
+		{CourseIDs: []int{12}},
+		{Roles: []string{"STUDENT"}, CourseIDs: []int{12}},
+		{Departments: []string{"Math"}},
+		{Roles: []string{"TEACHER"}, Departments: []string{"Math"}},
+		{UserIDs: []string{"a1"}},
+		{Roles: []string{"TEACHER"}, UserIDs: []string{"s2"}},
+	} {
+		var got []string
+		if err := audienceUsers(db, au).Order("id").Pluck("id", &got).Error; err != nil {
+			t.Fatal(err)
+		}
+		var want []string
+		for _, uid := range []string{"a1", "s1", "s2", "t1"} {
+			if au.Matches(loadViewer(db, uid, "")) {
+				want = append(want, uid)
+			}
+		}
+		sort.Strings(got)
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("audience %+v: sql %v, matches %v", au, got, want)
+		}
+	}
+}
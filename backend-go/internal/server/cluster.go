@@ -0,0 +1,141 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"hash/fnv"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	instanceOnce sync.Once
+	instanceID   string
+	instanceHost string
+)
+
+// InstanceID is INSTANCE_ID or the host name.
+func InstanceID() string {
+	instanceOnce.Do(func() {
+		instanceHost, _ = os.Hostname()
+		if instanceHost == "" {
+			instanceHost = "unknown"
+		}
+		instanceID = os.Getenv("INSTANCE_ID")
+		if instanceID == "" {
+			instanceID = instanceHost
+		}
+	})
+	return instanceID
+}
+
+func hostName() string {
+	InstanceID()
+	return instanceHost
+}
+
+// leaderLockKey is the advisory lock the leader holds.
+var leaderLockKey = func() int64 {
+	h := fnv.New64a()
+	h.Write([]byte("scholarhub:leader"))
+	return int64(h.Sum64())
+}()
+
+// LeaderElection holds a session advisory lock on a dedicated connection;
+// if the connection dies another replica takes over.
+type LeaderElection struct {
+	db       *sql.DB
+	conn     *sql.Conn
+	interval time.Duration
+	leader   atomic.Bool
+	stopCh   chan struct{}
+	done     chan struct{}
+	once     sync.Once
+}
+
+// election is nil until StartLeaderElection; until then every instance
+// is leader.
+var election *LeaderElection
+
+// isLeader reports whether this instance runs cluster-wide jobs.
+func isLeader() bool {
+	return election == nil || election.leader.Load()
+}
+
+// StartLeaderElection campaigns every LEADER_RETRY_SECONDS (default 10).
+func StartLeaderElection(db *gorm.DB) func() {
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Printf("leader election disabled: %v", err)
+		return func() {}
+	}
+	e := &LeaderElection{db: sqlDB, interval: time.Duration(envInt("LEADER_RETRY_SECONDS", 10)) * time.Second,
+		stopCh: make(chan struct{}), done: make(chan struct{})}
+	election = e
+	go e.loop()
+	return e.Stop
+}
+
+func (e *LeaderElection) loop() {
+	defer close(e.done)
+	t := time.NewTicker(e.interval)
+	defer t.Stop()
+	for {
+		e.campaign()
+		select {
+		case <-t.C:
+		case <-e.stopCh:
+			e.resign()
+			return
+		}
+	}
+}
+
+// campaign takes the lock, or checks the connection that holds it.
+func (e *LeaderElection) campaign() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if e.conn != nil {
+		if err := e.conn.PingContext(ctx); err != nil {
+			log.Printf("leader connection lost, stepping down: %v", err)
+			e.leader.Store(false)
+			e.conn.Close()
+			e.conn = nil
+		}
+		return
+	}
+	conn, err := e.db.Conn(ctx)
+	if err != nil {
+		return
+	}
+	var ok bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", leaderLockKey).Scan(&ok); err != nil || !ok {
+		conn.Close()
+		return
+	}
+	e.conn = conn
+	e.leader.Store(true)
+	log.Printf("instance %s is now the leader", InstanceID())
+}
+
+func (e *LeaderElection) resign() {
+	if e.conn == nil {
+		return
+	}
+	e.leader.Store(false)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, _ = e.conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", leaderLockKey)
+	e.conn.Close()
+	e.conn = nil
+}
+
+func (e *LeaderElection) Stop() {
+	e.once.Do(func() { close(e.stopCh) })
+	<-e.done
+}
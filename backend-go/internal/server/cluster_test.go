@@ -0,0 +1,27 @@
+package server
+
+import "testing"
+
+func TestIsLeaderFollowsElection(t *testing.T) {
+	saved := election
+	defer func() { election = saved }()
+
+	election = nil
+	if !isLeader() {
+		t.Fatal("without an election a lone instance must lead")
+	}
+	election = &LeaderElection{}
+	if isLeader() {
+		t.Fatal("an instance that has not won the lock must not lead")
+	}
+	election.leader.Store(true)
+	if !isLeader() {
+		t.Fatal("expected the lock holder to lead")
+	}
+}
+
+func TestInstanceIDDefaultsToHost(t *testing.T) {
+	if InstanceID() == "" || hostName() == "" {
+		t.Fatal("instance id and host must never be empty")
+	}
+}
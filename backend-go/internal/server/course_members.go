@@ -0,0 +1,82 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"scholarhub/backend-go/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+)
+
+// --- Course Members ---
+// Enrollment is what course-targeted announcements match against; teachers
+// count as members of the courses they teach without an explicit row.
+
+func (a *AdminController) ListCourseMembers(c *gin.Context) {
+	cid, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, respErr(1002, "bad_request"))
+		return
+	}
+	var users []models.User
+	a.db.Select("id, username, fullname, role").
+		Where("id IN (?)", a.db.Model(&models.CourseEnrollment{}).Select("\"userId\"").Where("\"courseId\" = ?", cid)).
+		Order("username asc").Find(&users)
+	c.JSON(http.StatusOK, respOk(gin.H{"items": users, "total": len(users)}))
+}
+
+func (a *AdminController) AddCourseMembers(c *gin.Context) {
+	cid, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, respErr(1002, "bad_request"))
+		return
+	}
+	var req struct {
+		UserIDs []string `json:"userIds"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.UserIDs) == 0 {
+		c.JSON(http.StatusBadRequest, respErr(1002, "bad_request"))
+		return
+	}
+	var course models.Course
+	if err := a.db.Select("id").First(&course, cid).Error; err != nil {
+		c.JSON(http.StatusNotFound, respErr(1006, "not_found"))
+		return
+	}
+	var ids []string
+	a.db.Model(&models.User{}).Where("id IN ?", req.UserIDs).Pluck("id", &ids)
+	rows := make([]models.CourseEnrollment, 0, len(ids))
+	for _, uid := range ids {
+		rows = append(rows, models.CourseEnrollment{CourseID: cid, UserID: uid})
+	}
+	if len(rows) > 0 {
+		if err := a.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, respErr(1004, "db_error"))
+			return
+		}
+	}
+	a.logAction(c.GetString("user_id"), "ADD_COURSE_MEMBERS", strconv.Itoa(cid), gin.H{"userIds": ids})
+	c.JSON(http.StatusOK, respOk(gin.H{"added": len(rows)}))
+}
+
+func (a *AdminController) RemoveCourseMember(c *gin.Context) {
+	cid, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, respErr(1002, "bad_request"))
+		return
+	}
+	uid := c.Param("uid")
+	res := a.db.Where("\"courseId\" = ? AND \"userId\" = ?", cid, uid).Delete(&models.CourseEnrollment{})
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, respErr(1004, "db_error"))
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, respErr(1006, "not_found"))
+		return
+	}
+	a.logAction(c.GetString("user_id"), "REMOVE_COURSE_MEMBER", strconv.Itoa(cid), gin.H{"userId": uid})
+	c.JSON(http.StatusOK, respOk(gin.H{"ok": true}))
+}
@@ -0,0 +1,327 @@
+package server
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	htmltpl "html/template"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	texttpl "text/template"
+	"time"
+
+	"scholarhub/backend-go/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	DeliverySent    = "SENT"
+	DeliveryFailed  = "FAILED"
+	DeliverySkipped = "SKIPPED"
+)
+
+// DigestJob mails each opted-in user a daily or weekly summary of unread
+// notifications and new announcements. Deliveries are keyed by
+// (user, period, periodKey) so restarts and replicas never double-send.
+type DigestJob struct {
+	db      *gorm.DB
+	ann     *AnnouncementsController
+	mail    Mailer
+	hour    int
+	weekday time.Weekday
+	baseURL string
+	stopCh  chan struct{}
+}
+
+func NewDigestJob(db *gorm.DB, ann *AnnouncementsController) *DigestJob {
+	hour := 8
+	if v, err := strconv.Atoi(os.Getenv("DIGEST_HOUR")); err == nil && v >= 0 && v < 24 {
+		hour = v
+	}
+	wd := time.Monday
+	if v, err := strconv.Atoi(os.Getenv("DIGEST_WEEKDAY")); err == nil && v >= 0 && v < 7 {
+		wd = time.Weekday(v)
+	}
+	return &DigestJob{db: db, ann: ann, mail: defaultMailer(), hour: hour, weekday: wd, baseURL: publicBaseURL(), stopCh: make(chan struct{})}
+}
+
+// publicBaseURL is where the web frontend lives, for links that leave the
+// app (emails, feeds).
+func publicBaseURL() string {
+	base := strings.TrimRight(os.Getenv("PUBLIC_BASE_URL"), "/")
+	if base == "" {
+		base = "http://localhost:5173"
+	}
+	return base
+}
+
+func (d *DigestJob) Start() {
+	if d.mail == nil {
+		log.Printf("digest disabled: SMTP_HOST not configured")
+		return
+	}
+	go func() {
+		t := time.NewTicker(10 * time.Minute)
+		defer t.Stop()
+		for {
+			d.RunDue(time.Now())
+			select {
+			case <-t.C:
+			case <-d.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+func (d *DigestJob) Stop() { close(d.stopCh) }
+
+// RunDue sends whatever digests are due at now in each user's time zone:
+// daily ones once the configured hour has passed, weekly ones on the
+// configured weekday.
+func (d *DigestJob) RunDue(now time.Time) {
+	var prefs []models.NotificationPreference
+	d.db.Where("digest IN ?", []string{DigestDaily, DigestWeekly}).Find(&prefs)
+	for _, p := range prefs {
+		local := now.In(userLocation(p.TimeZone))
+		if local.Hour() < d.hour {
+			continue
+		}
+		switch {
+		case p.Digest == DigestDaily:
+			d.sendDue(p, local.Format("2006-01-02"), now.AddDate(0, 0, -1))
+		case p.Digest == DigestWeekly && local.Weekday() == d.weekday:
+			y, w := local.ISOWeek()
+			d.sendDue(p, fmt.Sprintf("%d-W%02d", y, w), now.AddDate(0, 0, -7))
+		}
+	}
+}
+
+// userLocation resolves a preference's IANA time zone, falling back to the
+// server's when it is unset or unknown.
+func userLocation(tz string) *time.Location {
+	if tz == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+func (d *DigestJob) sendDue(p models.NotificationPreference, key string, floor time.Time) {
+	var done int64
+	d.db.Model(&models.DigestDelivery{}).
+		Where("\"userId\" = ? AND period = ? AND \"periodKey\" = ?", p.UserID, p.Digest, key).Count(&done)
+	if done > 0 {
+		return
+	}
+	d.sendOne(p, p.Digest, key, floor)
+}
+
+func (d *DigestJob) sendOne(p models.NotificationPreference, period, key string, floor time.Time) {
+	since := floor
+	var last models.DigestDelivery
+	if err := d.db.Where("\"userId\" = ? AND status = ?", p.UserID, DeliverySent).
+		Order("\"createTime\" desc").First(&last).Error; err == nil && last.CreateTime.After(since) {
+		since = last.CreateTime
+	}
+	rec := models.DigestDelivery{UserID: p.UserID, Period: period, PeriodKey: key}
+	// Claim the slot first; a unique-key conflict means another replica has it.
+	if res := d.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&rec); res.Error != nil || res.RowsAffected == 0 {
+		return
+	}
+	finish := func(status string, items int, err error) {
+		up := map[string]interface{}{"status": status, "items": items}
+		if err != nil {
+			up["error"] = err.Error()
+			log.Printf("digest send error user=%s period=%s err=%v", p.UserID, key, err)
+		}
+		d.db.Model(&models.DigestDelivery{}).Where("id = ?", rec.ID).Updates(up)
+	}
+	var u models.User
+	if err := d.db.First(&u, "id = ?", p.UserID).Error; err != nil || u.Email == "" {
+		finish(DeliverySkipped, 0, nil)
+		return
+	}
+	var notis []models.Notification
+	d.db.Where("\"userId\" = ? AND read = false AND \"createTime\" > ?", p.UserID, since).
+		Order("\"createTime\" desc").Limit(50).Find(&notis)
+	var anns []AnnouncementFile
+	if d.ann != nil {
+		anns = d.ann.PublishedSince(since, p.UserID)
+		for i := range anns {
+			anns[i] = localize(anns[i], p.Locale)
+		}
+	}
+	items := len(notis) + len(anns)
+	if items == 0 {
+		finish(DeliverySkipped, 0, nil)
+		return
+	}
+	name := u.Username
+	if u.FullName != nil && *u.FullName != "" {
+		name = *u.FullName
+	}
+	m, err := renderDigest(digestInput{
+		Locale:         p.Locale,
+		Period:         period,
+		Name:           name,
+		Notifications:  notis,
+		Announcements:  anns,
+		BaseURL:        d.baseURL,
+		UnsubscribeURL: unsubscribeURL(d.baseURL, p.UserID),
+	})
+	if err != nil {
+		finish(DeliveryFailed, items, err)
+		return
+	}
+	m.To = u.Email
+	if err := d.mail.Send(m); err != nil {
+		finish(DeliveryFailed, items, err)
+		return
+	}
+	finish(DeliverySent, items, nil)
+}
+
+type digestItem struct {
+	Title string
+	URL   string
+	Time  string
+}
+
+type digestInput struct {
+	Locale         string
+	Period         string
+	Name           string
+	Notifications  []models.Notification
+	Announcements  []AnnouncementFile
+	BaseURL        string
+	UnsubscribeURL string
+}
+
+var digestStrings = map[string]map[string]string{
+	"zh": {
+		"DAILY":         "每日摘要",
+		"WEEKLY":        "每周摘要",
+		"greeting":      "您好，%s：",
+		"notifications": "未读通知",
+		"announcements": "新公告",
+		"footer":        "您收到此邮件是因为开启了 ScholarHub 邮件摘要。",
+		"unsubscribe":   "退订摘要",
+	},
+	"en": {
+		"DAILY":         "Daily digest",
+		"WEEKLY":        "Weekly digest",
+		"greeting":      "Hi %s,",
+		"notifications": "Unread notifications",
+		"announcements": "New announcements",
+		"footer":        "You receive this email because ScholarHub digests are enabled for your account.",
+		"unsubscribe":   "Unsubscribe",
+	},
+}
+
+type digestView struct {
+	Subject        string
+	Greeting       string
+	T              map[string]string
+	Notifications  []digestItem
+	Announcements  []digestItem
+	UnsubscribeURL string
+}
+
+const digestHTML = `<div style="font-family:Segoe UI,Arial,sans-serif;max-width:600px;margin:auto;padding:20px;border:1px solid #e5e7eb;border-radius:12px">
+<h2 style="color:#111827;margin:0 0 12px">{{.Subject}}</h2>
+<p style="color:#374151">{{.Greeting}}</p>
+{{if .Notifications}}<h3 style="color:#111827">{{index .T "notifications"}} ({{len .Notifications}})</h3>
+<ul>{{range .Notifications}}<li><a href="{{.URL}}">{{.Title}}</a> <span style="color:#9ca3af">{{.Time}}</span></li>{{end}}</ul>{{end}}
+{{if .Announcements}}<h3 style="color:#111827">{{index .T "announcements"}} ({{len .Announcements}})</h3>
+<ul>{{range .Announcements}}<li><a href="{{.URL}}">{{.Title}}</a> <span style="color:#9ca3af">{{.Time}}</span></li>{{end}}</ul>{{end}}
+<hr style="border:none;border-top:1px solid #e5e7eb;margin:16px 0"/>
+<div style="font-size:12px;color:#9ca3af">{{index .T "footer"}} <a href="{{.UnsubscribeURL}}">{{index .T "unsubscribe"}}</a></div>
+</div>`
+
+const digestText = `{{.Subject}}
+
+{{.Greeting}}
+{{if .Notifications}}
+{{index .T "notifications"}} ({{len .Notifications}}):
+{{range .Notifications}}- {{.Title}} {{.URL}}
+{{end}}{{end}}{{if .Announcements}}
+{{index .T "announcements"}} ({{len .Announcements}}):
+{{range .Announcements}}- {{.Title}} {{.URL}}
+{{end}}{{end}}
+{{index .T "footer"}}
+{{index .T "unsubscribe"}}: {{.UnsubscribeURL}}
+`
+
+var (
+	digestHTMLTpl = htmltpl.Must(htmltpl.New("digest").Parse(digestHTML))
+	digestTextTpl = texttpl.Must(texttpl.New("digest").Parse(digestText))
+)
+
+func renderDigest(in digestInput) (Mail, error) {
+	t, ok := digestStrings[in.Locale]
+	if !ok {
+		t = digestStrings["zh"]
+	}
+	v := digestView{
+		Subject:        "ScholarHub " + t[in.Period],
+		Greeting:       fmt.Sprintf(t["greeting"], in.Name),
+		T:              t,
+		UnsubscribeURL: in.UnsubscribeURL,
+	}
+	for _, n := range in.Notifications {
+		link := in.BaseURL + "/student/notifications"
+		if n.QuestionID != nil {
+			link = fmt.Sprintf("%s/student/qa/%d", in.BaseURL, *n.QuestionID)
+		}
+		v.Notifications = append(v.Notifications, digestItem{Title: n.Title, URL: link, Time: n.CreateTime.Format("2006-01-02 15:04")})
+	}
+	for _, a := range in.Announcements {
+		v.Announcements = append(v.Announcements, digestItem{Title: a.Title, URL: in.BaseURL + "/student/announcements/" + a.ID, Time: a.PublishAt.Format("2006-01-02 15:04")})
+	}
+	var hb, tb bytes.Buffer
+	if err := digestHTMLTpl.Execute(&hb, v); err != nil {
+		return Mail{}, err
+	}
+	if err := digestTextTpl.Execute(&tb, v); err != nil {
+		return Mail{}, err
+	}
+	return Mail{
+		Subject: v.Subject,
+		HTML:    hb.String(),
+		Text:    tb.String(),
+		Headers: map[string]string{
+			"List-Unsubscribe":      "<" + in.UnsubscribeURL + ">",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		},
+	}, nil
+}
+
+func unsubscribeToken(uid string) string {
+	mac := hmac.New(sha256.New, []byte(os.Getenv("JWT_SECRET")))
+	mac.Write([]byte("digest-unsubscribe:" + uid))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// publicAPIURL is where the API is reachable from outside, for links in
+// emails and feeds.
+func publicAPIURL(base string) string {
+	if api := strings.TrimRight(os.Getenv("PUBLIC_API_URL"), "/"); api != "" {
+		return api
+	}
+	return base + "/api"
+}
+
+func unsubscribeURL(base, uid string) string {
+	return publicAPIURL(base) + "/notifications/unsubscribe?uid=" + url.QueryEscape(uid) + "&token=" + unsubscribeToken(uid)
+}
@@ -0,0 +1,205 @@
+package server
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"scholarhub/backend-go/internal/models"
+)
+
+func TestRenderDigestLocales(t *testing.T) {
+	qid := 42
+	in := digestInput{
+		Period:         DigestDaily,
+		Name:           "<Alice>",
+		Notifications:  []models.Notification{{Title: "新回答", QuestionID: &qid, CreateTime: time.Now()}},
+		Announcements:  []AnnouncementFile{{ID: "a1", Title: "停电通知", PublishAt: time.Now()}},
+		BaseURL:        "http://app",
+		UnsubscribeURL: "http://app/api/notifications/unsubscribe?uid=u1&token=x",
+	}
+	in.Locale = "en"
+	m, err := renderDigest(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Subject != "ScholarHub Daily digest" {
+		t.Fatalf("unexpected subject %q", m.Subject)
+	}
+	if !strings.Contains(m.HTML, "http://app/student/qa/42") || !strings.Contains(m.Text, "http://app/student/announcements/a1") {
+		t.Fatalf("digest is missing deep links:\n%s\n%s", m.HTML, m.Text)
+	}
+	if strings.Contains(m.HTML, "<Alice>") {
+		t.Fatalf("html template must escape user names")
+	}
+	if m.Headers["List-Unsubscribe"] == "" || m.Headers["List-Unsubscribe-Post"] != "List-Unsubscribe=One-Click" {
+		t.Fatalf("expected one-click List-Unsubscribe headers, got %v", m.Headers)
+	}
+	in.Locale = "zh"
+	m, _ = renderDigest(in)
+	if !strings.Contains(m.Subject, "每日摘要") || !strings.Contains(m.Text, "退订摘要") {
+		t.Fatalf("expected chinese digest, got %q", m.Subject)
+	}
+}
+
+func TestUnsubscribeTokenIsPerUser(t *testing.T) {
+	t.Setenv("JWT_SECRET", "s3cret")
+	if unsubscribeToken("u1") == unsubscribeToken("u2") {
+		t.Fatalf("tokens must differ per user")
+	}
+	if !strings.Contains(unsubscribeURL("http://app", "u1"), "token="+unsubscribeToken("u1")) {
+		t.Fatalf("unsubscribe url must carry the user's token")
+	}
+}
+
+type recordingMailer struct{ to []string }
+
+func (m *recordingMailer) Send(mail Mail) error {
+	m.to = append(m.to, mail.To)
+	return nil
+}
+
+func TestRunDueUsesUserTimeZone(t *testing.T) {
+	db := newTestDB(t, &models.Notification{}, &models.NotificationPreference{}, &models.User{}, &models.DigestDelivery{})
+	db.Create(&[]models.User{{ID: "sh", Username: "sh", Email: "sh@test"}, {ID: "ny", Username: "ny", Email: "ny@test"}})
+	db.Create(&[]models.NotificationPreference{
+		{UserID: "sh", Digest: DigestDaily, TimeZone: "Asia/Shanghai"},
+		{UserID: "ny", Digest: DigestDaily, TimeZone: "America/New_York"},
+	})
+	now := time.Date(2026, 5, 4, 1, 0, 0, 0, time.UTC) // 09:00 in Shanghai, 21:00 the day before in New York
+	db.Create(&[]models.Notification{
+		{Type: NotiAnswer, Title: "a", UserID: "sh", CreateTime: now.Add(-time.Hour)},
+		{Type: NotiAnswer, Title: "b", UserID: "ny", CreateTime: now.Add(-time.Hour)},
+	})
+	mail := &recordingMailer{}
+	d := &DigestJob{db: db, mail: mail, hour: 8, weekday: time.Monday}
+
+	d.RunDue(now)
+	d.RunDue(now.Add(10 * time.Minute))
+	if len(mail.to) != 2 {
+		t.Fatalf("both are past 08:00 locally and should get one digest each, got %v", mail.to)
+	}
+	var keys []string
+	db.Model(&models.DigestDelivery{}).Order("\"userId\"").Pluck("periodKey", &keys)
+	if len(keys) != 2 || keys[0] != "2026-05-03" || keys[1] != "2026-05-04" {
+		t.Fatalf("period keys should follow each user's local date, got %v", keys)
+	}
+
+	mail.to = nil
+	db.Model(&models.NotificationPreference{}).Where("\"userId\" = ?", "ny").Update("timeZone", "America/Los_Angeles")
+	d.RunDue(time.Date(2026, 5, 4, 14, 0, 0, 0, time.UTC)) // 07:00 in Los Angeles
+	if len(mail.to) != 0 {
+		t.Fatalf("nothing is due before 08:00 local time, sent %v", mail.to)
+	}
+}
+
+func TestUnsubscribeNeedsPost(t *testing.T) {
+	t.Setenv("JWT_SECRET", "s3cret")
+	gin.SetMode(gin.TestMode)
+	n := notificationsTestDB(t)
+	n.db.Create(&models.NotificationPreference{UserID: "u1", Email: true, Digest: DigestDaily, MutedTypes: "[]"})
+	r := gin.New()
+	r.GET("/unsubscribe", n.UnsubscribePage)
+	r.POST("/unsubscribe", n.Unsubscribe)
+	q := "?uid=u1&token=" + url.QueryEscape(unsubscribeToken("u1"))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/unsubscribe"+q, nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `method="post"`) {
+		t.Fatalf("GET should serve a confirm form, got %d %s", w.Code, w.Body.String())
+	}
+	if p := loadPreference(n.db, "u1"); p.Digest != DigestDaily || !p.Email {
+		t.Fatalf("GET must not change preferences, got %+v", p)
+	}
+
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/unsubscribe"+q, strings.NewReader("List-Unsubscribe=One-Click"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.ServeHTTP(w, req)
+	if p := loadPreference(n.db, "u1"); w.Code != http.StatusOK || p.Digest != DigestOff || p.Email {
+		t.Fatalf("one-click POST should unsubscribe, got %d %+v", w.Code, p)
+	}
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/unsubscribe", strings.NewReader("uid=u1&token=forged"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("a bad token should be rejected, got %d", w.Code)
+	}
+}
+
+// smtpSink is a minimal SMTP server that accepts one message.
+func smtpSink(t *testing.T) (int, <-chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	out := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		w := func(s string) { conn.Write([]byte(s + "\r\n")) }
+		w("220 sink")
+		var data strings.Builder
+		inData := false
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if inData {
+				if line == ".\r\n" {
+					inData = false
+					out <- data.String()
+					w("250 ok")
+					continue
+				}
+				data.WriteString(line)
+				continue
+			}
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				w("250 sink")
+			case cmd == "DATA":
+				inData = true
+				w("354 go ahead")
+			case cmd == "QUIT":
+				w("221 bye")
+				return
+			default:
+				w("250 ok")
+			}
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port, out
+}
+
+func TestSMTPMailerSendsToSink(t *testing.T) {
+	port, got := smtpSink(t)
+	m := &SMTPMailer{Host: "127.0.0.1", Port: port, From: "noreply@test"}
+	if err := m.Send(Mail{To: "s@test", Subject: "摘要", Text: "plain", HTML: "<b>rich</b>"}); err != nil {
+		t.Fatalf("send failed: %v", err)
+	}
+	select {
+	case msg := <-got:
+		if !strings.Contains(msg, "multipart/alternative") || !strings.Contains(msg, "<b>rich</b>") {
+			t.Fatalf("unexpected message:\n%s", msg)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("sink received nothing on port %s", strconv.Itoa(port))
+	}
+}
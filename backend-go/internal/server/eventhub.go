@@ -0,0 +1,220 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"gorm.io/gorm"
+)
+
+const (
+	TopicHealth       = "health"
+	TopicNotification = "notification"
+	TopicAnnouncement = "announcement"
+)
+
+// Event is what travels through the hub. An empty User is a broadcast.
+type Event struct {
+	ID    string          `json:"id,omitempty"`
+	Topic string          `json:"topic"`
+	User  string          `json:"user,omitempty"`
+	Name  string          `json:"name"`
+	Data  json.RawMessage `json:"data,omitempty"`
+}
+
+// Relay forwards events to every backend instance, including this one.
+type Relay interface {
+	Publish(e Event) error
+}
+
+type Subscription struct {
+	C     chan Event
+	topic string
+	user  string
+}
+
+type EventHub struct {
+	mu    sync.Mutex
+	subs  map[*Subscription]struct{}
+	relay Relay
+}
+
+func NewEventHub() *EventHub {
+	return &EventHub{subs: map[*Subscription]struct{}{}}
+}
+
+var hub = NewEventHub()
+
+// Hub returns the process-wide event hub.
+func Hub() *EventHub { return hub }
+
+func (h *EventHub) SetRelay(r Relay) {
+	h.mu.Lock()
+	h.relay = r
+	h.mu.Unlock()
+}
+
+// Subscribe registers interest in a topic for user.
+func (h *EventHub) Subscribe(topic, user string) *Subscription {
+	s := &Subscription{C: make(chan Event, 16), topic: topic, user: user}
+	h.mu.Lock()
+	h.subs[s] = struct{}{}
+	h.mu.Unlock()
+	return s
+}
+
+func (h *EventHub) Unsubscribe(s *Subscription) {
+	h.mu.Lock()
+	if _, ok := h.subs[s]; ok {
+		delete(h.subs, s)
+		close(s.C)
+	}
+	h.mu.Unlock()
+}
+
+func (h *EventHub) SubscriberCount(topic string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n := 0
+	for s := range h.subs {
+		if s.topic == topic {
+			n++
+		}
+	}
+	return n
+}
+
+// Publish sends the event through the relay, or locally if that fails.
+func (h *EventHub) Publish(e Event) {
+	h.mu.Lock()
+	r := h.relay
+	h.mu.Unlock()
+	if r != nil {
+		err := r.Publish(e)
+		if err == nil {
+			return
+		}
+		log.Printf("event_relay publish error topic=%s err=%v", e.Topic, err)
+	}
+	h.Dispatch(e)
+}
+
+// Dispatch delivers to local subscribers; slow ones drop events.
+func (h *EventHub) Dispatch(e Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.subs {
+		if s.topic != e.Topic {
+			continue
+		}
+		if e.User != "" && s.user != e.User {
+			continue
+		}
+		select {
+		case s.C <- e:
+		default:
+		}
+	}
+}
+
+func newEvent(topic, user, name, id string, v interface{}) Event {
+	return Event{ID: id, Topic: topic, User: user, Name: name, Data: mustJSON(v)}
+}
+
+func mustJSON(v interface{}) []byte {
+	b, _ := json.Marshal(v)
+	return b
+}
+
+func writeSSE(w io.Writer, id, name string, data []byte) error {
+	if len(data) == 0 {
+		data = []byte("{}")
+	}
+	if id != "" {
+		if _, err := fmt.Fprintf(w, "id: %s\n", id); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, data)
+	return err
+}
+
+const pgEventChannel = "scholarhub_events"
+
+// pgNotify payloads are capped at 8000 bytes by PostgreSQL.
+const pgNotifyMaxPayload = 7900
+
+// PGRelay fans events out across instances with LISTEN/NOTIFY.
+type PGRelay struct {
+	db  *gorm.DB
+	dsn string
+}
+
+func (r *PGRelay) Publish(e Event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if len(b) > pgNotifyMaxPayload {
+		return fmt.Errorf("payload too large: %d", len(b))
+	}
+	return r.db.Exec("SELECT pg_notify(?, ?)", pgEventChannel, string(b)).Error
+}
+
+// listen dispatches notifications locally, reconnecting until ctx ends.
+func (r *PGRelay) listen(ctx context.Context, h *EventHub) {
+	backoff := time.Second
+	for ctx.Err() == nil {
+		conn, err := pgx.Connect(ctx, r.dsn)
+		if err == nil {
+			_, err = conn.Exec(ctx, "LISTEN "+pgEventChannel)
+		}
+		if err == nil {
+			backoff = time.Second
+			for {
+				n, werr := conn.WaitForNotification(ctx)
+				if werr != nil {
+					err = werr
+					break
+				}
+				var e Event
+				if json.Unmarshal([]byte(n.Payload), &e) == nil {
+					h.Dispatch(e)
+				}
+			}
+		}
+		if conn != nil {
+			_ = conn.Close(context.Background())
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("event_relay listen error: %v (retry in %s)", err, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+// StartEventRelay relays events across replicas unless EVENT_RELAY=off.
+func StartEventRelay(ctx context.Context, db *gorm.DB, dsn string) {
+	if os.Getenv("EVENT_RELAY") == "off" {
+		return
+	}
+	r := &PGRelay{db: db, dsn: dsn}
+	go r.listen(ctx, hub)
+	hub.SetRelay(r)
+	log.Printf("event_relay started channel=%s", pgEventChannel)
+}
@@ -0,0 +1,45 @@
+package server
+
+import "testing"
+
+func TestHubDispatchScopesUserEvents(t *testing.T) {
+	h := NewEventHub()
+	alice := h.Subscribe(TopicNotification, "alice")
+	bob := h.Subscribe(TopicNotification, "bob")
+	defer h.Unsubscribe(alice)
+	defer h.Unsubscribe(bob)
+
+	h.Publish(newEvent(TopicNotification, "alice", "notification", "7", map[string]int{"id": 7}))
+	select {
+	case e := <-alice.C:
+		if e.ID != "7" {
+			t.Fatalf("unexpected event id %q", e.ID)
+		}
+	default:
+		t.Fatalf("alice should receive her notification")
+	}
+	select {
+	case <-bob.C:
+		t.Fatalf("bob must not receive alice's notification")
+	default:
+	}
+}
+
+func TestHubBroadcastAndTopicFilter(t *testing.T) {
+	h := NewEventHub()
+	ann := h.Subscribe(TopicAnnouncement, "alice")
+	health := h.Subscribe(TopicHealth, "")
+	defer h.Unsubscribe(ann)
+	defer h.Unsubscribe(health)
+
+	h.Dispatch(newEvent(TopicAnnouncement, "", "announcement", "1", nil))
+	if len(ann.C) != 1 {
+		t.Fatalf("broadcast should reach user subscription")
+	}
+	if len(health.C) != 0 {
+		t.Fatalf("health subscriber must not see announcement events")
+	}
+	if h.SubscriberCount(TopicHealth) != 1 {
+		t.Fatalf("expected one health subscriber")
+	}
+}
@@ -0,0 +1,224 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"scholarhub/backend-go/internal/models"
+)
+
+// anomalyMetric is how one metric is judged: Direction +1 flags highs, -1
+// lows; MinStd keeps a flat metric from flagging every wobble.
+type anomalyMetric struct {
+	Name      string
+	Direction float64
+	MinStd    float64
+	Unit      string
+}
+
+var anomalyMetrics = []anomalyMetric{
+	{"score", -1, 2, ""},
+	{"cpuUsed", 1, 2, "%"},
+	{"memUsed", 1, 1, "%"},
+	{"diskUsed", 1, 0.5, "%"},
+	{"dbLatencyMs", 1, 5, "ms"},
+	{"endpointMs", 1, 10, "ms"},
+	{"apiP95Ms", 1, 10, "ms"},
+}
+
+const (
+	// A baseline slot needs baselineMinSamples from baselineWeeks.
+	baselineWeeks      = 4
+	baselineMinSamples = 30
+	// The moving average stands in until a slot has history.
+	ewmaAlpha  = 0.1
+	ewmaWarmup = 30
+)
+
+// anomalyZ is ANOMALY_Z (default 3) standard deviations.
+func anomalyZ() float64 {
+	if z, err := strconv.ParseFloat(os.Getenv("ANOMALY_Z"), 64); err == nil && z > 0 {
+		return z
+	}
+	return 3
+}
+
+type metricStats struct {
+	Mean, Std float64
+	N         int64
+}
+
+// hourOfWeek numbers the UTC hours of the week from Sunday 00:00.
+func hourOfWeek(t time.Time) int {
+	t = t.UTC()
+	return int(t.Weekday())*24 + t.Hour()
+}
+
+// ewmaStat is an exponentially weighted mean and variance.
+type ewmaStat struct {
+	mean, variance float64
+	n              int
+}
+
+func (e *ewmaStat) add(v float64) {
+	if e.n == 0 {
+		e.mean = v
+	} else {
+		d := v - e.mean
+		inc := ewmaAlpha * d
+		e.mean += inc
+		e.variance = (1 - ewmaAlpha) * (e.variance + d*inc)
+	}
+	e.n++
+}
+
+// anomalyDetector compares one instance's samples with the same hour of
+// the week in past weeks, or a moving average until that exists.
+type anomalyDetector struct {
+	db       *gorm.DB
+	instance string
+	z        float64
+
+	mu      sync.Mutex
+	slots   map[int]map[string]metricStats
+	builtAt time.Time
+	recent  map[string]*ewmaStat
+}
+
+func newAnomalyDetector(db *gorm.DB, instance string) *anomalyDetector {
+	return &anomalyDetector{db: db, instance: instance, z: anomalyZ(), slots: map[int]map[string]metricStats{}, recent: map[string]*ewmaStat{}}
+}
+
+// refresh rebuilds the baseline at most hourly.
+func (d *anomalyDetector) refresh(now time.Time) {
+	d.mu.Lock()
+	fresh := now.Sub(d.builtAt) < time.Hour
+	d.mu.Unlock()
+	if fresh {
+		return
+	}
+	var rows []struct {
+		Slot                 int
+		N                    int64
+		ScoreAvg, ScoreStd   float64
+		CpuAvg, CpuStd       float64
+		MemAvg, MemStd       float64
+		DiskAvg, DiskStd     float64
+		DbAvg, DbStd         float64
+		EndpointAvg, EndpStd float64
+		ApiAvg, ApiStd       float64
+	}
+	utc := "(\"createTime\" AT TIME ZONE 'UTC')"
+	err := d.db.Model(&models.HealthSample{}).
+		Select("(EXTRACT(DOW FROM "+utc+") * 24 + EXTRACT(HOUR FROM "+utc+"))::int AS slot, COUNT(*) AS n, "+
+			"AVG(score) AS score_avg, COALESCE(STDDEV_POP(score), 0) AS score_std, "+
+			"AVG(\"cpuUsed\") AS cpu_avg, COALESCE(STDDEV_POP(\"cpuUsed\"), 0) AS cpu_std, "+
+			"AVG(\"memUsed\") AS mem_avg, COALESCE(STDDEV_POP(\"memUsed\"), 0) AS mem_std, "+
+			"AVG(\"diskUsed\") AS disk_avg, COALESCE(STDDEV_POP(\"diskUsed\"), 0) AS disk_std, "+
+			"AVG(\"dbLatencyMs\") AS db_avg, COALESCE(STDDEV_POP(\"dbLatencyMs\"), 0) AS db_std, "+
+			"AVG(\"endpointMs\") AS endpoint_avg, COALESCE(STDDEV_POP(\"endpointMs\"), 0) AS endp_std, "+
+			"AVG(\"apiP95Ms\") AS api_avg, COALESCE(STDDEV_POP(\"apiP95Ms\"), 0) AS api_std").
+		Where("\"instanceId\" = ? AND \"createTime\" >= ?", d.instance, now.AddDate(0, 0, -7*baselineWeeks)).
+		Group("slot").Scan(&rows).Error
+	slots := map[int]map[string]metricStats{}
+	for _, r := range rows {
+		slots[r.Slot] = map[string]metricStats{
+			"score":       {r.ScoreAvg, r.ScoreStd, r.N},
+			"cpuUsed":     {r.CpuAvg, r.CpuStd, r.N},
+			"memUsed":     {r.MemAvg, r.MemStd, r.N},
+			"diskUsed":    {r.DiskAvg, r.DiskStd, r.N},
+			"dbLatencyMs": {r.DbAvg, r.DbStd, r.N},
+			"endpointMs":  {r.EndpointAvg, r.EndpStd, r.N},
+			"apiP95Ms":    {r.ApiAvg, r.ApiStd, r.N},
+		}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.builtAt = now
+	if err != nil {
+		log.Printf("health baseline load error: %v", err)
+		return
+	}
+	d.slots = slots
+}
+
+// check returns the anomalies in s and updates the moving averages.
+func (d *anomalyDetector) check(s models.HealthSample) models.HealthAnomalies {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var out models.HealthAnomalies
+	slot := hourOfWeek(s.CreateTime)
+	for _, m := range anomalyMetrics {
+		v := alertMetrics[m.Name](s)
+		ew := d.recent[m.Name]
+		if ew == nil {
+			ew = &ewmaStat{}
+			d.recent[m.Name] = ew
+		}
+		var expected, std float64
+		basis := ""
+		if st, ok := d.slots[slot][m.Name]; ok && st.N >= baselineMinSamples {
+			expected, std, basis = st.Mean, st.Std, "hour-of-week"
+		} else if ew.n >= ewmaWarmup {
+			expected, std, basis = ew.mean, math.Sqrt(ew.variance), "recent"
+		}
+		ew.add(v)
+		if basis == "" {
+			continue
+		}
+		std = math.Max(std, m.MinStd)
+		z := (v - expected) / std
+		if z*m.Direction < d.z {
+			continue
+		}
+		out = append(out, models.HealthAnomaly{Metric: m.Name, Value: v, Expected: round1(expected), StdDev: round1(std),
+			Z: round1(z), Basis: basis, Message: anomalyMessage(m, v, expected, std, z, basis, s.CreateTime)})
+	}
+	return out
+}
+
+func round1(v float64) float64 { return math.Round(v*10) / 10 }
+
+func anomalyMessage(m anomalyMetric, v, expected, std, z float64, basis string, at time.Time) string {
+	dir := "above"
+	if z < 0 {
+		dir = "below"
+	}
+	usual := "the recent average"
+	if basis == "hour-of-week" {
+		usual = "the usual for " + at.UTC().Format("Mon 15:00") + " UTC"
+	}
+	return fmt.Sprintf("%s %.1f%s is %.1fσ %s %s (%.1f ± %.1f%s)", m.Name, v, m.Unit, math.Abs(z), dir, usual, expected, std, m.Unit)
+}
+
+// anomalyMark is an anomalous sample as listed next to a trend.
+type anomalyMark struct {
+	CreateTime time.Time              `json:"createTime"`
+	InstanceID string                 `json:"instanceId"`
+	Score      int                    `json:"score"`
+	Anomalies  models.HealthAnomalies `json:"anomalies"`
+}
+
+// anomaliesBetween lists anomalous samples in [from, to), newest first.
+func anomaliesBetween(db *gorm.DB, instance string, from, to time.Time, limit int) ([]anomalyMark, error) {
+	tx := db.Where("anomalies IS NOT NULL AND \"createTime\" >= ? AND \"createTime\" < ?", from, to)
+	if instance != "" {
+		tx = tx.Where("\"instanceId\" = ?", instance)
+	}
+	var samples []models.HealthSample
+	if err := tx.Order("\"createTime\" desc").Limit(limit).Find(&samples).Error; err != nil {
+		return nil, err
+	}
+	out := make([]anomalyMark, 0, len(samples))
+	for _, s := range samples {
+		out = append(out, anomalyMark{CreateTime: s.CreateTime, InstanceID: s.InstanceID, Score: s.Score, Anomalies: s.Anomalies})
+	}
+	return out, nil
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"scholarhub/backend-go/internal/models"
+)
+
+func TestAnomalyUsesHourOfWeekBaseline(t *testing.T) {
+	d := newAnomalyDetector(nil, "a")
+	backup := time.Date(2026, 3, 3, 2, 30, 0, 0, time.UTC) // Tuesday 02:30
+	d.slots[hourOfWeek(backup)] = map[string]metricStats{"cpuUsed": {Mean: 85, Std: 5, N: 400}}
+
+	if got := d.check(models.HealthSample{Score: 90, CpuUsed: 92, CreateTime: backup}); len(got) != 0 {
+		t.Fatalf("the nightly backup is expected at this hour, got %+v", got)
+	}
+	got := d.check(models.HealthSample{Score: 90, CpuUsed: 10, CreateTime: backup})
+	if len(got) != 0 {
+		t.Fatalf("low cpu is never an anomaly, got %+v", got)
+	}
+}
+
+func TestAnomalyHourOfWeekBaselineFires(t *testing.T) {
+	d := newAnomalyDetector(nil, "a")
+	quiet := time.Date(2026, 3, 3, 14, 0, 0, 0, time.UTC) // Tuesday 14:00
+	d.slots[hourOfWeek(quiet)] = map[string]metricStats{"cpuUsed": {Mean: 10, Std: 2, N: 400}}
+	for i := 0; i < ewmaWarmup; i++ {
+		d.check(models.HealthSample{Score: 90, CpuUsed: 80, CreateTime: quiet.Add(-time.Duration(i+1) * time.Minute)})
+	}
+	got := d.check(models.HealthSample{Score: 90, CpuUsed: 80, CreateTime: quiet})
+	if len(got) != 1 || got[0].Metric != "cpuUsed" || got[0].Basis != "hour-of-week" || got[0].Expected != 10 {
+		t.Fatalf("80%% cpu is normal lately but not for this hour of the week, got %+v", got)
+	}
+}
+
+func TestAnomalyFallsBackToMovingAverage(t *testing.T) {
+	d := newAnomalyDetector(nil, "a")
+	t0 := time.Date(2026, 3, 3, 14, 0, 0, 0, time.UTC)
+	for i := 0; i < ewmaWarmup; i++ {
+		s := models.HealthSample{Score: 95, CpuUsed: 20 + float64(i%3), DbLatencyMs: 3, CreateTime: t0.Add(time.Duration(i) * 30 * time.Second)}
+		if got := d.check(s); len(got) != 0 {
+			t.Fatalf("sample %d flagged during warm-up: %+v", i, got)
+		}
+	}
+	got := d.check(models.HealthSample{Score: 60, CpuUsed: 97, DbLatencyMs: 4, CreateTime: t0.Add(time.Hour)})
+	if len(got) != 2 || got[0].Metric != "score" || got[1].Metric != "cpuUsed" {
+		t.Fatalf("expected score and cpu anomalies, got %+v", got)
+	}
+	if got[1].Basis != "recent" || !strings.Contains(got[1].Message, "above the recent average") {
+		t.Fatalf("unexpected explanation %+v", got[1])
+	}
+	if !strings.Contains(got[0].Message, "below") {
+		t.Fatalf("a low score should read as below, got %q", got[0].Message)
+	}
+}
+
+func TestHealthAnomaliesRoundTrip(t *testing.T) {
+	var empty models.HealthAnomalies
+	if v, err := empty.Value(); err != nil || v != nil {
+		t.Fatalf("no anomalies should store NULL, got %v %v", v, err)
+	}
+	in := models.HealthAnomalies{{Metric: "cpuUsed", Value: 97, Z: 4.2}}
+	v, err := in.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out models.HealthAnomalies
+	if err := out.Scan(v); err != nil || len(out) != 1 || out[0].Z != 4.2 {
+		t.Fatalf("round trip failed: %+v %v", out, err)
+	}
+}
+
+func TestAlertAnomalyRule(t *testing.T) {
+	t0 := time.Now()
+	ev := newAlertEvaluator(t0)
+	rules := []models.AlertRule{{ID: 1, Kind: AlertAnomaly, Metric: "dbLatencyMs", Enabled: true}}
+	ev.observe(models.HealthSample{CreateTime: t0, Anomalies: models.HealthAnomalies{{Metric: "cpuUsed", Z: 5}}})
+	if trs := ev.evaluate(rules, t0); len(trs) != 0 {
+		t.Fatalf("an anomaly on another metric must not fire, got %+v", trs)
+	}
+	ev.observe(models.HealthSample{CreateTime: t0.Add(time.Minute), Anomalies: models.HealthAnomalies{{Metric: "dbLatencyMs", Z: 6, Message: "slow"}}})
+	trs := ev.evaluate(rules, t0.Add(time.Minute))
+	if len(trs) != 1 || !trs[0].Firing || trs[0].Value != 6 || trs[0].Message != "slow" {
+		t.Fatalf("expected the anomaly rule to fire, got %+v", trs)
+	}
+}
+
+func TestHealthTrendReportsDBErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/trend", NewAdminController(newTestDB(t)).HealthTrend)
+	if code, resp := doJSON(t, r, http.MethodGet, "/trend?step=raw&days=1", nil); code != 500 || resp.Code != 1004 {
+		t.Fatalf("a failing query must not look like an empty trend, got %d %+v", code, resp)
+	}
+
+	db := newTestDB(t, &models.HealthSample{})
+	db.Create(&models.HealthSample{Score: 40, Anomalies: models.HealthAnomalies{{Metric: "score", Z: -4}}, CreateTime: time.Now().Add(-time.Hour)})
+	r = gin.New()
+	r.GET("/trend", NewAdminController(db).HealthTrend)
+	code, resp := doJSON(t, r, http.MethodGet, "/trend?step=raw&days=1", nil)
+	if data, _ := resp.Data.(map[string]interface{}); code != 200 || len(data["items"].([]interface{})) != 1 || len(data["anomalies"].([]interface{})) != 1 {
+		t.Fatalf("expected the sample and its anomaly, got %d %+v", code, resp)
+	}
+}
@@ -2,14 +2,15 @@ package server
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"math"
 	"net/http"
 	"os"
 	"runtime"
 	"strconv"
-	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/shirou/gopsutil/v3/cpu"
@@ -20,57 +21,56 @@ import (
 	"scholarhub/backend-go/internal/models"
 )
 
-var (
-	subMu = &sync.Mutex{}
-	subs  = map[chan models.HealthSample]struct{}{}
-)
-
-func SubscribeHealth() chan models.HealthSample {
-	ch := make(chan models.HealthSample, 8)
-	subMu.Lock()
-	subs[ch] = struct{}{}
-	subMu.Unlock()
-	return ch
-}
-
-func UnsubscribeHealth(ch chan models.HealthSample) {
-	subMu.Lock()
-	delete(subs, ch)
-	close(ch)
-	subMu.Unlock()
-}
-
+// publishHealth is local-only: every instance streams its own collector.
+// A sample with anomalies is followed by an "anomaly" event so the
+// dashboard can call it out without inspecting every sample.
 func publishHealth(s models.HealthSample) {
-	subMu.Lock()
-	for ch := range subs {
-		select {
-		case ch <- s:
-		default:
-		}
+	hub.Dispatch(newEvent(TopicHealth, "", "sample", "", s))
+	if len(s.Anomalies) > 0 {
+		hub.Dispatch(newEvent(TopicHealth, "", "anomaly", "", anomalyMark{CreateTime: s.CreateTime, InstanceID: s.InstanceID, Score: s.Score, Anomalies: s.Anomalies}))
 	}
-	subMu.Unlock()
 }
 
 type HealthCollector struct {
 	db       *gorm.DB
 	interval time.Duration
 	stopCh   chan struct{}
-	lastBeat time.Time
+	wg       sync.WaitGroup
+	once     sync.Once
+	lastBeat atomic.Int64
+	anomaly  *anomalyDetector
+	incident *incidentTracker
 }
 
-func NewHealthCollector(db *gorm.DB) *HealthCollector {
+// healthInterval is the sampling period, HEALTH_INTERVAL_MS (default 30s).
+func healthInterval() time.Duration {
 	ms := 30000
 	if v := os.Getenv("HEALTH_INTERVAL_MS"); v != "" {
 		if n, err := strconv.Atoi(v); err == nil && n > 0 {
 			ms = n
 		}
 	}
-	return &HealthCollector{db: db, interval: time.Duration(ms) * time.Millisecond, stopCh: make(chan struct{}, 1)}
+	return time.Duration(ms) * time.Millisecond
+}
+
+func NewHealthCollector(db *gorm.DB) *HealthCollector {
+	return &HealthCollector{
+		db:       db,
+		interval: healthInterval(),
+		stopCh:   make(chan struct{}),
+		anomaly:  newAnomalyDetector(db, InstanceID()),
+		incident: &incidentTracker{db: db, instance: InstanceID()},
+	}
 }
 
 func (hc *HealthCollector) Start() {
-	hc.startRetention(30)
+	hc.lastBeat.Store(time.Now().UnixNano())
+	RegisterProbe("collector", time.Second, hc.checkHeartbeat)
+	hc.startRetention(healthRawDays)
+	hc.startRollups()
+	hc.wg.Add(1)
 	go func() {
+		defer hc.wg.Done()
 		for {
 			func() {
 				defer func() {
@@ -91,13 +91,27 @@ func (hc *HealthCollector) Start() {
 	}()
 }
 
+// startRetention prunes raw samples, rollups, incidents and synthetic steps
+// hourly; like the rollups themselves it is the leader's job.
 func (hc *HealthCollector) startRetention(days int) {
+	hc.wg.Add(1)
 	go func() {
+		defer hc.wg.Done()
 		t := time.NewTicker(1 * time.Hour)
 		defer t.Stop()
 		for {
-			cutoff := time.Now().AddDate(0, 0, -days)
-			_ = hc.db.Where("\"createTime\" < ?", cutoff).Delete(&models.HealthSample{}).Error
+			if isLeader() {
+				cutoff := time.Now().AddDate(0, 0, -days)
+				_ = hc.db.Where("\"createTime\" < ?", cutoff).Delete(&models.HealthSample{}).Error
+				for _, size := range []time.Duration{rollupHour, rollupDay} {
+					cutoff := time.Now().AddDate(0, 0, -rollupRetentionDays(size))
+					_ = hc.db.Table(rollupTable(size)).Where("\"bucketStart\" < ?", cutoff).Delete(&models.HealthRollup{}).Error
+				}
+				incidentCutoff := time.Now().AddDate(0, 0, -rollupRetentionDays(rollupDay))
+				_ = hc.db.Where("\"endedAt\" < ?", incidentCutoff).Delete(&models.HealthIncident{}).Error
+				stepCutoff := time.Now().AddDate(0, 0, -envInt("SYNTHETIC_RETENTION_DAYS", 30))
+				_ = hc.db.Where("\"runAt\" < ?", stepCutoff).Delete(&models.SyntheticStep{}).Error
+			}
 			select {
 			case <-t.C:
 			case <-hc.stopCh:
@@ -107,12 +121,28 @@ func (hc *HealthCollector) startRetention(days int) {
 	}()
 }
 
+// Stop ends sampling, rollups and retention and waits for them to return.
+func (hc *HealthCollector) Stop() {
+	hc.once.Do(func() { close(hc.stopCh) })
+	hc.wg.Wait()
+}
+
+// checkHeartbeat fails once three intervals pass without a sample; the
+// first sample is due one interval after Start.
+func (hc *HealthCollector) checkHeartbeat(ctx context.Context) error {
+	age := time.Since(time.Unix(0, hc.lastBeat.Load()))
+	if age > 3*hc.interval+5*time.Second {
+		return fmt.Errorf("last health sample %s ago", age.Round(time.Second))
+	}
+	return nil
+}
+
 func (hc *HealthCollector) run() {
 	t := time.NewTicker(hc.interval)
 	defer t.Stop()
 	for {
 		hc.sampleOnce()
-		hc.lastBeat = time.Now()
+		hc.lastBeat.Store(time.Now().UnixNano())
 		select {
 		case <-t.C:
 		case <-hc.stopCh:
@@ -121,15 +151,16 @@ func (hc *HealthCollector) run() {
 	}
 }
 
-func (hc *HealthCollector) sampleOnce() {
-	start := time.Now()
-	cpuPerc := 0.0
+// collectHealth takes the raw measurements both the collector and the
+// on-demand admin check score.
+func collectHealth(db *gorm.DB) healthReading {
+	now := time.Now()
+	r := healthReading{DiskClass: diskClass(), SvcMs: telemetry.serviceLatencyMs(now), Journeys: journeyHealth.failurePercent(now)}
 	if vals, err := cpu.Percent(time.Millisecond*200, false); err == nil && len(vals) > 0 {
-		cpuPerc = vals[0]
+		r.CPU = vals[0]
 	}
-	memPerc := 0.0
 	if m, err := mem.VirtualMemory(); err == nil {
-		memPerc = m.UsedPercent
+		r.Mem = m.UsedPercent
 	}
 	root := "/"
 	if runtime.GOOS == "windows" {
@@ -139,55 +170,49 @@ func (hc *HealthCollector) sampleOnce() {
 		}
 		root = drive + "\\"
 	}
-	diskPerc := 0.0
 	if du, err := disk.Usage(root); err == nil && du != nil {
-		diskPerc = du.UsedPercent
+		r.Disk = du.UsedPercent
 	}
 	dbStart := time.Now()
-	_ = hc.db.Exec("SELECT 1")
-	dbLatency := time.Since(dbStart).Milliseconds()
-	pingURL := os.Getenv("PING_URL")
-	netLatency := int64(0)
-	if pingURL != "" {
-		netLatency = pingLatency(pingURL, 800)
-		if netLatency < 0 {
-			netLatency = 0
+	_ = db.Exec("SELECT 1")
+	r.DBMs = time.Since(dbStart).Milliseconds()
+	if pingURL := os.Getenv("PING_URL"); pingURL != "" {
+		r.NetMs = pingLatency(pingURL, 800)
+		if r.NetMs < 0 {
+			r.NetMs = 0
 		}
 	}
-	totalMs := time.Since(start).Milliseconds()
+	r.ProbeMs = time.Since(now).Milliseconds()
+	return r
+}
 
-	severities := map[string]float64{
-		"cpu":  severityCPU(cpuPerc),
-		"mem":  severityMem(memPerc),
-		"disk": severityDisk(diskPerc, diskClass()),
-		"db":   severityDBLatency(dbLatency),
-		"net":  severityNetworkLatency(netLatency),
-		"svc":  severityServiceMs(totalMs),
-	}
-	baseWeights := map[string]float64{
-		"cpu": 0.22, "mem": 0.22, "disk": 0.20, "db": 0.16, "net": 0.12, "svc": 0.08,
-	}
-	weights := dynamicWeights(baseWeights, severities)
-	score := calcHealthScore(severities, weights)
-	status := "Healthy"
-	if score < 60 {
-		status = "Critical"
-	} else if score < 80 {
-		status = "Warning"
-	}
+func (hc *HealthCollector) sampleOnce() {
+	r := collectHealth(hc.db)
+	res := healthScoring.Model().Score(r)
 	sample := models.HealthSample{
-		Score:       int(score + 0.5),
-		Status:      status,
-		CpuUsed:     cpuPerc,
-		MemUsed:     memPerc,
-		DiskUsed:    diskPerc,
-		DbLatencyMs: dbLatency,
-		EndpointMs:  totalMs,
+		Score:       int(res.Score + 0.5),
+		Status:      res.Status,
+		CpuUsed:     r.CPU,
+		MemUsed:     r.Mem,
+		DiskUsed:    r.Disk,
+		DbLatencyMs: r.DBMs,
+		EndpointMs:  r.ProbeMs,
+		ApiP95Ms:    r.SvcMs,
+		InstanceID:  InstanceID(),
+		Host:        hostName(),
+		CreateTime:  time.Now(),
 	}
+	hc.anomaly.refresh(sample.CreateTime)
+	sample.Anomalies = hc.anomaly.check(sample)
 	_ = hc.db.Create(&sample)
+	hc.incident.observe(sample, hc.interval)
 	publishHealth(sample)
-	log.Printf("health_collect status=%s score=%d endpointMs=%d cpu=%.1f mem=%.1f disk=%.1f db=%d net=%d",
-		status, int(score+0.5), totalMs, cpuPerc, memPerc, diskPerc, dbLatency, netLatency)
+	for _, a := range sample.Anomalies {
+		log.Printf("health_anomaly %s", a.Message)
+	}
+	recordHealthMetrics(sample, r.NetMs)
+	log.Printf("health_collect status=%s score=%d endpointMs=%d apiP95Ms=%d cpu=%.1f mem=%.1f disk=%.1f db=%d net=%d",
+		res.Status, sample.Score, r.ProbeMs, r.SvcMs, r.CPU, r.Mem, r.Disk, r.DBMs, r.NetMs)
 }
 
 func minf(a, b float64) float64 {
@@ -221,55 +246,10 @@ func piecewise(x float64, pts [][2]float64) float64 {
 	return pts[len(pts)-1][1]
 }
 
-func severityDisk(used float64, cls string) float64 {
-	if strings.ToUpper(cls) == "HDD" {
-		return piecewise(used, [][2]float64{
-			{0, 0}, {50, 10}, {75, 40}, {90, 80}, {95, 95}, {100, 100},
-		})
-	}
-	return piecewise(used, [][2]float64{
-		{0, 0}, {60, 10}, {80, 30}, {90, 70}, {95, 90}, {100, 100},
-	})
-}
-
-func severityCPU(p float64) float64 {
-	return piecewise(p, [][2]float64{
-		{0, 0}, {40, 10}, {60, 30}, {80, 65}, {90, 85}, {100, 100},
-	})
-}
-
-func severityMem(p float64) float64 {
-	return piecewise(p, [][2]float64{
-		{0, 0}, {50, 10}, {70, 40}, {85, 75}, {95, 95}, {100, 100},
-	})
-}
-
-func severityDBLatency(ms int64) float64 {
-	return piecewise(float64(ms), [][2]float64{
-		{0, 0}, {50, 5}, {200, 30}, {500, 60}, {1000, 90}, {2000, 100},
-	})
-}
-
-func severityNetworkLatency(ms int64) float64 {
-	if ms <= 0 {
-		return 0
-	}
-	return piecewise(float64(ms), [][2]float64{
-		{0, 0}, {20, 5}, {50, 20}, {100, 40}, {200, 70}, {500, 100},
-	})
-}
-
-func severityServiceMs(ms int64) float64 {
-	return piecewise(float64(ms), [][2]float64{
-		{0, 0}, {50, 5}, {150, 20}, {300, 40}, {600, 80}, {1000, 100},
-	})
-}
-
-func dynamicWeights(base map[string]float64, sev map[string]float64) map[string]float64 {
+func dynamicWeights(base map[string]float64, sev map[string]float64, a float64) map[string]float64 {
 	sum := 0.0
 	out := map[string]float64{}
 	for k, w := range base {
-		a := 0.5
 		s := sev[k] / 100.0
 		adj := w * (1 + a*s)
 		out[k] = adj
@@ -36,11 +36,12 @@ func TestMinf(t *testing.T) {
 }
 
 func TestSeverityDiskSSD(t *testing.T) {
-	s := severityDisk(70, "SSD")
+	m := defaultHealthModel()
+	s := m.severity("disk", 70, "SSD")
 	if s < 20 || s > 40 {
 		t.Fatalf("expected SSD severity ~30 for 70%% used, got %f", s)
 	}
-	s2 := severityDisk(95, "SSD")
+	s2 := m.severity("disk", 95, "SSD")
 	if s2 < 85 {
 		t.Fatalf("expected SSD severity high near 95%% used, got %f", s2)
 	}
@@ -49,7 +50,7 @@ func TestSeverityDiskSSD(t *testing.T) {
 func TestDynamicWeightsNormalize(t *testing.T) {
 	base := map[string]float64{"cpu": 0.22, "mem": 0.22, "disk": 0.20, "db": 0.16, "net": 0.12, "svc": 0.08}
 	sev := map[string]float64{"cpu": 30, "mem": 40, "disk": 50, "db": 10, "net": 0, "svc": 5}
-	w := dynamicWeights(base, sev)
+	w := dynamicWeights(base, sev, 0.5)
 	sum := 0.0
 	for _, v := range w {
 		sum += v
@@ -0,0 +1,328 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// healthComponents are the inputs the score is made of, in display order.
+var healthComponents = []string{"cpu", "mem", "disk", "db", "net", "svc", "journeys"}
+
+// HealthComponent maps a reading to a 0-100 severity along Curve, or
+// ByClass per disk class. Thresholds are for display only.
+type HealthComponent struct {
+	Weight     float64                 `json:"weight" yaml:"weight"`
+	Curve      [][2]float64            `json:"curve" yaml:"curve"`
+	ByClass    map[string][][2]float64 `json:"byClass,omitempty" yaml:"byClass,omitempty"`
+	Thresholds map[string]float64      `json:"thresholds,omitempty" yaml:"thresholds,omitempty"`
+}
+
+// HealthThresholds are the score cut-offs for Warning and Critical.
+type HealthThresholds struct {
+	Warning  float64 `json:"warning" yaml:"warning"`
+	Critical float64 `json:"critical" yaml:"critical"`
+}
+
+// HealthModel is the scoring configuration. Each weight is scaled by
+// 1+DynamicFactor*severity/100 before normalising.
+type HealthModel struct {
+	Components    map[string]HealthComponent `json:"components" yaml:"components"`
+	DynamicFactor float64                    `json:"dynamicFactor" yaml:"dynamicFactor"`
+	Thresholds    HealthThresholds           `json:"thresholds" yaml:"thresholds"`
+}
+
+// defaultHealthModel is the model used when HEALTH_MODEL_FILE is unset.
+func defaultHealthModel() HealthModel {
+	return HealthModel{
+		Components: map[string]HealthComponent{
+			"cpu": {Weight: 0.22, Curve: [][2]float64{{0, 0}, {40, 10}, {60, 30}, {80, 65}, {90, 85}, {100, 100}},
+				Thresholds: map[string]float64{"warn": 75, "crit": 90}},
+			"mem": {Weight: 0.22, Curve: [][2]float64{{0, 0}, {50, 10}, {70, 40}, {85, 75}, {95, 95}, {100, 100}},
+				Thresholds: map[string]float64{"warn": 75, "crit": 90}},
+			"disk": {Weight: 0.20, Curve: [][2]float64{{0, 0}, {60, 10}, {80, 30}, {90, 70}, {95, 90}, {100, 100}},
+				ByClass:    map[string][][2]float64{"HDD": {{0, 0}, {50, 10}, {75, 40}, {90, 80}, {95, 95}, {100, 100}}},
+				Thresholds: map[string]float64{"normal_low": 60, "normal_high": 80, "warn": 90, "crit": 95}},
+			"db": {Weight: 0.16, Curve: [][2]float64{{0, 0}, {50, 5}, {200, 30}, {500, 60}, {1000, 90}, {2000, 100}},
+				Thresholds: map[string]float64{"warn": 200, "crit": 1000}},
+			"net": {Weight: 0.12, Curve: [][2]float64{{0, 0}, {20, 5}, {50, 20}, {100, 40}, {200, 70}, {500, 100}},
+				Thresholds: map[string]float64{"warn": 100, "crit": 200}},
+			"svc": {Weight: 0.08, Curve: [][2]float64{{0, 0}, {50, 5}, {150, 20}, {300, 40}, {600, 80}, {1000, 100}}},
+			"journeys": {Weight: 0.10, Curve: [][2]float64{{0, 0}, {20, 60}, {50, 90}, {100, 100}},
+				Thresholds: map[string]float64{"warn": 1, "crit": 20}},
+		},
+		DynamicFactor: 0.5,
+		Thresholds:    HealthThresholds{Warning: 80, Critical: 60},
+	}
+}
+
+func validateCurve(name string, pts [][2]float64) error {
+	if len(pts) < 2 {
+		return fmt.Errorf("%s: curve needs at least two points", name)
+	}
+	for i, p := range pts {
+		if p[1] < 0 || p[1] > 100 {
+			return fmt.Errorf("%s: severity %v outside 0-100", name, p[1])
+		}
+		if i > 0 && p[0] <= pts[i-1][0] {
+			return fmt.Errorf("%s: curve points must be in increasing value order", name)
+		}
+	}
+	return nil
+}
+
+func (m HealthModel) Validate() error {
+	sum := 0.0
+	for _, k := range healthComponents {
+		c, ok := m.Components[k]
+		if !ok {
+			return fmt.Errorf("missing component %q", k)
+		}
+		if c.Weight < 0 {
+			return fmt.Errorf("%s: negative weight", k)
+		}
+		sum += c.Weight
+		if err := validateCurve(k, c.Curve); err != nil {
+			return err
+		}
+		for cls, pts := range c.ByClass {
+			if err := validateCurve(k+"."+cls, pts); err != nil {
+				return err
+			}
+		}
+	}
+	for k := range m.Components {
+		if !containsString(healthComponents, k) {
+			return fmt.Errorf("unknown component %q", k)
+		}
+	}
+	if sum <= 0 {
+		return fmt.Errorf("weights must not all be zero")
+	}
+	if m.DynamicFactor < 0 {
+		return fmt.Errorf("dynamicFactor must not be negative")
+	}
+	t := m.Thresholds
+	if t.Critical < 0 || t.Warning > 100 || t.Critical > t.Warning {
+		return fmt.Errorf("thresholds need 0 <= critical <= warning <= 100")
+	}
+	return nil
+}
+
+func containsString(xs []string, s string) bool {
+	for _, x := range xs {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// healthReading is one set of raw measurements. SvcMs is the API p95,
+// Journeys the percentage of synthetic journeys failing; ProbeMs is not
+// scored.
+type healthReading struct {
+	CPU, Mem, Disk              float64
+	DBMs, NetMs, SvcMs, ProbeMs int64
+	Journeys                    float64
+	DiskClass                   string
+}
+
+// healthResult is a reading run through a model.
+type healthResult struct {
+	Score      float64
+	Status     string
+	Severities map[string]float64
+	Weights    map[string]float64
+}
+
+func (m HealthModel) severity(k string, v float64, diskClass string) float64 {
+	c := m.Components[k]
+	pts := c.Curve
+	if alt, ok := c.ByClass[strings.ToUpper(diskClass)]; ok && k == "disk" {
+		pts = alt
+	}
+	return piecewise(v, pts)
+}
+
+// Score turns a reading into a 0-100 score and a status.
+func (m HealthModel) Score(r healthReading) healthResult {
+	sev := map[string]float64{
+		"cpu":      m.severity("cpu", r.CPU, r.DiskClass),
+		"mem":      m.severity("mem", r.Mem, r.DiskClass),
+		"disk":     m.severity("disk", r.Disk, r.DiskClass),
+		"db":       m.severity("db", float64(r.DBMs), r.DiskClass),
+		"net":      0,
+		"svc":      m.severity("svc", float64(r.SvcMs), r.DiskClass),
+		"journeys": m.severity("journeys", r.Journeys, r.DiskClass),
+	}
+	if r.NetMs > 0 {
+		sev["net"] = m.severity("net", float64(r.NetMs), r.DiskClass)
+	}
+	base := make(map[string]float64, len(m.Components))
+	for k, c := range m.Components {
+		base[k] = c.Weight
+	}
+	w := dynamicWeights(base, sev, m.DynamicFactor)
+	score := calcHealthScore(sev, w)
+	status := "Healthy"
+	if score < m.Thresholds.Critical {
+		status = "Critical"
+	} else if score < m.Thresholds.Warning {
+		status = "Warning"
+	}
+	return healthResult{Score: score, Status: status, Severities: sev, Weights: w}
+}
+
+// healthEngine holds the active model, replaced whole on reload.
+type healthEngine struct {
+	mu       sync.RWMutex
+	model    HealthModel
+	source   string
+	version  string
+	loadedAt time.Time
+	modTime  time.Time
+	lastErr  string
+}
+
+var healthScoring = newHealthEngine()
+
+func newHealthEngine() *healthEngine {
+	m := defaultHealthModel()
+	return &healthEngine{model: m, source: "default", version: modelVersion(m), loadedAt: time.Now()}
+}
+
+func modelVersion(m HealthModel) string {
+	sum := sha256.Sum256(mustJSON(m))
+	return hex.EncodeToString(sum[:6])
+}
+
+// Model returns the active model.
+func (e *healthEngine) Model() HealthModel {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.model
+}
+
+// parseHealthModel reads JSON or YAML over the default model; a component
+// it lists replaces the default one whole.
+func parseHealthModel(path string, b []byte) (HealthModel, error) {
+	m := defaultHealthModel()
+	var err error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(b))
+		dec.KnownFields(true)
+		if err = dec.Decode(&m); errors.Is(err, io.EOF) {
+			err = nil
+		}
+	default:
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&m)
+	}
+	if err != nil {
+		return m, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if err := m.Validate(); err != nil {
+		return m, fmt.Errorf("%s: %w", path, err)
+	}
+	return m, nil
+}
+
+// load reads path if it changed; a bad file keeps the active model.
+func (e *healthEngine) load(path string, force bool) (bool, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	e.mu.RLock()
+	same := !force && e.source == path && fi.ModTime().Equal(e.modTime)
+	e.mu.RUnlock()
+	if same {
+		return false, nil
+	}
+	b, err := os.ReadFile(path)
+	if err == nil {
+		var m HealthModel
+		if m, err = parseHealthModel(path, b); err == nil {
+			e.mu.Lock()
+			e.model, e.source, e.version = m, path, modelVersion(m)
+			e.loadedAt, e.modTime, e.lastErr = time.Now(), fi.ModTime(), ""
+			e.mu.Unlock()
+			return true, nil
+		}
+	}
+	e.mu.Lock()
+	e.modTime, e.lastErr = fi.ModTime(), err.Error()
+	e.mu.Unlock()
+	return false, err
+}
+
+// LoadHealthModel loads HEALTH_MODEL_FILE, failing startup on a bad file.
+func LoadHealthModel() error {
+	path := os.Getenv("HEALTH_MODEL_FILE")
+	if path == "" {
+		return nil
+	}
+	if _, err := healthScoring.load(path, true); err != nil {
+		return fmt.Errorf("health model: %w", err)
+	}
+	log.Printf("health model loaded from %s version=%s", path, healthScoring.info()["version"])
+	return nil
+}
+
+// WatchHealthModel reloads HEALTH_MODEL_FILE when it changes.
+func WatchHealthModel(interval time.Duration) func() {
+	path := os.Getenv("HEALTH_MODEL_FILE")
+	stop := make(chan struct{})
+	if path == "" {
+		return func() {}
+	}
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+			case <-stop:
+				return
+			}
+			changed, err := healthScoring.load(path, false)
+			if err != nil {
+				log.Printf("health model reload failed, keeping version=%s: %v", healthScoring.info()["version"], err)
+			} else if changed {
+				log.Printf("health model reloaded from %s version=%s", path, healthScoring.info()["version"])
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(stop) }) }
+}
+
+func (e *healthEngine) info() map[string]interface{} {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	out := map[string]interface{}{
+		"model":    e.model,
+		"source":   e.source,
+		"version":  e.version,
+		"loadedAt": e.loadedAt.UTC(),
+	}
+	if e.lastErr != "" {
+		out["lastError"] = e.lastErr
+	}
+	return out
+}
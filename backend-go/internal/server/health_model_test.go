@@ -0,0 +1,88 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDefaultHealthModelScores(t *testing.T) {
+	m := defaultHealthModel()
+	if err := m.Validate(); err != nil {
+		t.Fatalf("default model must validate: %v", err)
+	}
+	idle := m.Score(healthReading{CPU: 5, Mem: 20, Disk: 30, DBMs: 2, SvcMs: 210, DiskClass: "SSD"})
+	if idle.Status != "Healthy" || idle.Score < 90 {
+		t.Fatalf("expected an idle box to be healthy, got %+v", idle)
+	}
+	busy := m.Score(healthReading{CPU: 99, Mem: 97, Disk: 98, DBMs: 1500, NetMs: 400, SvcMs: 900, DiskClass: "HDD"})
+	if busy.Status != "Critical" {
+		t.Fatalf("expected a saturated box to be critical, got %+v", busy)
+	}
+	if hdd, ssd := m.severity("disk", 80, "hdd"), m.severity("disk", 80, "SSD"); hdd <= ssd {
+		t.Fatalf("HDD curve must apply by class: hdd=%v ssd=%v", hdd, ssd)
+	}
+}
+
+func TestParseHealthModelOverlaysDefaults(t *testing.T) {
+	m, err := parseHealthModel("model.yaml", []byte(`
+thresholds:
+  warning: 90
+components:
+  cpu:
+    weight: 0.5
+    curve: [[0, 0], [50, 100]]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Thresholds.Warning != 90 || m.Thresholds.Critical != 60 || m.DynamicFactor != 0.5 {
+		t.Fatalf("unset settings must keep defaults: %+v", m.Thresholds)
+	}
+	if m.Components["cpu"].Weight != 0.5 || m.Components["mem"].Weight != 0.22 {
+		t.Fatalf("unexpected components %+v", m.Components)
+	}
+	bad := []string{
+		`{"components": {"cpu": {"weight": 0.2, "curve": [[10, 0], [5, 100]]}}}`,
+		`{"components": {"gpu": {"weight": 0.2, "curve": [[0, 0], [1, 1]]}}}`,
+		`{"thresholds": {"warning": 50, "critical": 70}}`,
+		`{"dynamicFactr": 1}`,
+	}
+	for _, b := range bad {
+		if _, err := parseHealthModel("model.json", []byte(b)); err == nil {
+			t.Errorf("expected %s to be rejected", b)
+		}
+	}
+	if _, err := parseHealthModel("model.yaml", []byte("thresholds:\n  warnng: 90\n")); err == nil {
+		t.Errorf("expected a misspelt yaml key to be rejected")
+	}
+	if _, err := parseHealthModel("model.yml", nil); err != nil {
+		t.Errorf("an empty yaml file should keep the defaults: %v", err)
+	}
+}
+
+func TestHealthEngineReloadKeepsGoodModel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "model.json")
+	if err := os.WriteFile(path, []byte(`{"dynamicFactor": 0.25}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	e := newHealthEngine()
+	if changed, err := e.load(path, false); err != nil || !changed {
+		t.Fatalf("expected first load to apply, changed=%v err=%v", changed, err)
+	}
+	if changed, _ := e.load(path, false); changed {
+		t.Fatalf("an unchanged file must not reload")
+	}
+	later := time.Now().Add(time.Minute)
+	_ = os.WriteFile(path, []byte(`{"dynamicFactor": -1}`), 0644)
+	_ = os.Chtimes(path, later, later)
+	if _, err := e.load(path, false); err == nil {
+		t.Fatalf("expected the invalid file to be rejected")
+	}
+	info := e.info()
+	if e.Model().DynamicFactor != 0.25 || !strings.Contains(info["lastError"].(string), "dynamicFactor") {
+		t.Fatalf("the last good model must stay active, got %+v", info)
+	}
+}
@@ -0,0 +1,255 @@
+package server
+
+import (
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"scholarhub/backend-go/internal/models"
+)
+
+// Rollup bucket sizes. Buckets are aligned to UTC hours and days.
+const (
+	rollupHour = time.Hour
+	rollupDay  = 24 * time.Hour
+)
+
+// healthRawDays is how long raw samples are kept; rollups outlive them.
+const healthRawDays = 30
+
+func envInt(key string, def int) int {
+	if n, err := strconv.Atoi(os.Getenv(key)); err == nil && n > 0 {
+		return n
+	}
+	return def
+}
+
+// rollupRetentionDays is HEALTH_ROLLUP_HOUR_DAYS (180) or _DAY_DAYS (730).
+func rollupRetentionDays(size time.Duration) int {
+	if size == rollupDay {
+		return envInt("HEALTH_ROLLUP_DAY_DAYS", 730)
+	}
+	return envInt("HEALTH_ROLLUP_HOUR_DAYS", 180)
+}
+
+func rollupTable(size time.Duration) string {
+	if size == rollupDay {
+		return models.HealthRollupDay{}.TableName()
+	}
+	return models.HealthRollupHour{}.TableName()
+}
+
+// percentile uses the nearest-rank method on sorted values.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
+
+func summarize(vals []float64) (lo, avg, hi, p95 float64) {
+	s := append([]float64(nil), vals...)
+	sort.Float64s(s)
+	sum := 0.0
+	for _, v := range s {
+		sum += v
+	}
+	return s[0], math.Round(sum/float64(len(s))*100) / 100, s[len(s)-1], percentile(s, 95)
+}
+
+// rollupSamples summarises a non-empty bucket.
+func rollupSamples(bucket time.Time, samples []models.HealthSample) models.HealthRollup {
+	r := models.HealthRollup{BucketStart: bucket, Samples: len(samples)}
+	col := func(get func(models.HealthSample) float64) []float64 {
+		out := make([]float64, len(samples))
+		for i, s := range samples {
+			out[i] = get(s)
+		}
+		return out
+	}
+	r.ScoreMin, r.ScoreAvg, r.ScoreMax, r.ScoreP95 = summarize(col(alertMetrics["score"]))
+	r.CpuMin, r.CpuAvg, r.CpuMax, r.CpuP95 = summarize(col(alertMetrics["cpuUsed"]))
+	r.MemMin, r.MemAvg, r.MemMax, r.MemP95 = summarize(col(alertMetrics["memUsed"]))
+	r.DiskMin, r.DiskAvg, r.DiskMax, r.DiskP95 = summarize(col(alertMetrics["diskUsed"]))
+	r.DbLatencyMin, r.DbLatencyAvg, r.DbLatencyMax, r.DbLatencyP95 = summarize(col(alertMetrics["dbLatencyMs"]))
+	r.EndpointMin, r.EndpointAvg, r.EndpointMax, r.EndpointP95 = summarize(col(alertMetrics["endpointMs"]))
+	r.ApiMin, r.ApiAvg, r.ApiMax, r.ApiP95 = summarize(col(alertMetrics["apiP95Ms"]))
+	target := sloLatencyMs()
+	for _, s := range samples {
+		if len(s.Anomalies) > 0 {
+			r.AnomalyCount++
+		}
+		if float64(s.ApiP95Ms) <= target {
+			r.LatencyGoodCount++
+		}
+		switch s.Status {
+		case "Healthy":
+			r.HealthyCount++
+		case "Warning":
+			r.WarningCount++
+		case "Critical":
+			r.CriticalCount++
+		}
+	}
+	return r
+}
+
+// bucketSamples groups time-ordered samples into buckets of size.
+func bucketSamples(samples []models.HealthSample, size time.Duration) []models.HealthRollup {
+	var out []models.HealthRollup
+	for i := 0; i < len(samples); {
+		b := samples[i].CreateTime.UTC().Truncate(size)
+		j := i
+		for j < len(samples) && samples[j].CreateTime.UTC().Truncate(size).Equal(b) {
+			j++
+		}
+		out = append(out, rollupSamples(b, samples[i:j]))
+		i = j
+	}
+	return out
+}
+
+func saveRollups(db *gorm.DB, size time.Duration, rs []models.HealthRollup) error {
+	if len(rs) == 0 {
+		return nil
+	}
+	tx := db.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "bucketStart"}}, UpdateAll: true})
+	if size == rollupDay {
+		rows := make([]models.HealthRollupDay, len(rs))
+		for i, r := range rs {
+			rows[i].HealthRollup = r
+		}
+		return tx.Create(&rows).Error
+	}
+	rows := make([]models.HealthRollupHour, len(rs))
+	for i, r := range rs {
+		rows[i].HealthRollup = r
+	}
+	return tx.Create(&rows).Error
+}
+
+// rollupUpTo stores every completed bucket after the newest one. Saving
+// is an upsert, so overlapping runs are harmless.
+func rollupUpTo(db *gorm.DB, size time.Duration, now time.Time) error {
+	end := now.UTC().Truncate(size)
+	var last []time.Time
+	if err := db.Table(rollupTable(size)).Order("\"bucketStart\" desc").Limit(1).Pluck("bucketStart", &last).Error; err != nil {
+		return err
+	}
+	var from time.Time
+	if len(last) > 0 {
+		from = last[0].Add(size)
+	} else {
+		var first models.HealthSample
+		if err := db.Order("\"createTime\" asc").Limit(1).Find(&first).Error; err != nil || first.ID == 0 {
+			return err
+		}
+		from = first.CreateTime.UTC().Truncate(size)
+	}
+	for from.Before(end) {
+		to := from.Add(rollupDay)
+		if to.After(end) {
+			to = end
+		}
+		var samples []models.HealthSample
+		if err := db.Where("\"createTime\" >= ? AND \"createTime\" < ?", from, to).Order("\"createTime\" asc").Find(&samples).Error; err != nil {
+			return err
+		}
+		if err := saveRollups(db, size, bucketSamples(samples, size)); err != nil {
+			return err
+		}
+		from = to
+	}
+	return nil
+}
+
+// startRollups updates the rollups every five minutes on the leader.
+func (hc *HealthCollector) startRollups() {
+	hc.wg.Add(1)
+	go func() {
+		defer hc.wg.Done()
+		t := time.NewTicker(5 * time.Minute)
+		defer t.Stop()
+		for {
+			now := time.Now()
+			for _, size := range []time.Duration{rollupHour, rollupDay} {
+				if !isLeader() {
+					break
+				}
+				if err := rollupUpTo(hc.db, size, now); err != nil {
+					log.Printf("health rollup error step=%s err=%v", size, err)
+				}
+			}
+			select {
+			case <-t.C:
+			case <-hc.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// trendStep is raw up to two days, hourly up to sixty, daily beyond.
+func trendStep(span time.Duration) string {
+	switch {
+	case span <= 48*time.Hour:
+		return "raw"
+	case span <= 60*24*time.Hour:
+		return "hour"
+	}
+	return "day"
+}
+
+// rollupTrend returns the buckets in [from, to), including the open one.
+func rollupTrend(db *gorm.DB, size time.Duration, from, to time.Time) ([]models.HealthRollup, error) {
+	var items []models.HealthRollup
+	err := db.Table(rollupTable(size)).
+		Where("\"bucketStart\" >= ? AND \"bucketStart\" < ?", from.UTC().Truncate(size), to).
+		Order("\"bucketStart\" asc").Find(&items).Error
+	if err != nil {
+		return nil, err
+	}
+	open := time.Now().UTC().Truncate(size)
+	if to.After(open) && (len(items) == 0 || items[len(items)-1].BucketStart.Before(open)) {
+		start := open
+		if from.After(start) {
+			start = from
+		}
+		var samples []models.HealthSample
+		if err := db.Where("\"createTime\" >= ? AND \"createTime\" < ?", start, to).Order("\"createTime\" asc").Find(&samples).Error; err != nil {
+			return nil, err
+		}
+		if len(samples) > 0 {
+			items = append(items, rollupSamples(open, samples))
+		}
+	}
+	return items, nil
+}
+
+// instanceTrend buckets one instance's raw samples in [from, to).
+func instanceTrend(db *gorm.DB, size time.Duration, instance string, from, to time.Time) ([]models.HealthRollup, error) {
+	if floor := time.Now().AddDate(0, 0, -healthRawDays); from.Before(floor) {
+		from = floor
+	}
+	var samples []models.HealthSample
+	err := db.Where("\"instanceId\" = ? AND \"createTime\" >= ? AND \"createTime\" < ?", instance, from, to).
+		Order("\"createTime\" asc").Find(&samples).Error
+	if err != nil {
+		return nil, err
+	}
+	items := bucketSamples(samples, size)
+	if items == nil {
+		items = []models.HealthRollup{}
+	}
+	return items, nil
+}
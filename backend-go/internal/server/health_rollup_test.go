@@ -0,0 +1,72 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"scholarhub/backend-go/internal/models"
+)
+
+func TestRollupSamplesSummarisesBucket(t *testing.T) {
+	b := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	var samples []models.HealthSample
+	for i := 1; i <= 20; i++ {
+		st := "Healthy"
+		if i > 18 {
+			st = "Warning"
+		}
+		samples = append(samples, models.HealthSample{Score: 100 - i, CpuUsed: float64(i), DbLatencyMs: int64(i * 10), Status: st, CreateTime: b.Add(time.Duration(i) * time.Minute)})
+	}
+	r := rollupSamples(b, samples)
+	if r.Samples != 20 || r.CpuMin != 1 || r.CpuMax != 20 || r.CpuAvg != 10.5 || r.CpuP95 != 19 {
+		t.Fatalf("unexpected cpu summary %+v", r)
+	}
+	if r.ScoreMin != 80 || r.ScoreMax != 99 || r.DbLatencyP95 != 190 {
+		t.Fatalf("unexpected score/db summary %+v", r)
+	}
+	if r.HealthyCount != 18 || r.WarningCount != 2 || r.CriticalCount != 0 {
+		t.Fatalf("unexpected status counts %+v", r)
+	}
+}
+
+func TestBucketSamplesSplitsOnBoundaries(t *testing.T) {
+	t0 := time.Date(2026, 3, 1, 9, 58, 0, 0, time.UTC)
+	var samples []models.HealthSample
+	for i := 0; i < 6; i++ {
+		samples = append(samples, models.HealthSample{Score: 90, CreateTime: t0.Add(time.Duration(i) * time.Minute)})
+	}
+	rs := bucketSamples(samples, rollupHour)
+	if len(rs) != 2 || rs[0].Samples != 2 || rs[1].Samples != 4 || !rs[1].BucketStart.Equal(t0.Add(2*time.Minute)) {
+		t.Fatalf("unexpected buckets %+v", rs)
+	}
+	if days := bucketSamples(samples, rollupDay); len(days) != 1 || days[0].Samples != 6 {
+		t.Fatalf("expected one day bucket, got %+v", days)
+	}
+}
+
+func TestTrendStepFollowsRange(t *testing.T) {
+	for span, want := range map[time.Duration]string{
+		6 * time.Hour:        "raw",
+		7 * 24 * time.Hour:   "hour",
+		30 * 24 * time.Hour:  "hour",
+		180 * 24 * time.Hour: "day",
+	} {
+		if got := trendStep(span); got != want {
+			t.Fatalf("trendStep(%s) = %s, want %s", span, got, want)
+		}
+	}
+}
+
+func TestCollectorStopEndsBackgroundJobs(t *testing.T) {
+	db := newTestDB(t, &models.HealthSample{}, &models.HealthRollupHour{}, &models.HealthRollupDay{}, &models.HealthIncident{})
+	hc := &HealthCollector{db: db, stopCh: make(chan struct{})}
+	hc.startRetention(healthRawDays)
+	hc.startRollups()
+	done := make(chan struct{})
+	go func() { hc.Stop(); hc.Stop(); close(done) }()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("rollup and retention goroutines did not stop")
+	}
+}
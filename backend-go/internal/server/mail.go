@@ -0,0 +1,151 @@
+package server
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Mail struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+	Headers map[string]string
+}
+
+type Mailer interface {
+	Send(m Mail) error
+}
+
+// SMTPMailer talks plain SMTP. Port 465 uses implicit TLS, other ports try
+// STARTTLS; with no SMTP_USER it sends unauthenticated, which is what a
+// local sink such as MailHog expects.
+type SMTPMailer struct {
+	Host string
+	Port int
+	User string
+	Pass string
+	From string
+}
+
+var (
+	mailerOnce sync.Once
+	mailer     Mailer
+)
+
+// defaultMailer builds the process mailer from SMTP_* env, the same
+// variables the Node service reads. It is nil when SMTP_HOST is unset.
+func defaultMailer() Mailer {
+	mailerOnce.Do(func() {
+		host := strings.TrimSpace(os.Getenv("SMTP_HOST"))
+		if host == "" {
+			return
+		}
+		port, _ := strconv.Atoi(os.Getenv("SMTP_PORT"))
+		if port <= 0 {
+			port = 465
+		}
+		from := os.Getenv("SMTP_FROM")
+		if from == "" {
+			from = os.Getenv("SMTP_USER")
+		}
+		if from == "" {
+			from = "noreply@scholarhub.local"
+		}
+		mailer = &SMTPMailer{Host: host, Port: port, User: os.Getenv("SMTP_USER"), Pass: os.Getenv("SMTP_PASS"), From: from}
+	})
+	return mailer
+}
+
+func (s *SMTPMailer) Send(m Mail) error {
+	addr := net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+	var c *smtp.Client
+	var err error
+	if s.Port == 465 {
+		conn, derr := tls.DialWithDialer(&net.Dialer{Timeout: 10 * time.Second}, "tcp", addr, &tls.Config{ServerName: s.Host})
+		if derr != nil {
+			return derr
+		}
+		c, err = smtp.NewClient(conn, s.Host)
+	} else {
+		c, err = smtp.Dial(addr)
+	}
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok && s.Port != 465 {
+		if err := c.StartTLS(&tls.Config{ServerName: s.Host}); err != nil {
+			return err
+		}
+	}
+	if s.User != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.User, s.Pass, s.Host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(s.From); err != nil {
+		return err
+	}
+	if err := c.Rcpt(m.To); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(buildMIME(s.From, m)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// buildMIME renders a multipart/alternative message so clients pick the
+// HTML part and fall back to text.
+func buildMIME(from string, m Mail) []byte {
+	var b bytes.Buffer
+	boundary := fmt.Sprintf("sh-%d", time.Now().UnixNano())
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", m.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", m.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	for k, v := range m.Headers {
+		fmt.Fprintf(&b, "%s: %s\r\n", k, v)
+	}
+	if m.HTML == "" {
+		b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+		b.WriteString(m.Text)
+		return b.Bytes()
+	}
+	fmt.Fprintf(&b, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", boundary)
+	fmt.Fprintf(&b, "--%s\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s\r\n", boundary, m.Text)
+	fmt.Fprintf(&b, "--%s\r\nContent-Type: text/html; charset=UTF-8\r\n\r\n%s\r\n", boundary, m.HTML)
+	fmt.Fprintf(&b, "--%s--\r\n", boundary)
+	return b.Bytes()
+}
+
+func sendMailAsync(m Mail) {
+	ml := defaultMailer()
+	if ml == nil || m.To == "" {
+		return
+	}
+	go func() {
+		if err := ml.Send(m); err != nil {
+			log.Printf("mail send error to=%s subject=%q err=%v", m.To, m.Subject, err)
+		}
+	}()
+}
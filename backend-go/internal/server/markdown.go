@@ -0,0 +1,97 @@
+package server
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	gmhtml "github.com/yuin/goldmark/renderer/html"
+)
+
+// markdown is CommonMark plus GFM; raw HTML is dropped and single newlines
+// become <br> as with the old renderer.
+var markdown = goldmark.New(
+	goldmark.WithExtensions(
+		extension.NewTable(extension.WithTableCellAlignMethod(extension.TableCellAlignAttribute)),
+		extension.Strikethrough,
+		extension.Linkify,
+		extension.TaskList,
+	),
+	goldmark.WithRendererOptions(gmhtml.WithHardWraps()),
+)
+
+// htmlPolicy allows what markdown produces, with http(s), mailto or
+// relative links only.
+var htmlPolicy = func() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+	p.AllowElements("p", "br", "hr", "h1", "h2", "h3", "h4", "h5", "h6",
+		"strong", "em", "b", "i", "del", "code", "pre", "blockquote",
+		"ul", "ol", "li", "table", "thead", "tbody", "tr", "th", "td")
+	p.AllowAttrs("start").Matching(bluemonday.Integer).OnElements("ol")
+	p.AllowAttrs("align").Matching(regexp.MustCompile(`^(left|center|right)$`)).OnElements("th", "td")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+#-]+$`)).OnElements("code")
+	p.AllowAttrs("href").OnElements("a")
+	p.AllowAttrs("src", "alt").OnElements("img")
+	p.AllowAttrs("title").OnElements("a", "img")
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").Matching(regexp.MustCompile(`^(checked|disabled)?$`)).OnElements("input")
+	p.AllowURLSchemes("http", "https", "mailto")
+	p.AllowRelativeURLs(true)
+	p.RequireParseableURLs(true)
+	p.RequireNoFollowOnLinks(true)
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+	return p
+}()
+
+// renderMarkdown turns announcement markdown into sanitized HTML.
+func renderMarkdown(md string) string {
+	var buf bytes.Buffer
+	if err := markdown.Convert([]byte(md), &buf); err != nil {
+		return "<p>" + html.EscapeString(md) + "</p>"
+	}
+	return sanitizeMarkdownHTML(buf.String())
+}
+
+func sanitizeMarkdownHTML(h string) string {
+	return htmlPolicy.Sanitize(h)
+}
+
+// RerenderAnnouncements re-renders every announcement, trashed ones
+// included, and saves the ones that changed, returning how many.
+func RerenderAnnouncements(store AnnouncementStore) (int, error) {
+	items, err := store.List(AnnouncementFilter{})
+	if err != nil {
+		return 0, err
+	}
+	trashed, err := store.List(AnnouncementFilter{Trashed: true})
+	if err != nil {
+		return 0, err
+	}
+	items = append(items, trashed...)
+	n := 0
+	for _, af := range items {
+		changed := false
+		if out := renderMarkdown(af.Markdown); out != af.HTML {
+			af.HTML = out
+			changed = true
+		}
+		for l, t := range af.Translations {
+			if out := renderMarkdown(t.Markdown); out != t.HTML {
+				t.HTML = out
+				af.Translations[l] = t
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+		if err := store.Save(af); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite testdata/markdown/*.html from the current renderer")
+
+// TestRenderMarkdownGolden renders every testdata/markdown/*.md and compares
+// it with the .html next to it. Run with -update after an intended change.
+func TestRenderMarkdownGolden(t *testing.T) {
+	files, _ := filepath.Glob(filepath.Join("testdata", "markdown", "*.md"))
+	if len(files) == 0 {
+		t.Fatal("no golden inputs found")
+	}
+	for _, f := range files {
+		src, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := renderMarkdown(string(src))
+		golden := strings.TrimSuffix(f, ".md") + ".html"
+		if *updateGolden {
+			if err := os.WriteFile(golden, []byte(got), 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		want, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatalf("%s: %v (run with -update to create it)", golden, err)
+		}
+		if got != string(want) {
+			t.Errorf("%s mismatch\n--- got\n%s\n--- want\n%s", filepath.Base(f), got, want)
+		}
+	}
+}
+
+func TestRenderMarkdownDropsUnsafeContent(t *testing.T) {
+	out := renderMarkdown("[a](javascript:alert(1)) <img src=x onerror=alert(2)> <script>x</script>")
+	for _, bad := range []string{"javascript:", "onerror", "<script"} {
+		if strings.Contains(out, bad) {
+			t.Errorf("output still contains %q: %s", bad, out)
+		}
+	}
+}
+
+func TestRerenderAnnouncements(t *testing.T) {
+	deleted := time.Now()
+	store := newTestStore(t,
+		AnnouncementFile{ID: "a", Markdown: "**bold**", HTML: "<p>&lt;b&gt;bold&lt;/b&gt;</p>"},
+		AnnouncementFile{ID: "b", Markdown: "plain", HTML: renderMarkdown("plain")},
+		AnnouncementFile{ID: "t", Markdown: "**bold**", HTML: "<p>&lt;b&gt;bold&lt;/b&gt;</p>", DeletedAt: &deleted},
+	)
+	n, err := RerenderAnnouncements(store)
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 rewritten, got %d err=%v", n, err)
+	}
+	for _, id := range []string{"a", "t"} {
+		if af, _ := store.Get(id); af.HTML != "<p><strong>bold</strong></p>\n" {
+			t.Fatalf("%s: unexpected html %q", id, af.HTML)
+		}
+	}
+}
+
+func TestAnswerSanitizerKeepsFormatting(t *testing.T) {
+	got := sanitizeHTML(`<p style="color:red"><u>note</u></p><script>x</script><img onerror=alert(1)>`)
+	if !strings.Contains(got, `<p style="color:red"><u>note</u></p>`) || strings.Contains(got, "<script") || strings.Contains(got, "onerror") {
+		t.Fatalf("answers should keep rich formatting but lose scripts, got %s", got)
+	}
+}
@@ -0,0 +1,198 @@
+package server
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"scholarhub/backend-go/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"gorm.io/gorm"
+)
+
+// metricsRegistry holds everything /metrics exposes. It is separate from
+// the client library's default registry so nothing is exported by accident.
+var metricsRegistry = prometheus.NewRegistry()
+
+var healthStatuses = []string{"Healthy", "Warning", "Critical"}
+
+var (
+	healthGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "scholarhub",
+		Subsystem: "health",
+		Name:      "resource_used_percent",
+		Help:      "Resource usage from the latest health sample.",
+	}, []string{"resource"})
+	healthLatency = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "scholarhub",
+		Subsystem: "health",
+		Name:      "latency_seconds",
+		Help:      "Probe latencies from the latest health sample.",
+	}, []string{"probe"})
+	healthScore = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "scholarhub",
+		Subsystem: "health",
+		Name:      "score",
+		Help:      "Health score from the latest sample, 0-100.",
+	})
+	healthStatus = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "scholarhub",
+		Subsystem: "health",
+		Name:      "status",
+		Help:      "1 for the status of the latest health sample, 0 for the others.",
+	}, []string{"status"})
+	healthSampleTime = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "scholarhub",
+		Subsystem: "health",
+		Name:      "last_sample_timestamp_seconds",
+		Help:      "Unix time of the latest health sample.",
+	})
+
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "scholarhub",
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests by route, method and status code.",
+	}, []string{"method", "route", "code"})
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "scholarhub",
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by route and method.",
+		Buckets:   []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	}, []string{"method", "route"})
+
+	journeySuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "scholarhub",
+		Subsystem: "synthetic",
+		Name:      "journey_success",
+		Help:      "1 if the latest run of a synthetic journey passed, 0 if it failed; absent while skipped.",
+	}, []string{"journey"})
+	journeyStepDuration = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "scholarhub",
+		Subsystem: "synthetic",
+		Name:      "step_duration_seconds",
+		Help:      "Duration of each step in the latest run of a synthetic journey.",
+	}, []string{"journey", "step"})
+	journeyFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "scholarhub",
+		Subsystem: "synthetic",
+		Name:      "step_failures_total",
+		Help:      "Synthetic journey steps that failed.",
+	}, []string{"journey", "step"})
+)
+
+func init() {
+	metricsRegistry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		healthGauge, healthLatency, healthScore, healthStatus, healthSampleTime,
+		httpRequests, httpDuration,
+		journeySuccess, journeyStepDuration, journeyFailures,
+	)
+	for _, topic := range []string{TopicHealth, TopicNotification, TopicAnnouncement} {
+		topic := topic
+		metricsRegistry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace:   "scholarhub",
+			Subsystem:   "sse",
+			Name:        "subscribers",
+			Help:        "Open event-stream subscriptions on this instance.",
+			ConstLabels: prometheus.Labels{"topic": topic},
+		}, func() float64 { return float64(hub.SubscriberCount(topic)) }))
+	}
+}
+
+// recordHealthMetrics mirrors a health sample into the gauges.
+func recordHealthMetrics(s models.HealthSample, netLatencyMs int64) {
+	healthGauge.WithLabelValues("cpu").Set(s.CpuUsed)
+	healthGauge.WithLabelValues("mem").Set(s.MemUsed)
+	healthGauge.WithLabelValues("disk").Set(s.DiskUsed)
+	healthLatency.WithLabelValues("db").Set(float64(s.DbLatencyMs) / 1000)
+	healthLatency.WithLabelValues("net").Set(float64(netLatencyMs) / 1000)
+	healthLatency.WithLabelValues("sample").Set(float64(s.EndpointMs) / 1000)
+	healthLatency.WithLabelValues("api").Set(float64(s.ApiP95Ms) / 1000)
+	healthScore.Set(float64(s.Score))
+	for _, st := range healthStatuses {
+		v := 0.0
+		if st == s.Status {
+			v = 1
+		}
+		healthStatus.WithLabelValues(st).Set(v)
+	}
+	healthSampleTime.Set(float64(time.Now().Unix()))
+}
+
+// recordJourneyMetrics mirrors one journey of a synthetic pass.
+func recordJourneyMetrics(res journeyResult) {
+	if res.Skipped {
+		journeySuccess.DeleteLabelValues(res.Journey)
+	} else if res.OK {
+		journeySuccess.WithLabelValues(res.Journey).Set(1)
+	} else {
+		journeySuccess.WithLabelValues(res.Journey).Set(0)
+	}
+	for _, s := range res.Steps {
+		if s.Skipped {
+			continue
+		}
+		journeyStepDuration.WithLabelValues(res.Journey, s.Step).Set(s.DurationMs / 1000)
+		if !s.OK {
+			journeyFailures.WithLabelValues(res.Journey, s.Step).Inc()
+		}
+	}
+}
+
+// RegisterMetrics exposes /metrics and adds the DB pool collector. With
+// METRICS_ADDR set it gets its own listener there (e.g. 127.0.0.1:9464)
+// and stays off the public port; otherwise it is mounted on r and needs
+// METRICS_TOKEN as a bearer token or ?token=. With neither it is not
+// exposed at all. The returned func stops the separate listener.
+func RegisterMetrics(r *gin.Engine, db *gorm.DB) func() {
+	if sqlDB, err := db.DB(); err == nil {
+		metricsRegistry.MustRegister(collectors.NewDBStatsCollector(sqlDB, "scholarhub"))
+	}
+	h := promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{})
+	token := os.Getenv("METRICS_TOKEN")
+	if addr := os.Getenv("METRICS_ADDR"); addr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metricsAuth(token, h))
+		srv := &http.Server{Addr: addr, Handler: mux}
+		go func() {
+			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Printf("metrics listener error addr=%s err=%v", addr, err)
+			}
+		}()
+		log.Printf("metrics listening on %s", addr)
+		return func() { _ = srv.Close() }
+	}
+	if token == "" {
+		log.Printf("metrics disabled: set METRICS_TOKEN or METRICS_ADDR")
+		return func() {}
+	}
+	r.GET("/metrics", gin.WrapH(metricsAuth(token, h)))
+	return func() {}
+}
+
+// metricsAuth requires token when one is configured.
+func metricsAuth(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if token != "" {
+			got := req.URL.Query().Get("token")
+			if h := req.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+				got = strings.TrimPrefix(h, "Bearer ")
+			}
+			if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+		next.ServeHTTP(w, req)
+	})
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"scholarhub/backend-go/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestHTTPMetricsUseRoutePattern(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RequestTelemetry())
+	r.GET("/api/items/:id", func(c *gin.Context) { c.Status(204) })
+
+	before := testutil.ToFloat64(httpRequests.WithLabelValues("GET", "/api/items/:id", "204"))
+	for _, id := range []string{"1", "2"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/items/"+id, nil))
+	}
+	if got := testutil.ToFloat64(httpRequests.WithLabelValues("GET", "/api/items/:id", "204")) - before; got != 2 {
+		t.Fatalf("expected both requests on one series, got %v", got)
+	}
+}
+
+func TestHTTPMetricsSkipProbesStreamsAndSynthetic(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RequestTelemetry())
+	r.GET("/livez", func(c *gin.Context) { c.Status(200) })
+	r.GET("/api/metrics-skip/stream", func(c *gin.Context) {
+		c.Header("Content-Type", "text/event-stream")
+		c.String(200, "data: x\n\n")
+	})
+	r.GET("/api/metrics-skip/item", func(c *gin.Context) { c.Status(200) })
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/livez", nil))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/metrics-skip/stream", nil))
+	req := httptest.NewRequest(http.MethodGet, "/api/metrics-skip/item", nil)
+	r.ServeHTTP(httptest.NewRecorder(), req.WithContext(context.WithValue(req.Context(), syntheticKey{}, true)))
+	req = httptest.NewRequest(http.MethodGet, "/api/metrics-skip/item", nil)
+	req.Header.Set(syntheticHeader, syntheticSecret)
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	for _, route := range []string{"/livez", "/api/metrics-skip/stream", "/api/metrics-skip/item"} {
+		if n := testutil.ToFloat64(httpRequests.WithLabelValues("GET", route, "200")); n != 0 {
+			t.Fatalf("%s should not be counted, got %v", route, n)
+		}
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/metrics-skip/item", nil)
+	req.Header.Set(syntheticHeader, "forged")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	if n := testutil.ToFloat64(httpRequests.WithLabelValues("GET", "/api/metrics-skip/item", "200")); n != 1 {
+		t.Fatalf("a client-set header must not hide the request, got %v", n)
+	}
+}
+
+func TestMetricsEndpointRequiresToken(t *testing.T) {
+	recordHealthMetrics(models.HealthSample{Score: 87, Status: "Healthy", CpuUsed: 12.5}, 0)
+	h := metricsAuth("s3cret", promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without token, got %d", w.Code)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	body := w.Body.String()
+	for _, want := range []string{
+		"scholarhub_health_score 87",
+		`scholarhub_health_status{status="Healthy"} 1`,
+		`scholarhub_health_resource_used_percent{resource="cpu"} 12.5`,
+		`scholarhub_sse_subscribers{topic="notification"}`,
+		"go_goroutines",
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("metrics output is missing %q", want)
+		}
+	}
+}
@@ -1,8 +1,15 @@
 package server
 
 import (
+	"bytes"
+	"crypto/hmac"
+	"html/template"
+	"log"
 	"net/http"
 	"scholarhub/backend-go/internal/models"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -14,21 +21,318 @@ func NewNotificationsController(db *gorm.DB) *NotificationsController {
 	return &NotificationsController{db: db}
 }
 
+const unreadListLimit = 100
+
 func (n *NotificationsController) Unread(c *gin.Context) {
 	uid := c.GetString("user_id")
 	var list []models.Notification
-	n.db.Where("\"userId\" = ? AND read = false", uid).Order("\"createTime\" desc").Find(&list)
+	n.db.Where("\"userId\" = ? AND read = false AND archived = false", uid).
+		Order("\"createTime\" desc").Limit(unreadListLimit).Find(&list)
 	c.JSON(http.StatusOK, respOk(gin.H{"items": list}))
 }
 
+// History pages through all of the caller's notifications. type takes a
+// comma-separated list; status is read|unread; archived=1 shows the archive.
+func (n *NotificationsController) History(c *gin.Context) {
+	uid := c.GetString("user_id")
+	page, _ := strconv.Atoi(c.Query("page"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(c.Query("pageSize"))
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	tx := n.db.Model(&models.Notification{}).Where("\"userId\" = ?", uid)
+	if types := splitUpper(c.Query("type")); len(types) > 0 {
+		tx = tx.Where("UPPER(type) IN ?", types)
+	}
+	switch strings.ToLower(c.Query("status")) {
+	case "read":
+		tx = tx.Where("read = true")
+	case "unread":
+		tx = tx.Where("read = false")
+	}
+	tx = tx.Where("archived = ?", c.Query("archived") == "1")
+	var total int64
+	tx.Count(&total)
+	var list []models.Notification
+	tx.Order("\"createTime\" desc").Limit(pageSize).Offset((page - 1) * pageSize).Find(&list)
+	c.JSON(http.StatusOK, respOk(gin.H{"items": list, "total": total}))
+}
+
 func (n *NotificationsController) Read(c *gin.Context) {
 	id := c.Param("id")
-	n.db.Model(&models.Notification{}).Where("id = ?", id).Update("read", true)
+	uid := c.GetString("user_id")
+	res := n.db.Model(&models.Notification{}).Where("id = ? AND \"userId\" = ?", id, uid).Update("read", true)
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, respErr(1004, "db_error"))
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, respErr(1006, "not_found"))
+		return
+	}
+	hub.Publish(newEvent(TopicNotification, uid, "read", "", gin.H{"id": id}))
 	c.JSON(http.StatusOK, respOk(gin.H{"ok": true}))
 }
 
+func (n *NotificationsController) Archive(c *gin.Context) {
+	id := c.Param("id")
+	uid := c.GetString("user_id")
+	res := n.db.Model(&models.Notification{}).Where("id = ? AND \"userId\" = ?", id, uid).
+		Updates(map[string]interface{}{"archived": true, "read": true})
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, respErr(1004, "db_error"))
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, respErr(1006, "not_found"))
+		return
+	}
+	hub.Publish(newEvent(TopicNotification, uid, "read", "", gin.H{"id": id}))
+	c.JSON(http.StatusOK, respOk(gin.H{"ok": true}))
+}
+
+func (n *NotificationsController) Delete(c *gin.Context) {
+	id := c.Param("id")
+	uid := c.GetString("user_id")
+	res := n.db.Where("id = ? AND \"userId\" = ?", id, uid).Delete(&models.Notification{})
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, respErr(1004, "db_error"))
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, respErr(1006, "not_found"))
+		return
+	}
+	hub.Publish(newEvent(TopicNotification, uid, "read", "", gin.H{"id": id}))
+	c.JSON(http.StatusOK, respOk(gin.H{"ok": true}))
+}
+
+func (n *NotificationsController) GetPreferences(c *gin.Context) {
+	c.JSON(http.StatusOK, respOk(loadPreference(n.db, c.GetString("user_id"))))
+}
+
+func (n *NotificationsController) UpdatePreferences(c *gin.Context) {
+	uid := c.GetString("user_id")
+	var req struct {
+		MutedTypes         *[]string `json:"mutedTypes"`
+		InApp              *bool     `json:"inApp"`
+		Email              *bool     `json:"email"`
+		Digest             *string   `json:"digest"`
+		Locale             *string   `json:"locale"`
+		TimeZone           *string   `json:"timeZone"`
+		AnnouncementLocale *string   `json:"announcementLocale"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, respErr(1002, "bad_request"))
+		return
+	}
+	p := loadPreference(n.db, uid)
+	if req.MutedTypes != nil {
+		muted := make([]string, 0, len(*req.MutedTypes))
+		for _, t := range *req.MutedTypes {
+			t = strings.ToUpper(strings.TrimSpace(t))
+			if !notiTypes[t] {
+				c.JSON(http.StatusBadRequest, respErr(1002, "invalid_type"))
+				return
+			}
+			muted = append(muted, t)
+		}
+		p.Muted = muted
+	}
+	if req.InApp != nil {
+		p.InApp = *req.InApp
+	}
+	if req.Email != nil {
+		p.Email = *req.Email
+	}
+	if req.Digest != nil {
+		d := strings.ToUpper(strings.TrimSpace(*req.Digest))
+		if d != DigestOff && d != DigestDaily && d != DigestWeekly {
+			c.JSON(http.StatusBadRequest, respErr(1002, "invalid_digest"))
+			return
+		}
+		p.Digest = d
+	}
+	if req.Locale != nil {
+		l := strings.ToLower(strings.TrimSpace(*req.Locale))
+		if !isSupportedLocale(l) {
+			c.JSON(http.StatusBadRequest, respErr(1002, "invalid_locale"))
+			return
+		}
+		p.Locale = l
+	}
+	if req.TimeZone != nil {
+		tz := strings.TrimSpace(*req.TimeZone)
+		if _, err := time.LoadLocation(tz); err != nil {
+			c.JSON(http.StatusBadRequest, respErr(1002, "invalid_time_zone"))
+			return
+		}
+		p.TimeZone = tz
+	}
+	if req.AnnouncementLocale != nil {
+		l := strings.ToLower(strings.TrimSpace(*req.AnnouncementLocale))
+		switch {
+		case l == "":
+			p.AnnouncementLocale = nil
+		case isSupportedLocale(l):
+			p.AnnouncementLocale = &l
+		default:
+			c.JSON(http.StatusBadRequest, respErr(1002, "invalid_locale"))
+			return
+		}
+	}
+	p.MutedTypes = string(mustJSON(p.Muted))
+	if err := n.db.Save(&p.NotificationPreference).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, respErr(1004, "db_error"))
+		return
+	}
+	c.JSON(http.StatusOK, respOk(p))
+}
+
+// unsubscribeUser checks the signed uid/token pair from a digest link,
+// which authenticates these endpoints instead of a JWT.
+func unsubscribeUser(c *gin.Context) (string, bool) {
+	uid, token := c.Query("uid"), c.Query("token")
+	if uid == "" {
+		uid, token = c.PostForm("uid"), c.PostForm("token")
+	}
+	if uid == "" || !hmac.Equal([]byte(token), []byte(unsubscribeToken(uid))) {
+		c.Data(http.StatusBadRequest, "text/html; charset=utf-8", []byte("<p>链接无效 / Invalid link</p>"))
+		return "", false
+	}
+	return uid, true
+}
+
+// UnsubscribePage is where the digest link lands. It only asks for
+// confirmation, so mail scanners that prefetch links change nothing.
+func (n *NotificationsController) UnsubscribePage(c *gin.Context) {
+	uid, ok := unsubscribeUser(c)
+	if !ok {
+		return
+	}
+	var buf bytes.Buffer
+	_ = unsubscribePageTpl.Execute(&buf, gin.H{"UID": uid, "Token": c.Query("token")})
+	c.Data(http.StatusOK, "text/html; charset=utf-8", buf.Bytes())
+}
+
+// Unsubscribe turns off digests and email, from the confirm page or a
+// one-click List-Unsubscribe-Post request.
+func (n *NotificationsController) Unsubscribe(c *gin.Context) {
+	uid, ok := unsubscribeUser(c)
+	if !ok {
+		return
+	}
+	p := loadPreference(n.db, uid)
+	p.Digest = DigestOff
+	p.Email = false
+	p.MutedTypes = string(mustJSON(p.Muted))
+	if err := n.db.Save(&p.NotificationPreference).Error; err != nil {
+		c.Data(http.StatusInternalServerError, "text/html; charset=utf-8", []byte("<p>退订失败，请稍后重试 / Please try again later</p>"))
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte("<p>已退订 ScholarHub 邮件摘要 / You have been unsubscribed from ScholarHub digests.</p>"))
+}
+
+var unsubscribePageTpl = template.Must(template.New("unsubscribe").Parse(`<form method="post" action="unsubscribe">
+<input type="hidden" name="uid" value="{{.UID}}"/><input type="hidden" name="token" value="{{.Token}}"/>
+<p>确定退订 ScholarHub 邮件摘要吗？ / Unsubscribe from ScholarHub digests?</p>
+<button type="submit">退订 / Unsubscribe</button>
+</form>`))
+
+func splitUpper(s string) []string {
+	out := make([]string, 0)
+	for _, x := range strings.Split(s, ",") {
+		if x = strings.ToUpper(strings.TrimSpace(x)); x != "" {
+			out = append(out, x)
+		}
+	}
+	return out
+}
+
 func (n *NotificationsController) ReadAll(c *gin.Context) {
 	uid := c.GetString("user_id")
 	n.db.Model(&models.Notification{}).Where("\"userId\" = ?", uid).Update("read", true)
+	hub.Publish(newEvent(TopicNotification, uid, "read", "", gin.H{"all": true}))
 	c.JSON(http.StatusOK, respOk(gin.H{"ok": true}))
 }
+
+func (n *NotificationsController) unreadCount(uid string) int64 {
+	var count int64
+	n.db.Model(&models.Notification{}).Where("\"userId\" = ? AND read = false", uid).Count(&count)
+	return count
+}
+
+// Stream pushes the caller's new notifications, unread counts and new
+// announcements as server-sent events. Event ids are notification ids, so a
+// reconnecting EventSource (Last-Event-ID header, or lastEventId query) gets
+// whatever it missed replayed from the table.
+func (n *NotificationsController) Stream(c *gin.Context) {
+	uid := c.GetString("user_id")
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	c.Writer.Header().Set("Content-Type", "text/event-stream")
+	c.Writer.Header().Set("Cache-Control", "no-cache")
+	c.Writer.Header().Set("Connection", "keep-alive")
+	c.Writer.Header().Set("X-Accel-Buffering", "no")
+	sub := hub.Subscribe(TopicNotification, uid)
+	defer hub.Unsubscribe(sub)
+	ann := hub.Subscribe(TopicAnnouncement, uid)
+	defer hub.Unsubscribe(ann)
+
+	lastID := strings.TrimSpace(c.GetHeader("Last-Event-ID"))
+	if lastID == "" {
+		lastID = strings.TrimSpace(c.Query("lastEventId"))
+	}
+	if err := writeSSE(c.Writer, "", "ready", mustJSON(gin.H{"serverTime": time.Now().UnixMilli(), "unread": n.unreadCount(uid)})); err != nil {
+		return
+	}
+	if last, err := strconv.Atoi(lastID); err == nil && last > 0 {
+		var missed []models.Notification
+		n.db.Where("\"userId\" = ? AND id > ? AND archived = false", uid, last).Order("id asc").Limit(200).Find(&missed)
+		for _, x := range missed {
+			if err := writeSSE(c.Writer, strconv.Itoa(x.ID), "notification", mustJSON(x)); err != nil {
+				return
+			}
+		}
+	}
+	flusher.Flush()
+
+	ping := time.NewTicker(15 * time.Second)
+	defer ping.Stop()
+	ctxDone := c.Request.Context().Done()
+	var v *viewer
+	for {
+		var err error
+		select {
+		case e := <-sub.C:
+			if e.Name == "notification" {
+				err = writeSSE(c.Writer, e.ID, e.Name, e.Data)
+			}
+			if err == nil {
+				err = writeSSE(c.Writer, "", "unread", mustJSON(gin.H{"count": n.unreadCount(uid)}))
+			}
+		case e := <-ann.C:
+			if v == nil {
+				v = loadViewer(n.db, uid, c.GetString("role"))
+			}
+			if announcementEventVisible(e.Data, v) {
+				err = writeSSE(c.Writer, "", e.Name, e.Data)
+			}
+		case <-ping.C:
+			err = writeSSE(c.Writer, "", "ping", nil)
+		case <-ctxDone:
+			return
+		}
+		if err != nil {
+			log.Printf("notification_stream write error user=%s err=%v", uid, err)
+			return
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"scholarhub/backend-go/internal/models"
+)
+
+func notificationsTestDB(t *testing.T) *NotificationsController {
+	db := newTestDB(t, &models.Notification{}, &models.NotificationPreference{}, &models.User{})
+	return NewNotificationsController(db)
+}
+
+func TestStreamReplaySkipsArchived(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	n := notificationsTestDB(t)
+	n.db.Create(&[]models.Notification{
+		{ID: 1, Type: NotiAnswer, Title: "seen", UserID: "u1"},
+		{ID: 2, Type: NotiAnswer, Title: "missed", UserID: "u1"},
+		{ID: 3, Type: NotiAnswer, Title: "archived", UserID: "u1", Archived: true},
+		{ID: 4, Type: NotiAnswer, Title: "other user", UserID: "u2"},
+	})
+	r := gin.New()
+	r.GET("/stream", asUser("u1", "STUDENT"), n.Stream)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/stream", nil).WithContext(ctx)
+	req.Header.Set("Last-Event-ID", "1")
+	w := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() { r.ServeHTTP(w, req); close(done) }()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	<-done
+
+	body := w.Body.String()
+	if !strings.Contains(body, "id: 2\n") || strings.Contains(body, "id: 3\n") || strings.Contains(body, "other user") {
+		t.Fatalf("replay should send only the missed, unarchived notification:\n%s", body)
+	}
+}
+
+func notificationsRouter(n *NotificationsController, uid string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(asUser(uid, "STUDENT"))
+	r.GET("/notifications", n.Unread)
+	r.GET("/notifications/history", n.History)
+	r.GET("/notifications/preferences", n.GetPreferences)
+	r.PUT("/notifications/preferences", n.UpdatePreferences)
+	r.POST("/notifications/:id/read", n.Read)
+	r.POST("/notifications/:id/archive", n.Archive)
+	r.DELETE("/notifications/:id", n.Delete)
+	return r
+}
+
+func TestNotificationOwnership(t *testing.T) {
+	n := notificationsTestDB(t)
+	n.db.Create(&models.Notification{ID: 1, Type: NotiAnswer, Title: "mine", UserID: "u1"})
+	r := notificationsRouter(n, "u2")
+	for _, req := range [][2]string{{http.MethodPost, "/notifications/1/read"}, {http.MethodPost, "/notifications/1/archive"}, {http.MethodDelete, "/notifications/1"}} {
+		if code, _ := doJSON(t, r, req[0], req[1], nil); code != 404 {
+			t.Fatalf("%s %s by another user: %d", req[0], req[1], code)
+		}
+	}
+	var got models.Notification
+	n.db.First(&got, 1)
+	if got.Read || got.Archived {
+		t.Fatalf("another user's request changed the notification: %+v", got)
+	}
+
+	r = notificationsRouter(n, "u1")
+	if code, _ := doJSON(t, r, http.MethodPost, "/notifications/1/archive", nil); code != 200 {
+		t.Fatalf("archive own: %d", code)
+	}
+	if code, _ := doJSON(t, r, http.MethodDelete, "/notifications/1", nil); code != 200 {
+		t.Fatalf("delete own: %d", code)
+	}
+}
+
+func TestNotificationHistoryFilters(t *testing.T) {
+	n := notificationsTestDB(t)
+	for i := 1; i <= 25; i++ {
+		typ := NotiAnswer
+		if i%5 == 0 {
+			typ = NotiStatus
+		}
+		n.db.Create(&models.Notification{ID: i, Type: typ, Title: "n", UserID: "u1", Read: i <= 10, Archived: i == 25,
+			CreateTime: time.Date(2026, 5, 1, 0, i, 0, 0, time.UTC)})
+	}
+	r := notificationsRouter(n, "u1")
+	history := func(q string) (ids []int, total int) {
+		_, resp := doJSON(t, r, http.MethodGet, "/notifications/history?"+q, nil)
+		data := resp.Data.(map[string]interface{})
+		for _, x := range data["items"].([]interface{}) {
+			ids = append(ids, int(x.(map[string]interface{})["id"].(float64)))
+		}
+		return ids, int(data["total"].(float64))
+	}
+	if ids, total := history("pageSize=10&page=2"); total != 24 || len(ids) != 10 || ids[0] != 14 {
+		t.Fatalf("page 2: %v of %d", ids, total)
+	}
+	if ids, total := history("type=status"); total != 4 || ids[0] != 20 {
+		t.Fatalf("status filter: %v of %d", ids, total)
+	}
+	if _, total := history("status=unread"); total != 14 {
+		t.Fatalf("unread filter: %d", total)
+	}
+	if ids, _ := history("archived=1"); len(ids) != 1 || ids[0] != 25 {
+		t.Fatalf("archive: %v", ids)
+	}
+}
+
+func TestPreferencesAreHonoredBySend(t *testing.T) {
+	n := notificationsTestDB(t)
+	r := notificationsRouter(n, "u1")
+	if code, resp := doJSON(t, r, http.MethodPut, "/notifications/preferences", gin.H{"mutedTypes": []string{"bogus"}}); code != 400 || resp.Msg != "invalid_type" {
+		t.Fatalf("unknown type: %d %+v", code, resp)
+	}
+	if code, resp := doJSON(t, r, http.MethodPut, "/notifications/preferences", gin.H{"digest": "hourly"}); code != 400 || resp.Msg != "invalid_digest" {
+		t.Fatalf("unknown digest: %d %+v", code, resp)
+	}
+	if code, _ := doJSON(t, r, http.MethodPut, "/notifications/preferences", gin.H{"mutedTypes": []string{"comment"}, "inApp": false, "digest": "daily"}); code != 200 {
+		t.Fatalf("update: %d", code)
+	}
+	_, resp := doJSON(t, r, http.MethodGet, "/notifications/preferences", nil)
+	if p := resp.Data.(map[string]interface{}); p["inApp"] != false || p["digest"] != DigestDaily {
+		t.Fatalf("preferences not stored: %+v", p)
+	}
+
+	noti := NewNotifier(n.db)
+	noti.Send("u1", NotiComment, "muted", nil)
+	noti.Send("u1", NotiAnswer, "for the digest", nil)
+	var got []models.Notification
+	n.db.Where("\"userId\" = ?", "u1").Find(&got)
+	if len(got) != 1 || got[0].Title != "for the digest" || !got[0].Archived {
+		t.Fatalf("muted types are dropped and, with in-app off, the rest is kept archived for the digest: %+v", got)
+	}
+	if _, resp := doJSON(t, r, http.MethodGet, "/notifications", nil); len(resp.Data.(map[string]interface{})["items"].([]interface{})) != 0 {
+		t.Fatal("in-app list must stay empty with in-app off")
+	}
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"scholarhub/backend-go/internal/models"
+)
+
+const (
+	NotiAnswer  = "ANSWER"
+	NotiComment = "COMMENT"
+	NotiStatus  = "STATUS"
+	NotiAlert   = "ALERT"
+)
+
+// Notifier creates every models.Notification row.
+type Notifier struct{ db *gorm.DB }
+
+func NewNotifier(db *gorm.DB) *Notifier { return &Notifier{db: db} }
+
+func (n *Notifier) Follow(questionID int, uid string) error {
+	if questionID <= 0 || uid == "" {
+		return nil
+	}
+	return n.db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.QuestionFollow{QuestionID: questionID, UserID: uid}).Error
+}
+
+func (n *Notifier) Unfollow(questionID int, uid string) error {
+	return n.db.Where("\"questionId\" = ? AND \"userId\" = ?", questionID, uid).Delete(&models.QuestionFollow{}).Error
+}
+
+func (n *Notifier) Followers(questionID int) []string {
+	var ids []string
+	n.db.Model(&models.QuestionFollow{}).Where("\"questionId\" = ?", questionID).Pluck("userId", &ids)
+	return ids
+}
+
+// NotifyFollowers notifies the question's followers other than actor.
+func (n *Notifier) NotifyFollowers(questionID int, typ, title, actor string) {
+	for _, uid := range n.Followers(questionID) {
+		if uid == actor {
+			continue
+		}
+		n.Send(uid, typ, title, &questionID)
+	}
+}
+
+// Send records a notification for uid per their preferences. With in-app
+// off the row is stored archived so only email and the digest see it.
+func (n *Notifier) Send(uid, typ, title string, questionID *int) {
+	p := loadPreference(n.db, uid)
+	if p.isMuted(typ) || (!p.InApp && !p.Email && p.Digest == DigestOff) {
+		return
+	}
+	item := models.Notification{Type: typ, QuestionID: questionID, Title: title, UserID: uid, Archived: !p.InApp}
+	if err := n.db.Create(&item).Error; err != nil {
+		log.Printf("notify error user=%s type=%s err=%v", uid, typ, err)
+		return
+	}
+	if p.InApp {
+		hub.Publish(newEvent(TopicNotification, uid, "notification", strconv.Itoa(item.ID), item))
+	}
+	if p.Email {
+		var u models.User
+		if n.db.Select("id, email").First(&u, "id = ?", uid).Error == nil {
+			sendMailAsync(Mail{To: u.Email, Subject: "[ScholarHub] " + title, Text: title})
+		}
+	}
+}
+
+const (
+	DigestOff    = "OFF"
+	DigestDaily  = "DAILY"
+	DigestWeekly = "WEEKLY"
+)
+
+var notiTypes = map[string]bool{NotiAnswer: true, NotiComment: true, NotiStatus: true, NotiAlert: true}
+
+type notiPreference struct {
+	models.NotificationPreference
+	Muted []string `json:"mutedTypes"`
+}
+
+func (p notiPreference) isMuted(typ string) bool {
+	for _, t := range p.Muted {
+		if strings.EqualFold(t, typ) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadPreference returns the stored preference or the in-app-only default.
+func loadPreference(db *gorm.DB, uid string) notiPreference {
+	p := notiPreference{NotificationPreference: models.NotificationPreference{UserID: uid, InApp: true, Digest: DigestOff, Locale: "zh"}}
+	var row models.NotificationPreference
+	if err := db.First(&row, "\"userId\" = ?", uid).Error; err == nil {
+		p.NotificationPreference = row
+		_ = json.Unmarshal([]byte(row.MutedTypes), &p.Muted)
+	}
+	if p.Muted == nil {
+		p.Muted = []string{}
+	}
+	return p
+}
+
+// moveFollowers moves follows of a merged duplicate to the canonical question.
+func moveFollowers(tx *gorm.DB, fromID, toID int) error {
+	if err := tx.Exec(`INSERT INTO "QuestionFollow" ("questionId", "userId", "createTime")
+		SELECT ?, "userId", "createTime" FROM "QuestionFollow" WHERE "questionId" = ?
+		ON CONFLICT DO NOTHING`, toID, fromID).Error; err != nil {
+		return err
+	}
+	return tx.Where("\"questionId\" = ?", fromID).Delete(&models.QuestionFollow{}).Error
+}
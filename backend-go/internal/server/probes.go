@@ -0,0 +1,156 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ProbeCheck is one dependency /readyz waits on, cut off after Timeout.
+type ProbeCheck struct {
+	Name    string
+	Timeout time.Duration
+	Check   func(ctx context.Context) error
+}
+
+type probeResult struct {
+	Name       string `json:"name"`
+	OK         bool   `json:"ok"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+type probeSet struct {
+	mu       sync.RWMutex
+	checks   []ProbeCheck
+	draining atomic.Bool
+}
+
+var probes = &probeSet{}
+
+// RegisterProbe adds or replaces a readiness check.
+func RegisterProbe(name string, timeout time.Duration, check func(ctx context.Context) error) {
+	probes.mu.Lock()
+	defer probes.mu.Unlock()
+	pc := ProbeCheck{Name: name, Timeout: timeout, Check: check}
+	for i := range probes.checks {
+		if probes.checks[i].Name == name {
+			probes.checks[i] = pc
+			return
+		}
+	}
+	probes.checks = append(probes.checks, pc)
+}
+
+// SetDraining makes /readyz fail while in-flight requests finish.
+func SetDraining() {
+	probes.draining.Store(true)
+}
+
+func runProbe(pc ProbeCheck) probeResult {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), pc.Timeout)
+	defer cancel()
+	errc := make(chan error, 1)
+	go func() { errc <- pc.Check(ctx) }()
+	var err error
+	select {
+	case err = <-errc:
+	case <-ctx.Done():
+		err = fmt.Errorf("timed out after %s", pc.Timeout)
+	}
+	r := probeResult{Name: pc.Name, OK: err == nil, DurationMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	return r
+}
+
+// run executes every check concurrently.
+func (p *probeSet) run() []probeResult {
+	p.mu.RLock()
+	checks := append([]ProbeCheck(nil), p.checks...)
+	p.mu.RUnlock()
+	out := make([]probeResult, len(checks))
+	var wg sync.WaitGroup
+	for i, pc := range checks {
+		wg.Add(1)
+		go func(i int, pc ProbeCheck) {
+			defer wg.Done()
+			out[i] = runProbe(pc)
+		}(i, pc)
+	}
+	wg.Wait()
+	return out
+}
+
+// Livez checks no dependency, so an outage never restarts the pod.
+func Livez(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readyz answers 503 if a check fails or the server is draining.
+func Readyz(c *gin.Context) {
+	status, code := "ok", http.StatusOK
+	var results []probeResult
+	if probes.draining.Load() {
+		status, code = "draining", http.StatusServiceUnavailable
+	} else {
+		results = probes.run()
+		for _, r := range results {
+			if !r.OK {
+				status, code = "failing", http.StatusServiceUnavailable
+			}
+		}
+	}
+	body := gin.H{"status": status}
+	if c.Query("verbose") == "1" {
+		if results == nil {
+			results = []probeResult{}
+		}
+		body["checks"] = results
+	}
+	c.JSON(code, body)
+}
+
+func uploadDir() string {
+	if dir := os.Getenv("UPLOAD_DIR"); dir != "" {
+		return dir
+	}
+	return "uploads"
+}
+
+// dirWritable creates and removes a file in dir.
+func dirWritable(dir string) error {
+	f, err := os.CreateTemp(dir, ".readyz-*")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	f.Close()
+	return os.Remove(name)
+}
+
+// RegisterProbes mounts /livez and /readyz with the db and upload checks.
+func RegisterProbes(r *gin.Engine, db *gorm.DB) {
+	RegisterProbe("database", 2*time.Second, func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	})
+	RegisterProbe("uploads", time.Second, func(ctx context.Context) error {
+		return dirWritable(filepath.Clean(uploadDir()))
+	})
+	r.GET("/livez", Livez)
+	r.GET("/readyz", Readyz)
+}
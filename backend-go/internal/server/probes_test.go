@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func readyz(t *testing.T, query string) (int, map[string]interface{}) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/readyz", Readyz)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz"+query, nil))
+	var body map[string]interface{}
+	_ = json.Unmarshal(w.Body.Bytes(), &body)
+	return w.Code, body
+}
+
+func TestReadyzReportsFailingChecks(t *testing.T) {
+	saved := probes
+	probes = &probeSet{}
+	defer func() { probes = saved }()
+
+	RegisterProbe("ok", time.Second, func(ctx context.Context) error { return nil })
+	if code, body := readyz(t, ""); code != 200 || body["checks"] != nil {
+		t.Fatalf("expected a terse 200, got %d %v", code, body)
+	}
+	RegisterProbe("slow", 20*time.Millisecond, func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+	RegisterProbe("broken", time.Second, func(ctx context.Context) error { return errors.New("boom") })
+	code, body := readyz(t, "?verbose=1")
+	if code != http.StatusServiceUnavailable || body["status"] != "failing" {
+		t.Fatalf("expected 503 failing, got %d %v", code, body)
+	}
+	checks := body["checks"].([]interface{})
+	if len(checks) != 3 {
+		t.Fatalf("expected three checks, got %v", checks)
+	}
+	slow := checks[1].(map[string]interface{})
+	if slow["ok"] != false || slow["error"] != "timed out after 20ms" {
+		t.Fatalf("expected the slow check to time out, got %v", slow)
+	}
+
+	SetDraining()
+	if code, body := readyz(t, ""); code != http.StatusServiceUnavailable || body["status"] != "draining" {
+		t.Fatalf("expected draining, got %d %v", code, body)
+	}
+}
+
+func TestDirWritable(t *testing.T) {
+	if err := dirWritable(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	if err := dirWritable("/nonexistent/readyz"); err == nil {
+		t.Fatal("expected an error for a missing directory")
+	}
+}
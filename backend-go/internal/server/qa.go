@@ -5,14 +5,18 @@ import (
 	"net/http"
 	"scholarhub/backend-go/internal/models"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
-type QAController struct{ db *gorm.DB }
+type QAController struct {
+	db   *gorm.DB
+	noti *Notifier
+}
 
-func NewQAController(db *gorm.DB) *QAController { return &QAController{db: db} }
+func NewQAController(db *gorm.DB) *QAController { return &QAController{db: db, noti: NewNotifier(db)} }
 
 func (q *QAController) List(c *gin.Context) {
 	sort := c.Query("sort")
@@ -53,6 +57,16 @@ func (q *QAController) Detail(c *gin.Context) {
 		c.JSON(http.StatusNotFound, respErr(1006, "not_found"))
 		return
 	}
+	if item.Status == "MERGED" {
+		var m models.QuestionMerge
+		if err := q.db.Where("\"duplicateId\" = ?", item.ID).First(&m).Error; err == nil {
+			c.JSON(http.StatusOK, respOk(struct {
+				models.Question
+				MergedInto int `json:"mergedInto"`
+			}{item, m.CanonicalID}))
+			return
+		}
+	}
 	c.JSON(http.StatusOK, respOk(item))
 }
 
@@ -71,11 +85,118 @@ func (q *QAController) Create(c *gin.Context) {
 	images := toJSONB(req.Images)
 	item := models.Question{CourseID: req.CourseID, Title: req.Title, Content: "", ContentHTML: &req.ContentHTML, StudentID: uid}
 	item.Images = images
+	dups := q.findDuplicates(req.CourseID, req.Title, plainText(req.ContentHTML), 0)
 	if err := q.db.Create(&item).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, respErr(1004, "db_error"))
 		return
 	}
-	c.JSON(http.StatusOK, respOk(item))
+	_ = q.noti.Follow(item.ID, uid)
+	c.JSON(http.StatusOK, respOk(struct {
+		models.Question
+		Duplicates []duplicateHit `json:"duplicates"`
+	}{item, dups}))
+}
+
+// Similar lets the ask form preview likely duplicates before submitting.
+func (q *QAController) Similar(c *gin.Context) {
+	var req struct {
+		CourseID    int
+		Title       string
+		ContentHTML string
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, respErr(1002, "bad_request"))
+		return
+	}
+	if req.CourseID <= 0 {
+		c.JSON(http.StatusBadRequest, respErr(1002, "invalid_course"))
+		return
+	}
+	c.JSON(http.StatusOK, respOk(gin.H{"items": q.findDuplicates(req.CourseID, req.Title, plainText(req.ContentHTML), 0)}))
+}
+
+// Merge folds question :id into the canonical question TargetID: answers are
+// moved over and the duplicate is marked MERGED. Only the course teacher or
+// an admin may merge.
+func (q *QAController) Merge(c *gin.Context) {
+	uid := c.GetString("user_id")
+	role := strings.ToUpper(c.GetString("role"))
+	var req struct {
+		TargetID int
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.TargetID <= 0 {
+		c.JSON(http.StatusBadRequest, respErr(1002, "bad_request"))
+		return
+	}
+	var dup, canon models.Question
+	if err := q.db.First(&dup, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, respErr(1006, "not_found"))
+		return
+	}
+	if err := q.db.First(&canon, req.TargetID).Error; err != nil {
+		c.JSON(http.StatusNotFound, respErr(1006, "not_found"))
+		return
+	}
+	if dup.ID == canon.ID || dup.CourseID != canon.CourseID || dup.Status == "MERGED" || canon.Status == "MERGED" {
+		c.JSON(http.StatusBadRequest, respErr(1002, "invalid_merge"))
+		return
+	}
+	if role != "ADMIN" {
+		var course models.Course
+		if role != "TEACHER" || q.db.First(&course, dup.CourseID).Error != nil || course.TeacherID != uid {
+			c.JSON(http.StatusForbidden, respErr(1007, "forbidden"))
+			return
+		}
+	}
+	score := questionSimilarity(dup.Title, questionBody(dup), canon.Title, questionBody(canon))
+	var moved int64
+	err := q.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.Answer{}).Where("\"questionId\" = ?", dup.ID).Update("questionId", canon.ID)
+		if res.Error != nil {
+			return res.Error
+		}
+		moved = res.RowsAffected
+		if err := moveFollowers(tx, dup.ID, canon.ID); err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Question{}).Where("id = ?", dup.ID).Update("status", "MERGED").Error; err != nil {
+			return err
+		}
+		if moved > 0 && canon.Status == "UNANSWERED" {
+			if err := tx.Model(&models.Question{}).Where("id = ?", canon.ID).Update("status", "ANSWERED").Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(&models.QuestionMerge{DuplicateID: dup.ID, CanonicalID: canon.ID, MergedBy: uid, Score: score}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, respErr(1004, "db_error"))
+		return
+	}
+	q.noti.NotifyFollowers(canon.ID, NotiStatus, "您关注的问题《"+dup.Title+"》已合并到《"+canon.Title+"》", uid)
+	c.JSON(http.StatusOK, respOk(gin.H{"ok": true, "canonicalId": canon.ID, "movedAnswers": moved}))
+}
+
+func (q *QAController) Follow(c *gin.Context) {
+	var item models.Question
+	if err := q.db.First(&item, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, respErr(1006, "not_found"))
+		return
+	}
+	if err := q.noti.Follow(item.ID, c.GetString("user_id")); err != nil {
+		c.JSON(http.StatusInternalServerError, respErr(1004, "db_error"))
+		return
+	}
+	c.JSON(http.StatusOK, respOk(gin.H{"following": true}))
+}
+
+func (q *QAController) Unfollow(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if err := q.noti.Unfollow(id, c.GetString("user_id")); err != nil {
+		c.JSON(http.StatusInternalServerError, respErr(1004, "db_error"))
+		return
+	}
+	c.JSON(http.StatusOK, respOk(gin.H{"following": false}))
 }
 
 func toJSONB(arr []string) []byte {
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"html"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"scholarhub/backend-go/internal/models"
+)
+
+const (
+	dupThreshold     = 0.45
+	dupMaxResults    = 5
+	dupCandidateScan = 500
+)
+
+type duplicateHit struct {
+	ID     int     `json:"id"`
+	Title  string  `json:"title"`
+	Status string  `json:"status"`
+	Score  float64 `json:"score"`
+}
+
+var tagRe = regexp.MustCompile(`(?s)<[^>]*>`)
+
+// plainText strips tags and entities from rich-text question bodies.
+func plainText(h string) string {
+	s := tagRe.ReplaceAllString(h, " ")
+	s = html.UnescapeString(s)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// tokenize returns term frequencies: lowercase latin/digit words plus CJK
+// character bigrams, since Chinese text carries no word separators.
+func tokenize(s string) map[string]float64 {
+	tf := map[string]float64{}
+	var word []rune
+	var han []rune
+	flushWord := func() {
+		if len(word) > 1 {
+			tf[string(word)]++
+		}
+		word = word[:0]
+	}
+	flushHan := func() {
+		if len(han) == 1 {
+			tf[string(han)]++
+		}
+		for i := 0; i+1 < len(han); i++ {
+			tf[string(han[i:i+2])]++
+		}
+		han = han[:0]
+	}
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flushWord()
+			han = append(han, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushHan()
+			word = append(word, r)
+		default:
+			flushWord()
+			flushHan()
+		}
+	}
+	flushWord()
+	flushHan()
+	return tf
+}
+
+func cosine(a, b map[string]float64) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	dot, na, nb := 0.0, 0.0, 0.0
+	for k, v := range a {
+		na += v * v
+		if w, ok := b[k]; ok {
+			dot += v * w
+		}
+	}
+	for _, w := range b {
+		nb += w * w
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+// questionSimilarity weighs titles above bodies; when either body is empty
+// only the titles are compared.
+func questionSimilarity(titleA, bodyA, titleB, bodyB string) float64 {
+	ts := cosine(tokenize(titleA), tokenize(titleB))
+	ba, bb := tokenize(bodyA), tokenize(bodyB)
+	if len(ba) == 0 || len(bb) == 0 {
+		return ts
+	}
+	return 0.6*ts + 0.4*cosine(ba, bb)
+}
+
+func questionBody(q models.Question) string {
+	if q.ContentHTML != nil && *q.ContentHTML != "" {
+		return plainText(*q.ContentHTML)
+	}
+	return plainText(q.Content)
+}
+
+// findDuplicates scores recent questions of the same course against the
+// given title/body and returns the best matches above dupThreshold.
+func (q *QAController) findDuplicates(courseID int, title, body string, excludeID int) []duplicateHit {
+	out := make([]duplicateHit, 0)
+	if courseID <= 0 || strings.TrimSpace(title+body) == "" {
+		return out
+	}
+	var list []models.Question
+	q.db.Where("\"courseId\" = ? AND status <> ?", courseID, "MERGED").
+		Order("\"createTime\" desc").Limit(dupCandidateScan).Find(&list)
+	for _, x := range list {
+		if x.ID == excludeID {
+			continue
+		}
+		s := questionSimilarity(title, body, x.Title, questionBody(x))
+		if s >= dupThreshold {
+			out = append(out, duplicateHit{ID: x.ID, Title: x.Title, Status: x.Status, Score: math.Round(s*1000) / 1000})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Score > out[j].Score })
+	if len(out) > dupMaxResults {
+		out = out[:dupMaxResults]
+	}
+	return out
+}
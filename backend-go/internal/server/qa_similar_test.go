@@ -0,0 +1,33 @@
+package server
+
+import "testing"
+
+func TestPlainTextStripsTags(t *testing.T) {
+	got := plainText("<p>求 <b>极限</b>&amp;导数</p>\n<img src=\"x.png\">")
+	if got != "求 极限 &导数" {
+		t.Fatalf("unexpected plain text %q", got)
+	}
+}
+
+func TestTokenizeChineseBigrams(t *testing.T) {
+	tf := tokenize("线性代数 Homework 3")
+	for _, k := range []string{"线性", "性代", "代数", "homework"} {
+		if tf[k] == 0 {
+			t.Fatalf("expected term %q in %v", k, tf)
+		}
+	}
+	if tf["3"] != 0 {
+		t.Fatalf("single-character latin tokens should be dropped")
+	}
+}
+
+func TestQuestionSimilarityRanksDuplicates(t *testing.T) {
+	same := questionSimilarity("第三章习题5怎么做", "求矩阵的特征值", "第三章 习题5 怎么做？", "如何求这个矩阵的特征值")
+	other := questionSimilarity("第三章习题5怎么做", "求矩阵的特征值", "期末考试时间", "考试在哪个教室")
+	if same < dupThreshold {
+		t.Fatalf("expected near-duplicate above threshold, got %f", same)
+	}
+	if other >= dupThreshold {
+		t.Fatalf("expected unrelated question below threshold, got %f", other)
+	}
+}
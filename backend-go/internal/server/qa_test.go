@@ -0,0 +1,87 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"scholarhub/backend-go/internal/models"
+)
+
+func qaTestRouter(t *testing.T) (*gin.Engine, *QAController) {
+	gin.SetMode(gin.TestMode)
+	db := newTestDB(t, &models.Question{}, &models.Answer{}, &models.QuestionFollow{}, &models.Notification{},
+		&models.NotificationPreference{}, &models.AdminLog{})
+	db.Create(&models.Question{ID: 1, Title: "期中范围", StudentID: "s1", Status: "UNANSWERED"})
+	qa := NewQAController(db)
+	answers := NewAnswersController(db)
+	admin := NewAdminController(db)
+	r := gin.New()
+	r.POST("/qa/questions/:id/follow", asUser("s2", "STUDENT"), qa.Follow)
+	r.DELETE("/qa/questions/:id/follow", asUser("s2", "STUDENT"), qa.Unfollow)
+	r.POST("/questions/:id/answers", asUser("t1", "TEACHER"), answers.Create)
+	r.PUT("/admin/questions/:id", asUser("a1", "ADMIN"), admin.AuditQuestion)
+	return r, qa
+}
+
+func notificationsOf(qa *QAController, uid string) []models.Notification {
+	var list []models.Notification
+	qa.db.Where("\"userId\" = ?", uid).Order("id").Find(&list)
+	return list
+}
+
+func TestTeacherAnswerNotifiesFollowers(t *testing.T) {
+	r, qa := qaTestRouter(t)
+	if code, _ := doJSON(t, r, http.MethodPost, "/qa/questions/1/follow", nil); code != 200 {
+		t.Fatalf("follow: %d", code)
+	}
+	if code, _ := doJSON(t, r, http.MethodPost, "/qa/questions/9/follow", nil); code != 404 {
+		t.Fatalf("following a missing question: %d", code)
+	}
+	if code, _ := doJSON(t, r, http.MethodPost, "/questions/1/answers", gin.H{"content": "<p>第1-5章</p>"}); code != 200 {
+		t.Fatalf("answer: %d", code)
+	}
+	got := notificationsOf(qa, "s2")
+	if len(got) != 2 || got[0].Type != NotiAnswer || got[1].Type != NotiStatus {
+		t.Fatalf("follower should get an answer and a status notification, got %+v", got)
+	}
+	if len(notificationsOf(qa, "t1")) != 0 {
+		t.Fatal("the answering teacher must not be notified of their own answer")
+	}
+
+	doJSON(t, r, http.MethodDelete, "/qa/questions/1/follow", nil)
+	doJSON(t, r, http.MethodPost, "/questions/1/answers", gin.H{"content": "补充"})
+	if n := len(notificationsOf(qa, "s2")); n != 2 {
+		t.Fatalf("unfollowed user got %d notifications", n)
+	}
+}
+
+func TestAuditQuestionValidatesStatus(t *testing.T) {
+	r, qa := qaTestRouter(t)
+	doJSON(t, r, http.MethodPost, "/qa/questions/1/follow", nil)
+
+	for _, status := range []string{"", "MERGED", "bogus"} {
+		if code, resp := doJSON(t, r, http.MethodPut, "/admin/questions/1", gin.H{"status": status}); code != 400 || resp.Msg != "invalid_status" {
+			t.Fatalf("status %q: %d %+v", status, code, resp)
+		}
+	}
+	if code, _ := doJSON(t, r, http.MethodPut, "/admin/questions/9", gin.H{"status": "VIOLATION"}); code != 404 {
+		t.Fatalf("missing question: %d", code)
+	}
+	if len(notificationsOf(qa, "s2")) != 0 {
+		t.Fatal("rejected audits must not notify")
+	}
+
+	if code, _ := doJSON(t, r, http.MethodPut, "/admin/questions/1", gin.H{"status": "violation"}); code != 200 {
+		t.Fatalf("audit: %d", code)
+	}
+	got := notificationsOf(qa, "s2")
+	if len(got) != 1 || got[0].Title != "您关注的问题《期中范围》状态变更为违规" {
+		t.Fatalf("unexpected notifications %+v", got)
+	}
+	doJSON(t, r, http.MethodPut, "/admin/questions/1", gin.H{"status": "VIOLATION"})
+	if n := len(notificationsOf(qa, "s2")); n != 1 {
+		t.Fatalf("an unchanged status must not notify again, got %d", n)
+	}
+}
@@ -0,0 +1,385 @@
+package server
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"scholarhub/backend-go/internal/models"
+)
+
+// latencyBounds are eight buckets per doubling from 0.1 ms to a minute,
+// so quantiles are within about 5%.
+var latencyBounds = func() []float64 {
+	var out []float64
+	for v := 0.1; v < 60000; v *= math.Pow(2, 1.0/8) {
+		out = append(out, v)
+	}
+	return append(out, 60000)
+}()
+
+// routeAgg aggregates one route over one or more minutes.
+type routeAgg struct {
+	Count        int
+	ClientErrors int
+	ServerErrors int
+	SumMs        float64
+	MaxMs        float64
+	BytesIn      int64
+	BytesOut     int64
+	Codes        map[int]int
+	Hist         []int
+}
+
+func newRouteAgg() *routeAgg {
+	return &routeAgg{Codes: map[int]int{}, Hist: make([]int, len(latencyBounds)+1)}
+}
+
+func (a *routeAgg) add(code int, ms float64, in, out int64) {
+	a.Count++
+	if code >= 500 {
+		a.ServerErrors++
+	} else if code >= 400 {
+		a.ClientErrors++
+	}
+	a.SumMs += ms
+	if ms > a.MaxMs {
+		a.MaxMs = ms
+	}
+	a.BytesIn += in
+	a.BytesOut += out
+	a.Codes[code]++
+	a.Hist[sort.SearchFloat64s(latencyBounds, ms)]++
+}
+
+func (a *routeAgg) merge(b *routeAgg) {
+	a.Count += b.Count
+	a.ClientErrors += b.ClientErrors
+	a.ServerErrors += b.ServerErrors
+	a.SumMs += b.SumMs
+	if b.MaxMs > a.MaxMs {
+		a.MaxMs = b.MaxMs
+	}
+	a.BytesIn += b.BytesIn
+	a.BytesOut += b.BytesOut
+	for k, v := range b.Codes {
+		a.Codes[k] += v
+	}
+	for i, v := range b.Hist {
+		a.Hist[i] += v
+	}
+}
+
+// quantile is the geometric middle of the q-th request's bucket.
+func (a *routeAgg) quantile(q float64) float64 {
+	if a.Count == 0 {
+		return 0
+	}
+	rank := int(q*float64(a.Count) + 0.999999)
+	seen := 0
+	for i, n := range a.Hist {
+		seen += n
+		if seen >= rank {
+			if i == 0 {
+				return math.Min(latencyBounds[0], a.MaxMs)
+			}
+			if i == len(latencyBounds) {
+				return a.MaxMs
+			}
+			mid := math.Sqrt(latencyBounds[i-1] * latencyBounds[i])
+			return math.Round(math.Min(mid, a.MaxMs)*10) / 10
+		}
+	}
+	return a.MaxMs
+}
+
+type routeKey struct{ Method, Route string }
+
+type telemetryMinute struct {
+	minute int64
+	routes map[routeKey]*routeAgg
+}
+
+// requestTelemetry is a sliding window of per-minute route aggregates.
+type requestTelemetry struct {
+	mu      sync.Mutex
+	minutes []telemetryMinute
+}
+
+func newRequestTelemetry(windowMin int) *requestTelemetry {
+	return &requestTelemetry{minutes: make([]telemetryMinute, windowMin)}
+}
+
+var telemetry = newRequestTelemetry(envInt("TELEMETRY_WINDOW_MIN", 15))
+
+func (t *requestTelemetry) record(at time.Time, k routeKey, code int, ms float64, in, out int64) {
+	m := at.Unix() / 60
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	slot := &t.minutes[m%int64(len(t.minutes))]
+	if slot.minute != m {
+		slot.minute, slot.routes = m, map[routeKey]*routeAgg{}
+	}
+	a, ok := slot.routes[k]
+	if !ok {
+		a = newRouteAgg()
+		slot.routes[k] = a
+	}
+	a.add(code, ms, in, out)
+}
+
+// window merges the minutes in [from, to), both unix minutes.
+func (t *requestTelemetry) window(from, to int64) map[routeKey]*routeAgg {
+	out := map[routeKey]*routeAgg{}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, slot := range t.minutes {
+		if slot.minute < from || slot.minute >= to {
+			continue
+		}
+		for k, a := range slot.routes {
+			m, ok := out[k]
+			if !ok {
+				m = newRouteAgg()
+				out[k] = m
+			}
+			m.merge(a)
+		}
+	}
+	return out
+}
+
+// recent merges the whole window up to and including the current minute.
+func (t *requestTelemetry) recent(now time.Time) map[routeKey]*routeAgg {
+	m := now.Unix() / 60
+	return t.window(m-int64(len(t.minutes))+1, m+1)
+}
+
+// serviceLatencyMs is the p95 over all routes, 0 without traffic.
+func (t *requestTelemetry) serviceLatencyMs(now time.Time) int64 {
+	all := newRouteAgg()
+	for _, a := range t.recent(now) {
+		all.merge(a)
+	}
+	return int64(all.quantile(0.95) + 0.5)
+}
+
+// untracedRoutes are scrapes and probes, left out of telemetry.
+var untracedRoutes = map[string]bool{"/metrics": true, "/livez": true, "/readyz": true}
+
+// RequestTelemetry records requests by route pattern in the metrics and
+// the window. Event streams, untracedRoutes and synthetic requests are
+// left out.
+func RequestTelemetry() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		if untracedRoutes[route] || isSyntheticRequest(c.Request) ||
+			strings.HasPrefix(c.Writer.Header().Get("Content-Type"), "text/event-stream") {
+			return
+		}
+		elapsed := time.Since(start)
+		httpRequests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		httpDuration.WithLabelValues(c.Request.Method, route).Observe(elapsed.Seconds())
+		in := c.Request.ContentLength
+		if in < 0 {
+			in = 0
+		}
+		out := int64(c.Writer.Size())
+		if out < 0 {
+			out = 0
+		}
+		ms := float64(elapsed.Microseconds()) / 1000
+		telemetry.record(start, routeKey{c.Request.Method, route}, c.Writer.Status(), ms, in, out)
+	}
+}
+
+type routeSummary struct {
+	Method       string      `json:"method"`
+	Route        string      `json:"route"`
+	Count        int         `json:"count"`
+	ErrorRate    float64     `json:"errorRate"`
+	ClientErrors int         `json:"clientErrors"`
+	ServerErrors int         `json:"serverErrors"`
+	AvgMs        float64     `json:"avgMs"`
+	P50Ms        float64     `json:"p50Ms"`
+	P95Ms        float64     `json:"p95Ms"`
+	P99Ms        float64     `json:"p99Ms"`
+	MaxMs        float64     `json:"maxMs"`
+	BytesIn      int64       `json:"bytesIn"`
+	BytesOut     int64       `json:"bytesOut"`
+	Codes        map[int]int `json:"codes"`
+}
+
+func summarizeRoute(k routeKey, a *routeAgg) routeSummary {
+	s := routeSummary{Method: k.Method, Route: k.Route, Count: a.Count, ClientErrors: a.ClientErrors, ServerErrors: a.ServerErrors,
+		P50Ms: a.quantile(0.5), P95Ms: a.quantile(0.95), P99Ms: a.quantile(0.99), MaxMs: a.MaxMs,
+		BytesIn: a.BytesIn, BytesOut: a.BytesOut, Codes: a.Codes}
+	if a.Count > 0 {
+		s.AvgMs = float64(int(a.SumMs/float64(a.Count)*10+0.5)) / 10
+		s.ErrorRate = float64(int(float64(a.ServerErrors)/float64(a.Count)*10000+0.5)) / 10000
+	}
+	return s
+}
+
+// sortRoutes orders by "slowest", "errors" or request count.
+func sortRoutes(rs []routeSummary, by string) {
+	sort.SliceStable(rs, func(i, j int) bool {
+		a, b := rs[i], rs[j]
+		switch by {
+		case "slowest":
+			if a.P95Ms != b.P95Ms {
+				return a.P95Ms > b.P95Ms
+			}
+		case "errors":
+			if a.ErrorRate != b.ErrorRate {
+				return a.ErrorRate > b.ErrorRate
+			}
+			if a.ServerErrors != b.ServerErrors {
+				return a.ServerErrors > b.ServerErrors
+			}
+		}
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Route < b.Route
+	})
+}
+
+// TelemetryRoutes lists the window's routes; minCount hides rare ones.
+func (a *AdminController) TelemetryRoutes(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 || limit > 200 {
+		limit = 20
+	}
+	minCount, _ := strconv.Atoi(c.Query("minCount"))
+	now := time.Now()
+	total := newRouteAgg()
+	items := []routeSummary{}
+	for k, agg := range telemetry.recent(now) {
+		total.merge(agg)
+		if agg.Count >= minCount {
+			items = append(items, summarizeRoute(k, agg))
+		}
+	}
+	sortRoutes(items, c.Query("sort"))
+	if len(items) > limit {
+		items = items[:limit]
+	}
+	c.JSON(http.StatusOK, respOk(gin.H{
+		"windowMinutes": len(telemetry.minutes),
+		"total":         summarizeRoute(routeKey{Route: "*"}, total),
+		"items":         items,
+		"persisted":     telemetryPersist(),
+	}))
+}
+
+// TelemetryHistory returns one route's stored minutes per instance.
+func (a *AdminController) TelemetryHistory(c *gin.Context) {
+	if !telemetryPersist() {
+		c.JSON(http.StatusNotFound, respErr(1006, "telemetry_not_persisted"))
+		return
+	}
+	hours, _ := strconv.Atoi(c.Query("hours"))
+	if hours <= 0 || hours > 24*7 {
+		hours = 24
+	}
+	tx := a.db.Where("\"bucketStart\" >= ?", time.Now().Add(-time.Duration(hours)*time.Hour))
+	if r := c.Query("route"); r != "" {
+		tx = tx.Where("route = ?", r)
+	}
+	if m := c.Query("method"); m != "" {
+		tx = tx.Where("method = ?", strings.ToUpper(m))
+	}
+	if inst := c.Query("instance"); inst != "" {
+		tx = tx.Where("\"instanceId\" = ?", inst)
+	}
+	var items []models.RouteTelemetry
+	if err := tx.Order("\"bucketStart\" asc").Limit(10000).Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, respErr(1004, "db_error"))
+		return
+	}
+	c.JSON(http.StatusOK, respOk(gin.H{"items": items}))
+}
+
+// telemetryPersist reports whether TELEMETRY_PERSIST is on.
+func telemetryPersist() bool {
+	v := strings.ToLower(os.Getenv("TELEMETRY_PERSIST"))
+	return v == "1" || v == "true"
+}
+
+// TelemetryPersister stores closed minutes for TELEMETRY_RETENTION_DAYS (7).
+type TelemetryPersister struct {
+	db     *gorm.DB
+	last   int64
+	stopCh chan struct{}
+	done   chan struct{}
+	once   sync.Once
+}
+
+func NewTelemetryPersister(db *gorm.DB) *TelemetryPersister {
+	return &TelemetryPersister{db: db, last: time.Now().Unix()/60 - 1, stopCh: make(chan struct{}), done: make(chan struct{})}
+}
+
+func (p *TelemetryPersister) Start() {
+	if !telemetryPersist() {
+		close(p.done)
+		return
+	}
+	go func() {
+		defer close(p.done)
+		t := time.NewTicker(time.Minute)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				p.flush(time.Now())
+			case <-p.stopCh:
+				p.flush(time.Now())
+				return
+			}
+		}
+	}()
+}
+
+func (p *TelemetryPersister) Stop() {
+	p.once.Do(func() { close(p.stopCh) })
+	<-p.done
+}
+
+// flush writes the minutes that closed since the last flush.
+func (p *TelemetryPersister) flush(now time.Time) {
+	cur := now.Unix() / 60
+	var rows []models.RouteTelemetry
+	for m := p.last + 1; m < cur; m++ {
+		for k, a := range telemetry.window(m, m+1) {
+			s := summarizeRoute(k, a)
+			rows = append(rows, models.RouteTelemetry{BucketStart: time.Unix(m*60, 0), InstanceID: InstanceID(), Method: k.Method, Route: k.Route,
+				Count: s.Count, ClientErrors: s.ClientErrors, ServerErrors: s.ServerErrors, AvgMs: s.AvgMs, P95Ms: s.P95Ms,
+				MaxMs: s.MaxMs, BytesIn: s.BytesIn, BytesOut: s.BytesOut})
+		}
+	}
+	p.last = cur - 1
+	if len(rows) > 0 {
+		if err := p.db.CreateInBatches(rows, 200).Error; err != nil {
+			log.Printf("telemetry persist error rows=%d err=%v", len(rows), err)
+		}
+	}
+	if now.Minute() == 0 && isLeader() {
+		cutoff := now.AddDate(0, 0, -envInt("TELEMETRY_RETENTION_DAYS", 7))
+		_ = p.db.Where("\"bucketStart\" < ?", cutoff).Delete(&models.RouteTelemetry{}).Error
+	}
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"scholarhub/backend-go/internal/models"
+)
+
+// near reports whether got is within the 5% the latency histogram allows.
+func near(got, want float64) bool { return math.Abs(got-want) <= want*0.05 }
+
+func TestRouteAggQuantiles(t *testing.T) {
+	a := newRouteAgg()
+	for i := 0; i < 95; i++ {
+		a.add(200, 8, 0, 100)
+	}
+	for i := 0; i < 5; i++ {
+		a.add(503, 700, 0, 10)
+	}
+	if got := a.quantile(0.5); !near(got, 8) {
+		t.Fatalf("p50 = %v, want about 8ms", got)
+	}
+	if got := a.quantile(0.95); !near(got, 8) {
+		t.Fatalf("p95 = %v, want about 8ms", got)
+	}
+	if got := a.quantile(0.99); !near(got, 700) || got > 700 {
+		t.Fatalf("p99 = %v, want about 700ms and no more than the max", got)
+	}
+	b := newRouteAgg()
+	for i := 1; i <= 100; i++ {
+		b.add(200, float64(i*10), 0, 0)
+	}
+	if got := b.quantile(0.95); !near(got, 950) {
+		t.Fatalf("p95 of 10..1000ms = %v, want about 950ms", got)
+	}
+	s := summarizeRoute(routeKey{"GET", "/x"}, a)
+	if s.ErrorRate != 0.05 || s.ServerErrors != 5 || s.BytesOut != 9550 {
+		t.Fatalf("unexpected summary %+v", s)
+	}
+}
+
+func TestRequestTelemetryWindowDropsOldMinutes(t *testing.T) {
+	tel := newRequestTelemetry(3)
+	t0 := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	k := routeKey{"GET", "/api/items/:id"}
+	tel.record(t0, k, 200, 40, 0, 0)
+	tel.record(t0.Add(time.Minute), k, 200, 40, 0, 0)
+	if got := tel.recent(t0.Add(2 * time.Minute))[k]; got == nil || got.Count != 2 {
+		t.Fatalf("expected both minutes in the window, got %+v", got)
+	}
+	tel.record(t0.Add(3*time.Minute), k, 500, 2000, 0, 0)
+	got := tel.recent(t0.Add(3 * time.Minute))[k]
+	if got == nil || got.Count != 2 || got.ServerErrors != 1 {
+		t.Fatalf("expected the first minute to have left the window, got %+v", got)
+	}
+	if ms := tel.serviceLatencyMs(t0.Add(3 * time.Minute)); !near(float64(ms), 2000) {
+		t.Fatalf("service p95 = %d, want about 2000", ms)
+	}
+}
+
+func TestRequestTelemetryMiddlewareSkipsStreams(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RequestTelemetry())
+	r.POST("/api/telemetry-test/:id", func(c *gin.Context) { c.String(201, "created") })
+	r.GET("/api/telemetry-test/stream", func(c *gin.Context) {
+		c.Header("Content-Type", "text/event-stream")
+		c.String(200, "data: x\n\n")
+	})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/telemetry-test/1", strings.NewReader("hello")))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/telemetry-test/stream", nil))
+
+	win := telemetry.recent(time.Now())
+	a := win[routeKey{"POST", "/api/telemetry-test/:id"}]
+	if a == nil || a.Count != 1 || a.Codes[201] != 1 || a.BytesIn != 5 || a.BytesOut != 7 {
+		t.Fatalf("unexpected aggregate %+v", a)
+	}
+	if win[routeKey{"GET", "/api/telemetry-test/stream"}] != nil {
+		t.Fatal("event streams should not be recorded")
+	}
+}
+
+func TestTelemetryPersisterTagsInstance(t *testing.T) {
+	db := newTestDB(t, &models.RouteTelemetry{})
+	t0 := time.Now().Truncate(time.Minute).Add(-time.Minute)
+	telemetry.record(t0, routeKey{"GET", "/api/persist-test"}, 200, 12, 0, 0)
+	p := &TelemetryPersister{db: db, last: t0.Unix()/60 - 1}
+	p.flush(t0.Add(time.Minute))
+	var rows []models.RouteTelemetry
+	db.Where("route = ?", "/api/persist-test").Find(&rows)
+	if len(rows) != 1 || rows[0].InstanceID != InstanceID() || !near(rows[0].P95Ms, 12) {
+		t.Fatalf("expected one row tagged with this instance, got %+v", rows)
+	}
+}
@@ -3,6 +3,9 @@ package server
 import (
 	"net/http"
 	"os"
+	"path"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -25,17 +28,25 @@ func CORS() gin.HandlerFunc {
 }
 
 func RegisterStatic(r *gin.Engine) {
-	dir := os.Getenv("UPLOAD_DIR")
-	if dir == "" {
-		dir = "uploads"
-	}
+	dir := uploadDir()
 	if _, err := os.Stat(dir); os.IsNotExist(err) {
 		_ = os.MkdirAll(dir, 0755)
 	}
-	r.Static("/uploads", dir)
+	r.Group("/uploads", hideAttachments).Static("/", dir)
+}
+
+// hideAttachments keeps announcement attachments, which are stored with
+// the uploads, behind their signed and admin routes.
+func hideAttachments(c *gin.Context) {
+	p := path.Clean("/" + c.Param("filepath"))
+	if p == "/"+attachmentSubdir || strings.HasPrefix(p, "/"+attachmentSubdir+"/") {
+		c.AbortWithStatusJSON(http.StatusNotFound, respErr(1006, "not_found"))
+	}
 }
 
-func RegisterRoutes(r *gin.Engine, db *gorm.DB) {
+// RegisterRoutes mounts the API on r and starts the background jobs the
+// handlers rely on. The returned func stops those jobs; call it on shutdown.
+func RegisterRoutes(r *gin.Engine, db *gorm.DB) func() {
 	api := r.Group("/api")
 	auth := NewAuthController(db)
 	api.POST("/auth/register", auth.Register)
@@ -54,6 +65,22 @@ func RegisterRoutes(r *gin.Engine, db *gorm.DB) {
 	qa := NewQAController(db)
 	noti := NewNotificationsController(db)
 	announce := NewAnnouncementsController(db)
+	api.GET("/notifications/unsubscribe", noti.UnsubscribePage)
+	api.POST("/notifications/unsubscribe", noti.Unsubscribe)
+	api.GET("/announcements/:id/attachments/:aid", announce.PublicAttachment)
+	api.GET("/announcements/feed.atom", announce.PublicAtomFeed)
+	api.GET("/announcements/feed.ics", announce.PublicICSFeed)
+	digest := NewDigestJob(db, announce)
+	digest.Start()
+	RegisterProbe("announcements", 2*time.Second, announce.store.Ping)
+	archiver := NewAnnouncementArchiver(announce)
+	archiver.Start()
+	alertEngine := NewAlertEngine(db)
+	alertEngine.Start()
+	telemetryPersister := NewTelemetryPersister(db)
+	telemetryPersister.Start()
+	synth := NewSyntheticMonitor(db, r)
+	synth.Start()
 	p := api.Group("")
 	p.Use(jwt)
 	p.GET("/resources", res.List)
@@ -66,9 +93,23 @@ func RegisterRoutes(r *gin.Engine, db *gorm.DB) {
 	p.GET("/qa/questions", qa.List)
 	p.GET("/qa/questions/:id", qa.Detail)
 	p.POST("/qa/questions", qa.Create)
+	p.POST("/qa/questions/similar", qa.Similar)
+	p.POST("/qa/questions/:id/merge", qa.Merge)
+	p.POST("/qa/questions/:id/follow", qa.Follow)
+	p.DELETE("/qa/questions/:id/follow", qa.Unfollow)
+
+	answers := NewAnswersController(db)
+	p.GET("/questions/:id/answers", answers.List)
+	p.POST("/questions/:id/answers", answers.Create)
 
 	p.GET("/notifications", noti.Unread)
+	p.GET("/notifications/stream", noti.Stream)
+	p.GET("/notifications/history", noti.History)
+	p.GET("/notifications/preferences", noti.GetPreferences)
+	p.PUT("/notifications/preferences", noti.UpdatePreferences)
 	p.POST("/notifications/:id/read", noti.Read)
+	p.POST("/notifications/:id/archive", noti.Archive)
+	p.DELETE("/notifications/:id", noti.Delete)
 	p.POST("/notifications/read-all", noti.ReadAll)
 	p.GET("/announcements", announce.PublicList)
 	p.GET("/announcements/:id", announce.PublicDetail)
@@ -76,20 +117,43 @@ func RegisterRoutes(r *gin.Engine, db *gorm.DB) {
 	p.POST("/announcements/read-batch", announce.PublicBatchMarkRead)
 	p.POST("/announcements/:id/fav", announce.PublicToggleFavorite)
 	p.GET("/announcements/unread-count", announce.PublicUnreadCount)
+	p.GET("/announcements/feeds", announce.PublicFeedLinks)
+	p.POST("/announcements/feeds/rotate", announce.RotateFeedToken)
 
 	adm := api.Group("/admin")
 	adm.Use(jwt, RequireAdmin())
 	admin := NewAdminController(db)
 	adm.GET("/stats", admin.Stats)
 	adm.GET("/health", admin.Health)
+	adm.GET("/health/model", admin.HealthModel)
+	adm.GET("/telemetry/routes", admin.TelemetryRoutes)
+	adm.GET("/telemetry/history", admin.TelemetryHistory)
+	alerts := NewAlertsController(db, alertEngine)
+	adm.GET("/alerts", alerts.State)
+	adm.GET("/alerts/history", alerts.History)
+	adm.GET("/alerts/rules", alerts.ListRules)
+	adm.POST("/alerts/rules", alerts.CreateRule)
+	adm.PUT("/alerts/rules/:id", alerts.UpdateRule)
+	adm.DELETE("/alerts/rules/:id", alerts.DeleteRule)
+	adm.GET("/alerts/silences", alerts.ListSilences)
+	adm.POST("/alerts/silences", alerts.CreateSilence)
+	adm.DELETE("/alerts/silences/:id", alerts.ExpireSilence)
 	adm.GET("/health/trend", admin.HealthTrend)
 	adm.GET("/health/samples", admin.HealthSamples)
 	adm.GET("/health/stream", admin.HealthStream)
 	adm.GET("/service/status", admin.ServiceStatus)
+	adm.GET("/slo", admin.SLOStatus)
+	adm.GET("/slo/report", admin.SLOReport)
+	adm.GET("/synthetic", synth.SyntheticStatus)
+	adm.POST("/synthetic/run", synth.SyntheticRun)
+	adm.GET("/synthetic/history", synth.SyntheticHistory)
 	adm.GET("/teachers", admin.ListTeachers)
 	adm.POST("/teachers", admin.CreateTeacher)
 	adm.PUT("/teachers/:id", admin.UpdateTeacher)
 	adm.DELETE("/users/:id", admin.DeleteUser)
+	adm.GET("/courses/:id/members", admin.ListCourseMembers)
+	adm.POST("/courses/:id/members", admin.AddCourseMembers)
+	adm.DELETE("/courses/:id/members/:uid", admin.RemoveCourseMember)
 
 	adm.GET("/resources", admin.ListAuditResources)
 	adm.PUT("/resources/:id", admin.AuditResource)
@@ -100,6 +164,37 @@ func RegisterRoutes(r *gin.Engine, db *gorm.DB) {
 	adm.PUT("/answers/:id", admin.AuditAnswer)
 	adm.GET("/announcements", announce.AdminList)
 	adm.POST("/announcements", announce.AdminCreate)
+	adm.POST("/announcements/preview", announce.AdminPreview)
+	adm.POST("/announcements/:id/submit", announce.AdminSubmit)
+	adm.POST("/announcements/:id/approve", announce.AdminApprove)
+	adm.POST("/announcements/:id/reject", announce.AdminReject)
+	adm.POST("/announcements/:id/withdraw", announce.AdminWithdraw)
+	adm.POST("/announcements/:id/unpublish", announce.AdminUnpublish)
 	adm.PUT("/announcements/:id", announce.AdminUpdate)
 	adm.DELETE("/announcements/:id", announce.AdminDelete)
+	adm.GET("/announcements/trash", announce.AdminTrash)
+	adm.DELETE("/announcements/trash/:id", announce.AdminPurge)
+	adm.POST("/announcements/:id/restore", announce.AdminRestore)
+	adm.GET("/announcements/:id/revisions", announce.AdminRevisions)
+	adm.GET("/announcements/:id/revisions/:rev", announce.AdminRevision)
+	adm.GET("/announcements/:id/diff", announce.AdminDiff)
+	adm.POST("/announcements/:id/rollback", announce.AdminRollback)
+	adm.GET("/announcements/:id/analytics", announce.AdminAnalytics)
+	adm.GET("/announcements/:id/unread-users", announce.AdminUnreadUsers)
+	adm.POST("/announcements/:id/attachments", announce.AdminAddAttachment)
+	adm.GET("/announcements/:id/attachments/:aid", announce.AdminDownloadAttachment)
+	adm.DELETE("/announcements/:id/attachments/:aid", announce.AdminRemoveAttachment)
+	adm.GET("/announcements/archive", announce.AdminArchived)
+	adm.GET("/announcements/archive/policy", announce.AdminArchivePolicy)
+	adm.DELETE("/announcements/archive/:id", announce.AdminPurgeArchived)
+	adm.POST("/announcements/:id/unarchive", announce.AdminUnarchive)
+
+	return func() {
+		alertEngine.Stop()
+		telemetryPersister.Stop()
+		synth.Stop()
+		archiver.Stop()
+		digest.Stop()
+		announce.Stop()
+	}
 }
@@ -0,0 +1,516 @@
+package server
+
+import (
+	"encoding/csv"
+	"fmt"
+	htmltpl "html/template"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"scholarhub/backend-go/internal/models"
+)
+
+// incidentMissing is the status of a fleet-wide gap in the samples.
+const incidentMissing = "Missing"
+
+// incidentTracker records one instance's non-Healthy spans as incidents.
+type incidentTracker struct {
+	db       *gorm.DB
+	instance string
+	open     *models.HealthIncident
+	loaded   bool
+	last     time.Time
+}
+
+// observe takes every stored sample. An incident left open by a previous
+// run stays open until a Healthy sample.
+func (t *incidentTracker) observe(s models.HealthSample, interval time.Duration) {
+	if !t.loaded {
+		t.loaded = true
+		var prev models.HealthIncident
+		t.db.Where("\"instanceId\" = ? AND \"endedAt\" IS NULL", t.instance).Order("\"startedAt\" desc").Limit(1).Find(&prev)
+		if prev.ID != 0 {
+			t.open = &prev
+		}
+	}
+	// Only a late sample can follow a fleet-wide gap.
+	if t.last.IsZero() || s.CreateTime.Sub(t.last) > 3*interval {
+		t.recordGap(s, interval)
+	}
+	t.last = s.CreateTime
+	if s.Status == "Healthy" {
+		if t.open != nil {
+			t.db.Model(t.open).Update("endedAt", s.CreateTime)
+			t.open = nil
+		}
+		return
+	}
+	if t.open == nil {
+		t.open = &models.HealthIncident{InstanceID: t.instance, Status: s.Status, MinScore: s.Score, Samples: 1, StartedAt: s.CreateTime, LastSeenAt: s.CreateTime}
+		if err := t.db.Create(t.open).Error; err != nil {
+			t.open = nil
+		}
+		return
+	}
+	o := t.open
+	o.Samples++
+	o.LastSeenAt = s.CreateTime
+	if s.Score < o.MinScore {
+		o.MinScore = s.Score
+	}
+	if s.Status == "Critical" {
+		o.Status = "Critical"
+	}
+	t.db.Model(o).Updates(map[string]interface{}{"samples": o.Samples, "lastSeenAt": o.LastSeenAt, "minScore": o.MinScore, "status": o.Status})
+}
+
+// recordGap stores a fleet-wide gap of over three intervals as Missing.
+func (t *incidentTracker) recordGap(s models.HealthSample, interval time.Duration) {
+	var prev models.HealthSample
+	if err := t.db.Where("\"createTime\" < ?", s.CreateTime).Order("\"createTime\" desc").Limit(1).Find(&prev).Error; err != nil || prev.ID == 0 {
+		return
+	}
+	if s.CreateTime.Sub(prev.CreateTime) <= 3*interval {
+		return
+	}
+	end := s.CreateTime
+	t.db.Create(&models.HealthIncident{Status: incidentMissing, StartedAt: prev.CreateTime, LastSeenAt: prev.CreateTime, EndedAt: &end})
+}
+
+func envPercent(key string, def float64) float64 {
+	if v, err := strconv.ParseFloat(os.Getenv(key), 64); err == nil && v > 0 && v < 100 {
+		return v
+	}
+	return def
+}
+
+// sloLatencyMs is SLO_LATENCY_MS (default 500).
+func sloLatencyMs() float64 {
+	return float64(envInt("SLO_LATENCY_MS", 500))
+}
+
+// sloDef is one objective, in percent of good events.
+type sloDef struct {
+	Name        string
+	Description string
+	Objective   float64
+}
+
+// sloDefs are the objectives; the requests SLO needs TELEMETRY_PERSIST.
+func sloDefs() []sloDef {
+	defs := []sloDef{
+		{"availability", "seconds with no instance Critical and no gap in the health samples", envPercent("SLO_AVAILABILITY", 99.5)},
+		{"latency", fmt.Sprintf("health samples with API p95 within %.0f ms", sloLatencyMs()), envPercent("SLO_LATENCY", 99)},
+		{"health", "health samples Healthy (score above Warning)", envPercent("SLO_HEALTH", 95)},
+	}
+	if telemetryPersist() {
+		defs = append(defs, sloDef{"requests", "API requests answered without a 5xx", envPercent("SLO_REQUESTS", 99.9)})
+	}
+	return defs
+}
+
+type sloBudget struct {
+	Allowed   float64 `json:"allowed"`
+	Consumed  int64   `json:"consumed"`
+	Remaining float64 `json:"remainingPercent"`
+}
+
+type sloResult struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Objective   float64   `json:"objective"`
+	SLI         float64   `json:"sli"`
+	Good        int64     `json:"good"`
+	Total       int64     `json:"total"`
+	Met         bool      `json:"met"`
+	Budget      sloBudget `json:"errorBudget"`
+	BurnRate    float64   `json:"burnRate"`
+	Coverage    float64   `json:"coverage"`
+}
+
+func round3(v float64) float64 { return math.Round(v*1000) / 1000 }
+
+// evaluateSLO works out the SLI, error budget and burn rate; a burn rate
+// of 1 spends exactly the budget over the window.
+func evaluateSLO(d sloDef, good, total int64, coverage float64) sloResult {
+	r := sloResult{Name: d.Name, Description: d.Description, Objective: d.Objective, Good: good, Total: total, SLI: 100, Coverage: round3(coverage)}
+	allowedFrac := 1 - d.Objective/100
+	bad := total - good
+	r.Budget = sloBudget{Allowed: round3(float64(total) * allowedFrac), Consumed: bad, Remaining: 100}
+	if total > 0 {
+		r.SLI = round3(float64(good) / float64(total) * 100)
+		r.BurnRate = round3(float64(bad) / float64(total) / allowedFrac)
+		r.Budget.Remaining = round3((1 - r.BurnRate) * 100)
+	}
+	r.Met = r.SLI >= d.Objective
+	return r
+}
+
+type incidentPeriod struct {
+	InstanceID      string     `json:"instanceId"`
+	Status          string     `json:"status"`
+	StartedAt       time.Time  `json:"startedAt"`
+	EndedAt         *time.Time `json:"endedAt"`
+	DurationMinutes float64    `json:"durationMinutes"`
+	MinScore        int        `json:"minScore"`
+}
+
+type sloSource struct {
+	rollups   []models.HealthRollup
+	incidents []incidentPeriod
+	telemetry []models.RouteTelemetry
+}
+
+func loadSLOSource(db *gorm.DB, from, to time.Time) (sloSource, error) {
+	var src sloSource
+	var err error
+	if src.rollups, err = rollupTrend(db, rollupHour, from, to); err != nil {
+		return src, err
+	}
+	if src.incidents, err = loadIncidents(db, from, to); err != nil {
+		return src, err
+	}
+	if telemetryPersist() {
+		err = db.Select("\"bucketStart\", count, \"serverErrors\"").
+			Where("\"bucketStart\" >= ? AND \"bucketStart\" < ?", from, to).Order("\"bucketStart\" asc").Find(&src.telemetry).Error
+	}
+	return src, err
+}
+
+// loadIncidents returns the incidents in [from, to), plus an ongoing
+// Missing one while no instance is sampling.
+func loadIncidents(db *gorm.DB, from, to time.Time) ([]incidentPeriod, error) {
+	var rows []models.HealthIncident
+	if err := db.Where("\"startedAt\" < ? AND (\"endedAt\" IS NULL OR \"endedAt\" > ?)", to, from).Order("\"startedAt\" asc").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	var last models.HealthSample
+	if err := db.Order("\"createTime\" desc").Limit(1).Find(&last).Error; err != nil {
+		return nil, err
+	}
+	if last.ID != 0 && last.CreateTime.Before(to) && to.Sub(last.CreateTime) > 3*healthInterval() {
+		rows = append(rows, models.HealthIncident{Status: incidentMissing, StartedAt: last.CreateTime})
+	}
+	out := []incidentPeriod{}
+	gaps := map[int64]bool{}
+	for _, r := range rows {
+		// Instances that came back together each record the same gap.
+		if r.Status == incidentMissing {
+			if gaps[r.StartedAt.UnixNano()] {
+				continue
+			}
+			gaps[r.StartedAt.UnixNano()] = true
+		}
+		start, end := r.StartedAt, to
+		if start.Before(from) {
+			start = from
+		}
+		if r.EndedAt != nil && r.EndedAt.Before(to) {
+			end = *r.EndedAt
+		}
+		out = append(out, incidentPeriod{InstanceID: r.InstanceID, Status: r.Status, StartedAt: start, EndedAt: r.EndedAt,
+			DurationMinutes: math.Round(end.Sub(start).Minutes()*10) / 10, MinScore: r.MinScore})
+	}
+	return out, nil
+}
+
+// downtime is the union of Critical and Missing incidents in [from, to).
+func downtime(incidents []incidentPeriod, from, to time.Time) time.Duration {
+	type span struct{ a, b time.Time }
+	var spans []span
+	for _, in := range incidents {
+		if in.Status != "Critical" && in.Status != incidentMissing {
+			continue
+		}
+		a, b := in.StartedAt, to
+		if in.EndedAt != nil && in.EndedAt.Before(to) {
+			b = *in.EndedAt
+		}
+		if a.Before(from) {
+			a = from
+		}
+		if b.After(a) {
+			spans = append(spans, span{a, b})
+		}
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].a.Before(spans[j].a) })
+	total := time.Duration(0)
+	var cur *span
+	for i := range spans {
+		s := spans[i]
+		if cur != nil && !s.a.After(cur.b) {
+			if s.b.After(cur.b) {
+				cur.b = s.b
+			}
+			continue
+		}
+		if cur != nil {
+			total += cur.b.Sub(cur.a)
+		}
+		cur = &s
+	}
+	if cur != nil {
+		total += cur.b.Sub(cur.a)
+	}
+	return total
+}
+
+func downtimeMinutes(incidents []incidentPeriod, from, to time.Time) float64 {
+	return math.Round(downtime(incidents, from, to).Minutes()*10) / 10
+}
+
+// coverage is the share of [from, to) after the first data point.
+func coverage(first, from, to time.Time) float64 {
+	if first.IsZero() {
+		return 0
+	}
+	if first.Before(from) {
+		return 1
+	}
+	return to.Sub(first).Seconds() / to.Sub(from).Seconds()
+}
+
+// firstData is when the rollups or incidents in the window begin.
+func (src sloSource) firstData() time.Time {
+	var first time.Time
+	if len(src.rollups) > 0 {
+		first = src.rollups[0].BucketStart
+	}
+	for _, in := range src.incidents {
+		if first.IsZero() || in.StartedAt.Before(first) {
+			first = in.StartedAt
+		}
+	}
+	return first
+}
+
+// availability counts the seconds after the first sample that were up.
+func (src sloSource) availability(from, to time.Time) (good, total int64) {
+	first := src.firstData()
+	if first.IsZero() {
+		return 0, 0
+	}
+	if first.After(from) {
+		from = first
+	}
+	total = int64(to.Sub(from).Seconds())
+	return total - int64(downtime(src.incidents, from, to).Seconds()), total
+}
+
+func (src sloSource) evaluate(from, to time.Time) []sloResult {
+	var total, healthy, fast int64
+	for _, r := range src.rollups {
+		total += int64(r.Samples)
+		healthy += int64(r.HealthyCount)
+		fast += int64(r.LatencyGoodCount)
+	}
+	var reqs, reqErrs int64
+	var firstReq time.Time
+	for _, t := range src.telemetry {
+		if firstReq.IsZero() {
+			firstReq = t.BucketStart
+		}
+		reqs += int64(t.Count)
+		reqErrs += int64(t.ServerErrors)
+	}
+	cov := coverage(src.firstData(), from, to)
+	var out []sloResult
+	for _, d := range sloDefs() {
+		switch d.Name {
+		case "availability":
+			good, secs := src.availability(from, to)
+			out = append(out, evaluateSLO(d, good, secs, cov))
+		case "latency":
+			out = append(out, evaluateSLO(d, fast, total, cov))
+		case "health":
+			out = append(out, evaluateSLO(d, healthy, total, cov))
+		case "requests":
+			out = append(out, evaluateSLO(d, reqs-reqErrs, reqs, coverage(firstReq, from, to)))
+		}
+	}
+	return out
+}
+
+// SLOStatus evaluates every SLO over the last 7, 30 and 90 days.
+func (a *AdminController) SLOStatus(c *gin.Context) {
+	now := time.Now()
+	windows := []gin.H{}
+	for _, days := range []int{7, 30, 90} {
+		from := now.AddDate(0, 0, -days)
+		src, err := loadSLOSource(a.db, from, now)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, respErr(1004, "db_error"))
+			return
+		}
+		windows = append(windows, gin.H{"days": days, "from": from, "to": now, "slos": src.evaluate(from, now)})
+	}
+	c.JSON(http.StatusOK, respOk(gin.H{"windows": windows, "latencyTargetMs": sloLatencyMs()}))
+}
+
+type dayUptime struct {
+	Date         string  `json:"date"`
+	Samples      int     `json:"samples"`
+	Availability float64 `json:"availability"`
+	Health       float64 `json:"health"`
+}
+
+type uptimeReport struct {
+	Month           string           `json:"month"`
+	From            time.Time        `json:"from"`
+	To              time.Time        `json:"to"`
+	GeneratedAt     time.Time        `json:"generatedAt"`
+	UptimePercent   float64          `json:"uptimePercent"`
+	DowntimeMinutes float64          `json:"downtimeMinutes"`
+	SLOs            []sloResult      `json:"slos"`
+	Incidents       []incidentPeriod `json:"incidents"`
+	Days            []dayUptime      `json:"days"`
+}
+
+// buildUptimeReport covers the month starting at from, up to now.
+func buildUptimeReport(db *gorm.DB, from time.Time, now time.Time) (uptimeReport, error) {
+	to := from.AddDate(0, 1, 0)
+	if to.After(now) {
+		to = now
+	}
+	rep := uptimeReport{Month: from.Format("2006-01"), From: from, To: to, GeneratedAt: now, Days: []dayUptime{}}
+	src, err := loadSLOSource(db, from, to)
+	if err != nil {
+		return rep, err
+	}
+	rep.SLOs = src.evaluate(from, to)
+	for _, s := range rep.SLOs {
+		if s.Name == "availability" {
+			rep.UptimePercent = s.SLI
+		}
+	}
+	rep.Incidents = src.incidents
+	rep.DowntimeMinutes = downtimeMinutes(src.incidents, from, to)
+
+	byDay := map[string]*[2]int{}
+	for _, r := range src.rollups {
+		d := r.BucketStart.In(from.Location()).Format("2006-01-02")
+		v, ok := byDay[d]
+		if !ok {
+			v = &[2]int{}
+			byDay[d] = v
+		}
+		v[0] += r.Samples
+		v[1] += r.HealthyCount
+	}
+	first := src.firstData()
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		d := day.Format("2006-01-02")
+		row := dayUptime{Date: d, Availability: 100, Health: 100}
+		if v := byDay[d]; v != nil {
+			row.Samples = v[0]
+			if v[0] > 0 {
+				row.Health = round3(float64(v[1]) / float64(v[0]) * 100)
+			}
+		}
+		a, b := day, day.AddDate(0, 0, 1)
+		if b.After(to) {
+			b = to
+		}
+		if !first.IsZero() && first.After(a) {
+			a = first
+		}
+		if b.After(a) {
+			row.Availability = round3((1 - downtime(src.incidents, a, b).Seconds()/b.Sub(a).Seconds()) * 100)
+		}
+		rep.Days = append(rep.Days, row)
+	}
+	return rep, nil
+}
+
+// SLOReport serves month=YYYY-MM (default last month) as JSON, CSV or HTML.
+func (a *AdminController) SLOReport(c *gin.Context) {
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local).AddDate(0, -1, 0)
+	if m := c.Query("month"); m != "" {
+		t, err := time.ParseInLocation("2006-01", m, time.Local)
+		if err != nil || t.After(now) {
+			c.JSON(http.StatusBadRequest, respErr(1002, "invalid_month"))
+			return
+		}
+		from = t
+	}
+	rep, err := buildUptimeReport(a.db, from, now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, respErr(1004, "db_error"))
+		return
+	}
+	name := "uptime-" + rep.Month
+	switch c.Query("format") {
+	case "csv":
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.csv\"", name))
+		c.Writer.WriteString("\ufeff")
+		writeReportCSV(c.Writer, rep)
+	case "html":
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.html\"", name))
+		_ = uptimeReportTpl.Execute(c.Writer, rep)
+	default:
+		c.JSON(http.StatusOK, respOk(rep))
+	}
+}
+
+func f3(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+
+// writeReportCSV writes each table, separated by blank lines.
+func writeReportCSV(out io.Writer, rep uptimeReport) {
+	w := csv.NewWriter(out)
+	_ = w.Write([]string{"month", "from", "to", "uptimePercent", "downtimeMinutes"})
+	_ = w.Write([]string{rep.Month, rep.From.Format(time.RFC3339), rep.To.Format(time.RFC3339), f3(rep.UptimePercent), f3(rep.DowntimeMinutes)})
+	_ = w.Write(nil)
+	_ = w.Write([]string{"slo", "objective", "sli", "met", "good", "total", "burnRate", "budgetRemainingPercent"})
+	for _, s := range rep.SLOs {
+		_ = w.Write([]string{s.Name, f3(s.Objective), f3(s.SLI), strconv.FormatBool(s.Met), strconv.FormatInt(s.Good, 10),
+			strconv.FormatInt(s.Total, 10), f3(s.BurnRate), f3(s.Budget.Remaining)})
+	}
+	_ = w.Write(nil)
+	_ = w.Write([]string{"instance", "status", "startedAt", "endedAt", "durationMinutes", "minScore"})
+	for _, in := range rep.Incidents {
+		end := ""
+		if in.EndedAt != nil {
+			end = in.EndedAt.Format(time.RFC3339)
+		}
+		_ = w.Write([]string{in.InstanceID, in.Status, in.StartedAt.Format(time.RFC3339), end, f3(in.DurationMinutes), strconv.Itoa(in.MinScore)})
+	}
+	_ = w.Write(nil)
+	_ = w.Write([]string{"date", "samples", "availability", "health"})
+	for _, d := range rep.Days {
+		_ = w.Write([]string{d.Date, strconv.Itoa(d.Samples), f3(d.Availability), f3(d.Health)})
+	}
+	w.Flush()
+}
+
+var uptimeReportTpl = htmltpl.Must(htmltpl.New("uptime").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>ScholarHub uptime {{.Month}}</title>
+<style>body{font-family:sans-serif;margin:2em;color:#222}table{border-collapse:collapse;margin:1em 0}td,th{border:1px solid #ccc;padding:4px 10px;text-align:left}.bad{color:#b00}</style>
+</head><body>
+<h1>ScholarHub uptime report {{.Month}}</h1>
+<p>{{.From.Format "2006-01-02 15:04"}} – {{.To.Format "2006-01-02 15:04"}} · uptime <b>{{.UptimePercent}}%</b> · downtime {{.DowntimeMinutes}} min</p>
+<h2>Objectives</h2>
+<table><tr><th>SLO</th><th>Objective</th><th>Achieved</th><th>Burn rate</th><th>Budget left</th></tr>
+{{range .SLOs}}<tr><td>{{.Name}}<br><small>{{.Description}}</small></td><td>{{.Objective}}%</td><td{{if not .Met}} class="bad"{{end}}>{{.SLI}}%</td><td>{{.BurnRate}}</td><td>{{.Budget.Remaining}}%</td></tr>
+{{end}}</table>
+<h2>Incidents</h2>
+{{if .Incidents}}<table><tr><th>Instance</th><th>Status</th><th>Started</th><th>Ended</th><th>Minutes</th><th>Lowest score</th></tr>
+{{range .Incidents}}<tr><td>{{if .InstanceID}}{{.InstanceID}}{{else}}all{{end}}</td><td>{{.Status}}</td><td>{{.StartedAt.Format "2006-01-02 15:04"}}</td><td>{{if .EndedAt}}{{.EndedAt.Format "2006-01-02 15:04"}}{{else}}ongoing{{end}}</td><td>{{.DurationMinutes}}</td><td>{{.MinScore}}</td></tr>
+{{end}}</table>{{else}}<p>None.</p>{{end}}
+<h2>Daily</h2>
+<table><tr><th>Date</th><th>Samples</th><th>Availability</th><th>Healthy</th></tr>
+{{range .Days}}<tr><td>{{.Date}}</td><td>{{.Samples}}</td><td>{{.Availability}}%</td><td>{{.Health}}%</td></tr>
+{{end}}</table>
+<p><small>Generated {{.GeneratedAt.Format "2006-01-02 15:04:05"}}</small></p>
+</body></html>
+`))
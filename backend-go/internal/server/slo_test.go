@@ -0,0 +1,131 @@
+package server
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"scholarhub/backend-go/internal/models"
+)
+
+func TestEvaluateSLOBudget(t *testing.T) {
+	d := sloDef{Name: "availability", Objective: 99}
+	r := evaluateSLO(d, 9950, 10000, 1)
+	if r.SLI != 99.5 || !r.Met {
+		t.Fatalf("sli %v met %v", r.SLI, r.Met)
+	}
+	if r.Budget.Allowed != 100 || r.Budget.Consumed != 50 || r.BurnRate != 0.5 || r.Budget.Remaining != 50 {
+		t.Fatalf("unexpected budget %+v burn %v", r.Budget, r.BurnRate)
+	}
+
+	r = evaluateSLO(d, 9700, 10000, 1)
+	if r.Met || r.BurnRate != 3 || r.Budget.Remaining != -200 {
+		t.Fatalf("an overspent budget should go negative, got %+v burn %v", r.Budget, r.BurnRate)
+	}
+
+	r = evaluateSLO(d, 0, 0, 0)
+	if !r.Met || r.SLI != 100 || r.Budget.Remaining != 100 {
+		t.Fatalf("no data should leave the budget intact, got %+v", r)
+	}
+}
+
+func TestSLOSourceEvaluate(t *testing.T) {
+	t.Setenv("TELEMETRY_PERSIST", "")
+	from := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 2)
+	down := from.Add(30 * time.Hour).Add(864 * time.Second)
+	src := sloSource{rollups: []models.HealthRollup{
+		{BucketStart: from.Add(24 * time.Hour), Samples: 100, HealthyCount: 90, WarningCount: 8, CriticalCount: 2, LatencyGoodCount: 99},
+		{BucketStart: from.Add(25 * time.Hour), Samples: 100, HealthyCount: 100, LatencyGoodCount: 100},
+	}, incidents: []incidentPeriod{{InstanceID: "a", Status: "Critical", StartedAt: from.Add(30 * time.Hour), EndedAt: &down}}}
+	got := map[string]sloResult{}
+	for _, r := range src.evaluate(from, to) {
+		got[r.Name] = r
+	}
+	if len(got) != 3 {
+		t.Fatalf("requests SLO needs persisted telemetry, got %v", got)
+	}
+	if got["availability"].SLI != 99 || got["health"].SLI != 95 || got["latency"].SLI != 99.5 {
+		t.Fatalf("unexpected SLIs %+v", got)
+	}
+	if got["availability"].Coverage != 0.5 {
+		t.Fatalf("data starts halfway through the window, coverage %v", got["availability"].Coverage)
+	}
+}
+
+func TestDowntimeMergesOverlappingIncidents(t *testing.T) {
+	t0 := time.Date(2026, 5, 3, 10, 0, 0, 0, time.UTC)
+	at := func(m int) *time.Time { v := t0.Add(time.Duration(m) * time.Minute); return &v }
+	incidents := []incidentPeriod{
+		{InstanceID: "a", Status: "Critical", StartedAt: t0, EndedAt: at(30)},
+		{InstanceID: "b", Status: "Critical", StartedAt: *at(20), EndedAt: at(40)},
+		{InstanceID: "a", Status: "Warning", StartedAt: *at(60), EndedAt: at(120)},
+		{InstanceID: "a", Status: "Critical", StartedAt: *at(100), EndedAt: nil},
+		{Status: incidentMissing, StartedAt: *at(45), EndedAt: at(50)},
+	}
+	if got := downtimeMinutes(incidents, t0, *at(110)); got != 55 {
+		t.Fatalf("downtime = %v, want 40 + 5 without samples + 10 ongoing", got)
+	}
+}
+
+func TestIncidentsSpanRestartsAndGaps(t *testing.T) {
+	t.Setenv("HEALTH_INTERVAL_MS", "30000")
+	db := newTestDB(t, &models.HealthSample{}, &models.HealthIncident{}, &models.HealthRollupHour{})
+	t0 := time.Date(2026, 5, 3, 10, 0, 0, 0, time.UTC)
+	sample := func(tr *incidentTracker, at time.Time, status string) {
+		s := models.HealthSample{InstanceID: "a", Status: status, Score: 50, CreateTime: at}
+		db.Create(&s)
+		tr.observe(s, 30*time.Second)
+	}
+	sample(&incidentTracker{db: db, instance: "a"}, t0, "Critical")
+	// The process dies and a new one samples again ten minutes later.
+	sample(&incidentTracker{db: db, instance: "a"}, t0.Add(10*time.Minute), "Healthy")
+
+	var rows []models.HealthIncident
+	db.Order("id").Find(&rows)
+	if len(rows) != 2 || rows[0].EndedAt == nil || !rows[0].EndedAt.Equal(t0.Add(10*time.Minute)) ||
+		rows[1].Status != incidentMissing || !rows[1].StartedAt.Equal(t0) {
+		t.Fatalf("expected the incident to run until the next sample and the gap to be recorded, got %+v", rows)
+	}
+
+	rep, err := buildUptimeReport(db, time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC), t0.Add(11*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.DowntimeMinutes != 10 || rep.UptimePercent != round3((1-10.0/11)*100) {
+		t.Fatalf("uptime must follow the downtime: %v%% with %v min down", rep.UptimePercent, rep.DowntimeMinutes)
+	}
+
+	rep, _ = buildUptimeReport(db, time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC), t0.Add(20*time.Minute))
+	if rep.DowntimeMinutes != 20 {
+		t.Fatalf("the fleet has been silent for ten minutes, which is downtime too; got %v", rep.DowntimeMinutes)
+	}
+}
+
+func TestReportCSV(t *testing.T) {
+	from := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+	end := from.Add(time.Hour)
+	rep := uptimeReport{Month: "2026-04", From: from, To: from.AddDate(0, 1, 0), UptimePercent: 99.9, DowntimeMinutes: 60,
+		SLOs:      []sloResult{evaluateSLO(sloDef{Name: "availability", Objective: 99.5}, 999, 1000, 1)},
+		Incidents: []incidentPeriod{{InstanceID: "a", Status: "Critical", StartedAt: from, EndedAt: &end, DurationMinutes: 60, MinScore: 12}},
+		Days:      []dayUptime{{Date: "2026-04-01", Samples: 2880, Availability: 97.917, Health: 95}},
+	}
+	var buf bytes.Buffer
+	writeReportCSV(&buf, rep)
+	out := buf.String()
+	for _, want := range []string{
+		"2026-04,2026-04-01T00:00:00Z,2026-05-01T00:00:00Z,99.9,60\n\n",
+		"availability,99.5,99.9,true,999,1000,0.2,80\n",
+		"a,Critical,2026-04-01T00:00:00Z,2026-04-01T01:00:00Z,60,12\n",
+		"2026-04-01,2880,97.917,95\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in\n%s", want, out)
+		}
+	}
+	var html bytes.Buffer
+	if err := uptimeReportTpl.Execute(&html, rep); err != nil || !strings.Contains(html.String(), "uptime <b>99.9%</b>") {
+		t.Fatalf("html report: %v\n%s", err, html.String())
+	}
+}
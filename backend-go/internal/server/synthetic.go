@@ -0,0 +1,489 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"scholarhub/backend-go/internal/models"
+)
+
+// syntheticHeader carries syntheticSecret on loopback journey requests.
+const syntheticHeader = "X-Synthetic-Check"
+
+type syntheticKey struct{}
+
+// syntheticSecret is random per process.
+var syntheticSecret = func() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}()
+
+// isSyntheticRequest reports whether a journey of this process made r.
+func isSyntheticRequest(r *http.Request) bool {
+	if v, _ := r.Context().Value(syntheticKey{}).(bool); v {
+		return true
+	}
+	h := r.Header.Get(syntheticHeader)
+	return h != "" && hmac.Equal([]byte(h), []byte(syntheticSecret))
+}
+
+// errSkipped ends a journey early without counting as a failure.
+var errSkipped = errors.New("skipped")
+
+// journeyRun is what the steps of one pass share.
+type journeyRun struct {
+	m     *SyntheticMonitor
+	token string
+	vars  map[string]string
+}
+
+type journeyStepDef struct {
+	Name string
+	Do   func(r *journeyRun) (int, error)
+}
+
+// journeyDef is a user flow. Auth journeys are skipped without a token;
+// Cleanup runs even after a failed step.
+type journeyDef struct {
+	Name    string
+	Auth    bool
+	Steps   []journeyStepDef
+	Cleanup []journeyStepDef
+}
+
+// journeyResult is one journey of one pass.
+type journeyResult struct {
+	Journey    string                 `json:"journey"`
+	OK         bool                   `json:"ok"`
+	Skipped    bool                   `json:"skipped"`
+	DurationMs float64                `json:"durationMs"`
+	Steps      []models.SyntheticStep `json:"steps"`
+}
+
+var journeys = []journeyDef{
+	{Name: "login", Steps: []journeyStepDef{{"login", stepLogin}}},
+	{Name: "courses", Steps: []journeyStepDef{{"list courses", stepGet("/api/courses", nil)}}},
+	{Name: "resources", Auth: true, Steps: []journeyStepDef{{"list resources", stepGet("/api/resources?page=1&pageSize=10", nil)}}},
+	{Name: "announcement", Auth: true, Steps: []journeyStepDef{
+		{"list announcements", stepListAnnouncements},
+		{"fetch announcement", func(r *journeyRun) (int, error) {
+			return r.call(http.MethodGet, "/api/announcements/"+r.vars["announcement"], nil, "", nil)
+		}},
+	}},
+	{Name: "upload", Steps: []journeyStepDef{
+		{"upload file", stepUpload},
+		{"fetch file", func(r *journeyRun) (int, error) { return r.call(http.MethodGet, r.vars["upload"], nil, "", nil) }},
+	}, Cleanup: []journeyStepDef{{"delete file", stepDeleteUpload}}},
+}
+
+func stepGet(p string, out interface{}) func(r *journeyRun) (int, error) {
+	return func(r *journeyRun) (int, error) { return r.call(http.MethodGet, p, nil, "", out) }
+}
+
+func stepLogin(r *journeyRun) (int, error) {
+	body, _ := json.Marshal(gin.H{"username": r.m.user, "password": r.m.password})
+	var out struct {
+		Token string `json:"token"`
+	}
+	code, err := r.call(http.MethodPost, "/api/auth/login", bytes.NewReader(body), "application/json", &out)
+	if err == nil && out.Token == "" {
+		err = errors.New("no token in login response")
+	}
+	r.token = out.Token
+	return code, err
+}
+
+func stepListAnnouncements(r *journeyRun) (int, error) {
+	var out struct {
+		Items []struct {
+			ID string `json:"id"`
+		} `json:"items"`
+	}
+	code, err := r.call(http.MethodGet, "/api/announcements?pageSize=1", nil, "", &out)
+	if err == nil {
+		if len(out.Items) == 0 {
+			return code, fmt.Errorf("%w: no announcement visible to the probe account", errSkipped)
+		}
+		r.vars["announcement"] = out.Items[0].ID
+	}
+	return code, err
+}
+
+func stepUpload(r *journeyRun) (int, error) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	fw, _ := w.CreateFormFile("file", "synthetic-check.txt")
+	_, _ = fw.Write([]byte("synthetic check " + InstanceID() + "\n"))
+	_ = w.Close()
+	var out struct {
+		URL string `json:"url"`
+	}
+	code, err := r.call(http.MethodPost, "/api/uploads/files", &buf, w.FormDataContentType(), &out)
+	if !strings.HasPrefix(out.URL, "/uploads/") {
+		if err == nil {
+			err = fmt.Errorf("unexpected upload url %q", out.URL)
+		}
+		return code, err
+	}
+	r.vars["upload"] = out.URL
+	return code, err
+}
+
+// stepDeleteUpload removes the file directly; there is no delete API.
+func stepDeleteUpload(r *journeyRun) (int, error) {
+	if r.vars["upload"] == "" {
+		return 0, fmt.Errorf("%w: nothing was uploaded", errSkipped)
+	}
+	return 0, os.Remove(filepath.Join(uploadDir(), path.Base(r.vars["upload"])))
+}
+
+// call makes one request and unwraps the response envelope into out.
+func (r *journeyRun) call(method, p string, body io.Reader, contentType string, out interface{}) (int, error) {
+	ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), syntheticKey{}, true), r.m.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, r.m.baseURL+p, body)
+	if err != nil {
+		return 0, err
+	}
+	if r.m.baseURL != "" {
+		req.Header.Set(syntheticHeader, syntheticSecret)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if r.token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	}
+	code, b, err := r.m.do(req)
+	if err != nil {
+		return code, err
+	}
+	if code != http.StatusOK {
+		return code, fmt.Errorf("HTTP %d", code)
+	}
+	if out == nil && !strings.HasPrefix(p, "/api/") {
+		return code, nil
+	}
+	var env struct {
+		Code int             `json:"code"`
+		Msg  string          `json:"msg"`
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(b, &env); err != nil {
+		return code, fmt.Errorf("bad response: %v", err)
+	}
+	if env.Code != 0 {
+		return code, fmt.Errorf("code %d: %s", env.Code, env.Msg)
+	}
+	if out != nil {
+		if err := json.Unmarshal(env.Data, out); err != nil {
+			return code, fmt.Errorf("bad response data: %v", err)
+		}
+	}
+	return code, nil
+}
+
+// syntheticInterval is SYNTHETIC_INTERVAL_SEC (default 60).
+func syntheticInterval() time.Duration {
+	return time.Duration(envInt("SYNTHETIC_INTERVAL_SEC", 60)) * time.Second
+}
+
+// journeyStatus is the latest pass on this instance.
+type journeyStatus struct {
+	mu       sync.RWMutex
+	at       time.Time
+	interval time.Duration
+	results  []journeyResult
+}
+
+var journeyHealth = &journeyStatus{}
+
+func (s *journeyStatus) set(at time.Time, interval time.Duration, results []journeyResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.at, s.interval, s.results = at, interval, results
+}
+
+func (s *journeyStatus) snapshot() (time.Time, []journeyResult) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.at, s.results
+}
+
+// failurePercent is the share of journeys that failed, skipped ones aside;
+// a pass older than three intervals scores 0.
+func (s *journeyStatus) failurePercent(now time.Time) float64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.at.IsZero() || now.Sub(s.at) > 3*s.interval {
+		return 0
+	}
+	ran, failed := 0, 0
+	for _, r := range s.results {
+		if r.Skipped {
+			continue
+		}
+		ran++
+		if !r.OK {
+			failed++
+		}
+	}
+	if ran == 0 {
+		return 0
+	}
+	return round1(float64(failed) / float64(ran) * 100)
+}
+
+// SyntheticMonitor runs the journeys as SYNTHETIC_USER, in-process or, with
+// SYNTHETIC_BASE_URL, over loopback.
+type SyntheticMonitor struct {
+	db       *gorm.DB
+	handler  http.Handler
+	client   *http.Client
+	baseURL  string
+	user     string
+	password string
+	interval time.Duration
+	timeout  time.Duration
+
+	runMu  sync.Mutex
+	stopCh chan struct{}
+	done   chan struct{}
+	once   sync.Once
+}
+
+func NewSyntheticMonitor(db *gorm.DB, handler http.Handler) *SyntheticMonitor {
+	timeout := time.Duration(envInt("SYNTHETIC_TIMEOUT_MS", 5000)) * time.Millisecond
+	return &SyntheticMonitor{
+		db:       db,
+		handler:  handler,
+		client:   &http.Client{Timeout: timeout},
+		baseURL:  strings.TrimRight(os.Getenv("SYNTHETIC_BASE_URL"), "/"),
+		user:     os.Getenv("SYNTHETIC_USER"),
+		password: os.Getenv("SYNTHETIC_PASSWORD"),
+		interval: syntheticInterval(),
+		timeout:  timeout,
+		stopCh:   make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+func (m *SyntheticMonitor) enabled() bool { return m.user != "" && m.password != "" }
+
+func (m *SyntheticMonitor) mode() string {
+	if m.baseURL != "" {
+		return "loopback"
+	}
+	return "in-process"
+}
+
+// Start runs the first pass one interval in, once the server is listening.
+func (m *SyntheticMonitor) Start() {
+	if !m.enabled() {
+		log.Printf("synthetic checks disabled: set SYNTHETIC_USER and SYNTHETIC_PASSWORD")
+		close(m.done)
+		return
+	}
+	go func() {
+		defer close(m.done)
+		t := time.NewTicker(m.interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				m.RunOnce(time.Now())
+			case <-m.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+func (m *SyntheticMonitor) Stop() {
+	m.once.Do(func() { close(m.stopCh) })
+	<-m.done
+}
+
+// do sends req; an in-process request past the timeout counts as failed.
+func (m *SyntheticMonitor) do(req *http.Request) (int, []byte, error) {
+	if m.baseURL != "" {
+		resp, err := m.client.Do(req)
+		if err != nil {
+			return 0, nil, err
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		return resp.StatusCode, b, err
+	}
+	rec := httptest.NewRecorder()
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		m.handler.ServeHTTP(rec, req)
+	}()
+	select {
+	case <-finished:
+		return rec.Code, rec.Body.Bytes(), nil
+	case <-req.Context().Done():
+		return 0, nil, fmt.Errorf("no response within %s", m.timeout)
+	}
+}
+
+// RunOnce runs and stores one pass; passes never overlap.
+func (m *SyntheticMonitor) RunOnce(now time.Time) []journeyResult {
+	m.runMu.Lock()
+	defer m.runMu.Unlock()
+	run := &journeyRun{m: m, vars: map[string]string{}}
+	results := make([]journeyResult, 0, len(journeys))
+	var rows []models.SyntheticStep
+	for _, j := range journeys {
+		res := m.runJourney(run, j, now)
+		results = append(results, res)
+		rows = append(rows, res.Steps...)
+		recordJourneyMetrics(res)
+		if !res.OK && !res.Skipped {
+			log.Printf("synthetic_journey journey=%s failed: %s", j.Name, journeyError(res))
+		}
+	}
+	if err := m.db.CreateInBatches(rows, 100).Error; err != nil {
+		log.Printf("synthetic persist error rows=%d err=%v", len(rows), err)
+	}
+	journeyHealth.set(now, m.interval, results)
+	return results
+}
+
+// runJourney runs j's steps until one fails and records the rest as
+// skipped. Cleanup still runs once any step has.
+func (m *SyntheticMonitor) runJourney(run *journeyRun, j journeyDef, now time.Time) journeyResult {
+	res := journeyResult{Journey: j.Name, OK: true}
+	var stopped string
+	if j.Auth && run.token == "" {
+		stopped, res.Skipped = "login failed", true
+	}
+	ran := false
+	for i, s := range append(append([]journeyStepDef(nil), j.Steps...), j.Cleanup...) {
+		st := models.SyntheticStep{InstanceID: InstanceID(), RunAt: now, Journey: j.Name, Step: s.Name, Seq: i}
+		cleanup := i >= len(j.Steps)
+		if stopped != "" && !(cleanup && ran) {
+			st.Skipped, st.Error = true, stopped
+			res.Steps = append(res.Steps, st)
+			continue
+		}
+		ran = true
+		t0 := time.Now()
+		code, err := s.Do(run)
+		st.DurationMs = float64(time.Since(t0).Microseconds()) / 1000
+		st.StatusCode = code
+		res.DurationMs += st.DurationMs
+		switch {
+		case errors.Is(err, errSkipped):
+			st.Skipped, st.Error = true, err.Error()
+			if !cleanup {
+				stopped = err.Error()
+			}
+			if i == 0 {
+				res.Skipped = true
+			}
+		case err != nil:
+			st.Error = err.Error()
+			if !cleanup {
+				stopped = "an earlier step failed"
+			}
+			res.OK = false
+		default:
+			st.OK = true
+		}
+		res.Steps = append(res.Steps, st)
+	}
+	res.DurationMs = round1(res.DurationMs)
+	if res.Skipped {
+		res.OK = false
+	}
+	return res
+}
+
+func journeyError(res journeyResult) string {
+	for _, s := range res.Steps {
+		if !s.OK && !s.Skipped {
+			return s.Step + ": " + s.Error
+		}
+	}
+	return ""
+}
+
+// SyntheticStatus shows the latest pass on this instance.
+func (m *SyntheticMonitor) SyntheticStatus(c *gin.Context) {
+	at, results := journeyHealth.snapshot()
+	var last interface{}
+	if !at.IsZero() {
+		last = at
+	}
+	c.JSON(http.StatusOK, respOk(gin.H{
+		"enabled":        m.enabled(),
+		"mode":           m.mode(),
+		"intervalSec":    int(m.interval / time.Second),
+		"instance":       InstanceID(),
+		"lastRun":        last,
+		"failurePercent": journeyHealth.failurePercent(time.Now()),
+		"journeys":       results,
+	}))
+}
+
+// SyntheticRun runs a pass now and returns it.
+func (m *SyntheticMonitor) SyntheticRun(c *gin.Context) {
+	if !m.enabled() {
+		c.JSON(http.StatusConflict, respErr(1003, "synthetic_disabled"))
+		return
+	}
+	c.JSON(http.StatusOK, respOk(gin.H{"journeys": m.RunOnce(time.Now())}))
+}
+
+// SyntheticHistory returns the steps of the last hours (default 24, max 168).
+func (m *SyntheticMonitor) SyntheticHistory(c *gin.Context) {
+	hours, _ := strconv.Atoi(c.Query("hours"))
+	if hours < 1 {
+		hours = 24
+	}
+	if hours > 168 {
+		c.JSON(http.StatusBadRequest, respErr(1002, "range_too_large"))
+		return
+	}
+	tx := m.db.Where("\"runAt\" >= ?", time.Now().Add(-time.Duration(hours)*time.Hour))
+	if j := c.Query("journey"); j != "" {
+		tx = tx.Where("journey = ?", j)
+	}
+	if s := c.Query("step"); s != "" {
+		tx = tx.Where("step = ?", s)
+	}
+	if inst := c.Query("instance"); inst != "" {
+		tx = tx.Where("\"instanceId\" = ?", inst)
+	}
+	var items []models.SyntheticStep
+	if err := tx.Order("\"runAt\" asc, journey asc, seq asc").Limit(20000).Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, respErr(1004, "db_error"))
+		return
+	}
+	c.JSON(http.StatusOK, respOk(gin.H{"items": items}))
+}
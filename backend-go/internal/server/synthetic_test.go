@@ -0,0 +1,126 @@
+package server
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"golang.org/x/crypto/bcrypt"
+
+	"scholarhub/backend-go/internal/models"
+)
+
+// syntheticTestRouter mounts the handlers the journeys use, as
+// RegisterRoutes does, with a probe account whose password is "secret".
+func syntheticTestRouter(t *testing.T, items ...AnnouncementFile) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("UPLOAD_DIR", t.TempDir())
+	t.Setenv("JWT_SECRET", "s3cret")
+	db := newTestDB(t, &models.User{}, &models.Course{}, &models.CourseEnrollment{}, &models.Resource{}, &models.NotificationPreference{})
+	hash, _ := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	db.Create(&models.User{ID: "probe", Username: "probe", Password: string(hash), Role: "STUDENT"})
+	db.Create(&models.Course{Name: "Algebra"})
+	announce := &AnnouncementsController{db: db, store: newTestStore(t, items...)}
+
+	r := gin.New()
+	r.Use(RequestTelemetry())
+	RegisterStatic(r)
+	api := r.Group("/api")
+	api.POST("/auth/login", NewAuthController(db).Login)
+	api.GET("/courses", NewCoursesController(db).List)
+	api.POST("/uploads/files", NewUploadsController().File)
+	p := api.Group("")
+	p.Use(JWT())
+	p.GET("/resources", NewResourcesController(db).List)
+	p.GET("/announcements", announce.PublicList)
+	p.GET("/announcements/:id", announce.PublicDetail)
+	return r
+}
+
+func runJourneys(m *SyntheticMonitor) map[string]journeyResult {
+	run := &journeyRun{m: m, vars: map[string]string{}}
+	out := map[string]journeyResult{}
+	for _, j := range journeys {
+		out[j.Name] = m.runJourney(run, j, time.Now())
+	}
+	return out
+}
+
+func TestJourneysInProcess(t *testing.T) {
+	m := NewSyntheticMonitor(nil, syntheticTestRouter(t, AnnouncementFile{ID: "a1", Title: "Library hours", PublishAt: time.Now().Add(-time.Hour)}))
+	m.user, m.password = "probe", "secret"
+	courses := httpRequests.WithLabelValues("GET", "/api/courses", "200")
+	before := testutil.ToFloat64(courses)
+	got := runJourneys(m)
+	for name, res := range got {
+		if !res.OK || res.Skipped {
+			t.Fatalf("journey %s should pass: %+v", name, res)
+		}
+	}
+	if up := got["upload"].Steps; len(up) != 3 || up[0].StatusCode != 200 || up[1].StatusCode != 200 || !up[2].OK {
+		t.Fatalf("unexpected upload steps %+v", up)
+	}
+	if files, _ := os.ReadDir(uploadDir()); len(files) != 0 {
+		t.Fatalf("the uploaded file should be deleted, found %d", len(files))
+	}
+	if n := testutil.ToFloat64(courses) - before; n != 0 {
+		t.Fatalf("journey requests should stay out of request metrics, counted %v", n)
+	}
+}
+
+func TestJourneysSkipAfterFailedLogin(t *testing.T) {
+	m := NewSyntheticMonitor(nil, syntheticTestRouter(t))
+	m.user, m.password = "probe", "wrong"
+	got := runJourneys(m)
+	if got["login"].OK || got["login"].Steps[0].StatusCode != 401 {
+		t.Fatalf("login should fail with 401: %+v", got["login"])
+	}
+	if res := got["resources"]; !res.Skipped || res.Steps[0].Error != "login failed" {
+		t.Fatalf("resources needs a token and should be skipped: %+v", res)
+	}
+	if !got["courses"].OK || !got["upload"].OK {
+		t.Fatalf("public journeys still run: %+v %+v", got["courses"], got["upload"])
+	}
+
+	now := time.Now()
+	s := &journeyStatus{}
+	s.set(now, time.Minute, []journeyResult{got["login"], got["courses"], got["resources"], got["announcement"], got["upload"]})
+	if p := s.failurePercent(now); p != 33.3 {
+		t.Fatalf("one of three journeys that ran failed, got %v%%", p)
+	}
+	if p := s.failurePercent(now.Add(4 * time.Minute)); p != 0 {
+		t.Fatalf("a stale pass should not count, got %v%%", p)
+	}
+}
+
+func TestJourneyWithNothingToFetchIsSkipped(t *testing.T) {
+	m := NewSyntheticMonitor(nil, syntheticTestRouter(t, AnnouncementFile{ID: "draft", Title: "Draft", Status: StatusDraft, PublishAt: time.Now().Add(-time.Hour)}))
+	m.user, m.password = "probe", "secret"
+	res := runJourneys(m)["announcement"]
+	if !res.Skipped || res.OK || !res.Steps[1].Skipped {
+		t.Fatalf("no announcement should skip the journey, got %+v", res)
+	}
+}
+
+func TestUploadCleanupRunsAfterFailedFetch(t *testing.T) {
+	r := syntheticTestRouter(t)
+	m := NewSyntheticMonitor(nil, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodGet && strings.HasPrefix(req.URL.Path, "/uploads/") {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		r.ServeHTTP(w, req)
+	}))
+	m.user, m.password = "probe", "secret"
+	res := runJourneys(m)["upload"]
+	if res.OK || res.Steps[1].StatusCode != 503 || !res.Steps[2].OK || res.Steps[2].Skipped {
+		t.Fatalf("the fetch should fail and the delete still run: %+v", res.Steps)
+	}
+	if files, _ := os.ReadDir(uploadDir()); len(files) != 0 {
+		t.Fatalf("a failed journey must not leave its file behind, found %d", len(files))
+	}
+}
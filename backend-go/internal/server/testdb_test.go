@@ -0,0 +1,69 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB opens a private in-memory database with tables for models.
+func newTestDB(t *testing.T, models ...interface{}) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// asUser stands in for the JWT middleware.
+func asUser(uid, role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("user_id", uid)
+		c.Set("role", role)
+	}
+}
+
+// doJSON sends body as JSON through r and decodes the response envelope.
+func doJSON(t *testing.T, r http.Handler, method, path string, body interface{}) (int, apiResp) {
+	t.Helper()
+	var rd *bytes.Reader
+	if body != nil {
+		b, _ := json.Marshal(body)
+		rd = bytes.NewReader(b)
+	} else {
+		rd = bytes.NewReader(nil)
+	}
+	req := httptest.NewRequest(method, path, rd)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var resp apiResp
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	return w.Code, resp
+}
+
+// newTestStore is a file announcement store in a temp dir, seeded with items.
+func newTestStore(t *testing.T, items ...AnnouncementFile) *FileAnnouncementStore {
+	t.Helper()
+	s := NewFileAnnouncementStore(t.TempDir(), nil)
+	for _, af := range items {
+		if err := s.Save(af); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
@@ -355,9 +355,10 @@ export const AdminApi = {
   },
   health: () => adminFetch(`/admin/health?_=${Date.now()}`),
   serviceStatus: () => adminFetch(`/admin/service/status?_=${Date.now()}`),
-  healthTrend: (days?: number) => {
+  healthTrend: (days?: number, step?: 'raw' | 'hour' | 'day') => {
     const q = new URLSearchParams()
     if (days && days > 0) q.set('days', String(days))
+    if (step) q.set('step', step)
     q.set('_', String(Date.now()))
     return adminFetch(`/admin/health/trend?${q.toString()}`)
   },